@@ -7,17 +7,29 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Kostaaa1/tinylink/core/transactor"
+	accountHandler "github.com/Kostaaa1/tinylink/internal/api/account"
+	adminHandler "github.com/Kostaaa1/tinylink/internal/api/admin"
+	analyticsHandler "github.com/Kostaaa1/tinylink/internal/api/analytics"
+	apiKeyHandler "github.com/Kostaaa1/tinylink/internal/api/apikey"
 	tinylinkHandler "github.com/Kostaaa1/tinylink/internal/api/tinylink"
 	userHandler "github.com/Kostaaa1/tinylink/internal/api/user"
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/Kostaaa1/tinylink/internal/domain/admin"
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
 	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
 	"github.com/Kostaaa1/tinylink/internal/domain/token"
 	"github.com/Kostaaa1/tinylink/internal/domain/user"
 	"github.com/Kostaaa1/tinylink/internal/infra/postgres"
 	"github.com/Kostaaa1/tinylink/internal/infra/redis"
 	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/mailer"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	goredis "github.com/redis/go-redis/v9"
@@ -28,6 +40,8 @@ type application struct {
 	conf   Config
 	router *mux.Router
 	log    *slog.Logger
+	// called after the http server is shut down, used for flushing background workers
+	onShutdown []func(ctx context.Context) error
 }
 
 func (a *application) serve() error {
@@ -52,7 +66,12 @@ func (a *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		shutdownErr <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		for _, fn := range a.onShutdown {
+			err = errors.Join(err, fn(ctx))
+		}
+
+		shutdownErr <- err
 	}()
 
 	a.log.Info("Server started on port", "port", a.conf.Port, "env", a.conf.Env, "version", version)
@@ -67,27 +86,135 @@ func (a *application) serve() error {
 
 func (a *application) registerUsers(
 	pool *pgxpool.Pool,
+	txManager transactor.Transactor,
+	roleRepo user.RoleRepository,
+	tokenService *token.Service,
+	lockout *user.Lockout,
+	challenges user.ChallengeRepository,
+	pendingLinks user.PendingLinkRepository,
+	oauthService *oauth.Service,
+	mail mailer.Mailer,
+	errHandler errhandler.ErrorHandler,
+	authMW, loginLimitMW, resendLimitMW mux.MiddlewareFunc,
+) (*user.Service, *user.TwoFactorService) {
+	userRepo := postgres.NewUserRepository(pool)
+	twoFactor := user.NewTwoFactorService(userRepo, postgres.NewTwoFactorRepository(pool), challenges, txManager, user.TwoFactorConfig{
+		Issuer: a.conf.TwoFactorIssuer,
+	})
+	userService := user.NewService(userRepo, postgres.NewIdentityRepository(pool), pendingLinks, roleRepo, tokenService, postgres.NewLinkClaimer(pool), lockout, twoFactor, txManager)
+	resetService := user.NewPasswordResetService(userRepo, postgres.NewResetTokenRepository(pool), tokenService, mail, txManager, user.PasswordResetConfig{
+		TokenTTL: a.conf.PasswordResetTTL,
+		ResetURL: strings.TrimSuffix(a.conf.AppURL, "/") + "/reset-password",
+	}, a.log)
+	a.onShutdown = append(a.onShutdown, resetService.Shutdown)
+	verifyService := user.NewEmailVerificationService(userRepo, mail, user.EmailVerificationConfig{
+		Secret:    a.conf.EmailVerification.Secret,
+		TokenTTL:  a.conf.EmailVerification.TTL,
+		VerifyURL: strings.TrimSuffix(a.conf.AppURL, "/") + "/user/verify",
+	}, a.log)
+	userHandler := userHandler.NewUserHandler(userService, resetService, verifyService, twoFactor, tokenService, oauthService, errHandler, a.log)
+	userHandler.RegisterRoutes(a.router, authMW, loginLimitMW, resendLimitMW)
+
+	return userService, twoFactor
+}
+
+func (a *application) registerAccount(
+	pool *pgxpool.Pool,
+	txManager transactor.Transactor,
+	userService *user.Service,
+	twoFactor *user.TwoFactorService,
 	tokenRepo token.Repository,
+	tlService *tinylink.Service,
 	errHandler errhandler.ErrorHandler,
-	authMW mux.MiddlewareFunc,
+	authMW, loginLimitMW mux.MiddlewareFunc,
 ) {
-	userRepo := postgres.NewUserRepository(pool)
-	userService := user.NewService(userRepo, tokenRepo)
-	userHandler := userHandler.NewUserHandler(userService, errHandler, a.log)
-	userHandler.RegisterRoutes(a.router, authMW)
+	accountService := account.NewService(
+		postgres.NewAccountRepository(pool),
+		postgres.NewUserRepository(pool),
+		postgres.NewIdentityRepository(pool),
+		userService,
+		twoFactor,
+		postgres.NewAPIKeyRepository(pool),
+		tokenRepo,
+		tlService,
+		tlService,
+		txManager,
+		account.Config{
+			LinkPolicy: account.LinkPolicy(a.conf.AccountDeletion.LinkPolicy),
+			TransferTo: a.conf.AccountDeletion.TransferTo,
+		},
+	)
+	accountHandler := accountHandler.NewAccountHandler(accountService, errHandler, a.log)
+	accountHandler.RegisterRoutes(a.router, authMW, loginLimitMW)
+}
+
+func (a *application) registerAPIKeys(apiKeyService *apikey.Service, errHandler errhandler.ErrorHandler, authMW mux.MiddlewareFunc) {
+	apiKeyHandler := apiKeyHandler.NewAPIKeyHandler(apiKeyService, errHandler, a.log)
+	apiKeyHandler.RegisterRoutes(a.router, authMW)
 }
 
 func (a *application) registerTinylink(
+	ctx context.Context,
 	pool *pgxpool.Pool,
+	txManager transactor.Transactor,
 	redisClient *goredis.Client,
 	errHandler errhandler.ErrorHandler,
-	authMW mux.MiddlewareFunc,
-) {
+	authMW, apiKeyMW, redirectLimitMW, createLimitMW mux.MiddlewareFunc,
+) *tinylink.Service {
 	tlRepo := postgres.NewTinylinkRepository(pool)
 	tlCacheRepo := redis.NewTinylinkRepository(redisClient)
-	tlService := tinylink.NewService(tlRepo, tlCacheRepo)
+
+	analyticsRepo := postgres.NewAnalyticsRepository(pool)
+
+	clicks := analytics.NewCollector(analyticsRepo, analytics.CollectorConfig{
+		QueueSize:     a.conf.Analytics.QueueSize,
+		Workers:       a.conf.Analytics.Workers,
+		BatchSize:     a.conf.Analytics.BatchSize,
+		FlushInterval: a.conf.Analytics.FlushInterval,
+		IPSalt:        a.conf.Analytics.IPSalt,
+	}, a.log)
+	clicks.Start()
+	a.onShutdown = append(a.onShutdown, clicks.Shutdown)
+
+	var verified tinylink.VerificationChecker
+	if a.conf.EmailVerification.Required {
+		verified = postgres.NewVerificationChecker(pool)
+	}
+
+	tlService := tinylink.NewService(tlRepo, tlCacheRepo, clicks, verified, txManager)
+
+	reaper := tinylink.NewReaper(tlRepo, tinylink.ReaperConfig{
+		Mode:      tinylink.ReaperMode(a.conf.Reaper.Mode),
+		Interval:  a.conf.Reaper.Interval,
+		BatchSize: a.conf.Reaper.BatchSize,
+	}, a.log)
+	go reaper.Run(ctx)
+
+	evictionRelay := tinylink.NewEvictionRelay(tlRepo, tlCacheRepo, 5*time.Second, a.log)
+	go evictionRelay.Run(ctx)
+
 	tlHandler := tinylinkHandler.NewTinylinkHandler(tlService, errHandler, a.log)
-	tlHandler.RegisterRoutes(a.router, authMW)
+	tlHandler.RegisterRoutes(a.router, authMW, apiKeyMW, redirectLimitMW, createLimitMW)
+
+	analyticsService := analytics.NewService(analyticsRepo)
+	analyticsHandler := analyticsHandler.NewAnalyticsHandler(analyticsService, errHandler, a.log)
+	analyticsHandler.RegisterRoutes(a.router, authMW, apiKeyMW)
+
+	return tlService
+}
+
+func (a *application) registerAdmin(
+	pool *pgxpool.Pool,
+	txManager transactor.Transactor,
+	tokenRepo token.Repository,
+	tlService *tinylink.Service,
+	errHandler errhandler.ErrorHandler,
+	authMW, adminMW mux.MiddlewareFunc,
+) {
+	adminRepo := postgres.NewAdminRepository(pool)
+	adminService := admin.NewService(adminRepo, tokenRepo, tlService, txManager)
+	adminHandler := adminHandler.NewAdminHandler(adminService, errHandler, a.log)
+	adminHandler.RegisterRoutes(a.router, authMW, adminMW)
 }
 
 func (a *application) registerSwagger() {
@@ -2,16 +2,32 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/Kostaaa1/tinylink/internal/domain/ratelimit"
 	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
 	"github.com/Kostaaa1/tinylink/internal/infra/db"
 	"github.com/Kostaaa1/tinylink/internal/infra/middleware"
+	"github.com/Kostaaa1/tinylink/internal/infra/postgres"
 	"github.com/Kostaaa1/tinylink/internal/infra/redis"
 	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/Kostaaa1/tinylink/pkg/mailer"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
@@ -21,6 +37,57 @@ type Config struct {
 	Env         string
 	PostgresDSN string
 	RedisDSN    string
+	// TrustedProxies lists proxies whose X-Forwarded-For and X-Real-IP headers identify the client, see iputil.ClientIP
+	TrustedProxies string
+	// AppURL is the public URL of the frontend, used for links in emails
+	AppURL string
+	Mail   struct {
+		SMTPHost     string
+		SMTPPort     int
+		SMTPUsername string
+		SMTPPassword string
+		From         string
+		Dir          string
+	}
+	PasswordResetTTL time.Duration
+	// OAuthProviders is a comma separated list of external login providers, see newOAuthRegistry
+	OAuthProviders string
+	// TwoFactorIssuer is the name authenticator apps show next to the account
+	TwoFactorIssuer   string
+	EmailVerification struct {
+		Secret []byte
+		TTL    time.Duration
+		// Required restricts custom aliases and private links to users with verified email
+		Required bool
+	}
+	AccountDeletion struct {
+		LinkPolicy string
+		// TransferTo is the account receiving links of deleted users when LinkPolicy is transfer
+		TransferTo uint64
+	}
+	Reaper struct {
+		Mode      string
+		Interval  time.Duration
+		BatchSize int
+	}
+	JWT struct {
+		SigningKey       string
+		VerificationKeys string
+	}
+	RateLimit struct {
+		Enabled  bool
+		Redirect ratelimit.Limit
+		Create   ratelimit.Limit
+		Login    ratelimit.Limit
+		Resend   ratelimit.Limit
+	}
+	Analytics struct {
+		QueueSize     int
+		Workers       int
+		BatchSize     int
+		FlushInterval time.Duration
+		IPSalt        string
+	}
 }
 
 const (
@@ -41,8 +108,64 @@ func main() {
 	flag.StringVar(&conf.Env, "env", "development", "environment (development|production)")
 	flag.StringVar(&conf.PostgresDSN, "postgres-dsn", os.Getenv("POSTGRES_DSN"), "")
 	flag.StringVar(&conf.RedisDSN, "redis-dsn", os.Getenv("REDIS_DSN"), "")
+	flag.StringVar(&conf.OAuthProviders, "oauth-providers", envOr("OAUTH_PROVIDERS", defaultOAuthProviders()), "comma separated external login providers, e.g. google,github")
+	flag.StringVar(&conf.TrustedProxies, "trusted-proxies", os.Getenv("TRUSTED_PROXIES"), "comma separated IPs and CIDR ranges of reverse proxies allowed to set X-Forwarded-For, headers are ignored if empty")
+	flag.StringVar(&conf.AppURL, "app-url", envOr("APP_URL", "http://localhost:8000"), "public URL of the frontend used in emailed links")
+	flag.StringVar(&conf.Mail.SMTPHost, "smtp-host", os.Getenv("SMTP_HOST"), "SMTP server host, emails are written to mail-dir or logged if empty")
+	flag.IntVar(&conf.Mail.SMTPPort, "smtp-port", 587, "SMTP server port")
+	flag.StringVar(&conf.Mail.SMTPUsername, "smtp-username", os.Getenv("SMTP_USERNAME"), "")
+	flag.StringVar(&conf.Mail.SMTPPassword, "smtp-password", os.Getenv("SMTP_PASSWORD"), "")
+	flag.StringVar(&conf.Mail.From, "mail-from", envOr("MAIL_FROM", "Tinylink <no-reply@localhost>"), "sender address of emails")
+	flag.StringVar(&conf.Mail.Dir, "mail-dir", os.Getenv("MAIL_DIR"), "directory where emails are written as .eml files when smtp-host is not set")
+	flag.DurationVar(&conf.PasswordResetTTL, "password-reset-ttl", 30*time.Minute, "how long password reset links are valid")
+	flag.Func("email-verification-secret", "secret used for signing email verification tokens (env EMAIL_VERIFICATION_SECRET)", func(v string) error {
+		conf.EmailVerification.Secret = []byte(v)
+		return nil
+	})
+	flag.DurationVar(&conf.EmailVerification.TTL, "email-verification-ttl", 48*time.Hour, "how long email verification links are valid")
+	flag.BoolVar(&conf.EmailVerification.Required, "require-verified-email", false, "allow custom aliases and private links only to users with verified email")
+	flag.StringVar(&conf.TwoFactorIssuer, "two-factor-issuer", "Tinylink", "issuer name shown by authenticator apps")
+	flag.StringVar(&conf.AccountDeletion.LinkPolicy, "account-deletion-link-policy", "delete", "what to do with links of deleted accounts (delete|anonymize|transfer)")
+	flag.Uint64Var(&conf.AccountDeletion.TransferTo, "account-deletion-transfer-to", 0, "id of the account receiving links of deleted accounts, required by the transfer policy")
+	flag.StringVar(&conf.Reaper.Mode, "reaper-mode", "archive", "what to do with expired tinylinks (archive|delete)")
+	flag.DurationVar(&conf.Reaper.Interval, "reaper-interval", time.Minute, "how often expired tinylinks are reaped")
+	flag.IntVar(&conf.Reaper.BatchSize, "reaper-batch-size", 500, "max number of expired tinylinks reaped per query")
+	flag.IntVar(&conf.Analytics.QueueSize, "analytics-queue-size", 10000, "max number of pending click events")
+	flag.IntVar(&conf.Analytics.Workers, "analytics-workers", 2, "number of workers writing click events")
+	flag.IntVar(&conf.Analytics.BatchSize, "analytics-batch-size", 500, "max number of click events per insert")
+	flag.DurationVar(&conf.Analytics.FlushInterval, "analytics-flush-interval", time.Second, "how often pending click events are flushed")
+	flag.StringVar(&conf.Analytics.IPSalt, "analytics-ip-salt", os.Getenv("ANALYTICS_IP_SALT"), "salt used for hashing visitor IP addresses")
+	flag.BoolVar(&conf.RateLimit.Enabled, "ratelimit-enabled", true, "enable rate limiting")
+	flag.TextVar(&conf.RateLimit.Redirect, "ratelimit-redirect", ratelimit.Limit{Requests: 300, Window: time.Minute}, "redirects per client, as requests/window or off")
+	flag.TextVar(&conf.RateLimit.Create, "ratelimit-create", ratelimit.Limit{Requests: 30, Window: time.Minute}, "link creation requests per client, as requests/window or off")
+	flag.TextVar(&conf.RateLimit.Login, "ratelimit-login", ratelimit.Limit{Requests: 10, Window: time.Minute}, "login and register requests per IP, as requests/window or off")
+	flag.TextVar(&conf.RateLimit.Resend, "ratelimit-verify-resend", ratelimit.Limit{Requests: 3, Window: time.Hour}, "verification emails resent per user, as requests/window or off")
+	flag.StringVar(&conf.JWT.SigningKey, "jwt-signing-key", os.Getenv("JWT_SIGNING_KEY"), "path to PEM encoded RSA or Ed25519 private key used for signing access tokens, falls back to HS256 with JWT_SECRET_KEY")
+	flag.StringVar(&conf.JWT.VerificationKeys, "jwt-verification-keys", os.Getenv("JWT_VERIFICATION_KEYS"), "comma separated paths to PEM encoded keys of previous signing keys, still accepted for verification")
 	flag.Parse()
 
+	if err := configureJWT(conf); err != nil {
+		log.Fatal(err)
+	}
+
+	trustedProxies, err := iputil.ParseTrustedProxies(conf.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	iputil.SetTrustedProxies(trustedProxies)
+
+	if err := configureEmailVerification(&conf); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := configureAccountDeletion(&conf); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := configureAnalytics(&conf); err != nil {
+		log.Fatal(err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -63,18 +186,143 @@ func main() {
 	}
 
 	tokenRepo := redis.NewTokenRepository(redisClient)
-	tokenService := token.NewService(tokenRepo)
+	roleRepo := postgres.NewRoleRepository(dbPool)
+	tokenService := token.NewService(tokenRepo, tokenRepo, roleRepo)
+
+	apiKeyService := apikey.NewService(postgres.NewAPIKeyRepository(dbPool), a.log)
 
 	errHandler := errhandler.New(a.log)
-	mw := middleware.New(errHandler, tokenService, a.log)
+	var limiter *ratelimit.Limiter
+	if conf.RateLimit.Enabled {
+		limiter = ratelimit.NewLimiter(redis.NewRateLimitStore(redisClient), a.log)
+	}
+	mw := middleware.New(errHandler, tokenService, apiKeyService, limiter, a.log)
 
 	a.router.Use(mw.Global)
 
+	txManager := pgxtx.New(dbPool)
+
 	a.registerSwagger()
-	a.registerUsers(dbPool, tokenRepo, errHandler, mw.RouteProtector)
-	a.registerTinylink(dbPool, redisClient, errHandler, mw.RouteProtector)
+	loginLimit := mw.RateLimit("login", conf.RateLimit.Login, true)
+	lockout := user.NewLockout(redis.NewLoginAttemptRepository(redisClient), user.DefaultLockoutConfig(), user.NewLogNotifier(a.log))
+	mail, err := newMailer(conf, a.log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resendLimit := mw.RateLimit("verify-resend", conf.RateLimit.Resend, false)
+	oauthProviders, err := newOAuthRegistry(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	oauthService := oauth.NewService(oauthProviders, redis.NewOAuthStateRepository(redisClient), 0)
+	userService, twoFactor := a.registerUsers(dbPool, txManager, roleRepo, tokenService, lockout, redis.NewLoginChallengeRepository(redisClient), redis.NewPendingLinkRepository(redisClient), oauthService, mail, errHandler, mw.RouteProtector, loginLimit, resendLimit)
+	a.registerAPIKeys(apiKeyService, errHandler, mw.RouteProtector)
+	linksScope := mw.APIKeyScope(apikey.ScopeLinksRead, apikey.ScopeLinksWrite)
+	redirectLimit := mw.RateLimit("redirect", conf.RateLimit.Redirect, false)
+	createLimit := mw.RateLimit("create", conf.RateLimit.Create, false)
+	tlService := a.registerTinylink(ctx, dbPool, txManager, redisClient, errHandler, mw.RouteProtector, linksScope, redirectLimit, createLimit)
+	a.registerAdmin(dbPool, txManager, tokenRepo, tlService, errHandler, mw.RouteProtector, mw.RequireRoles(auth.RoleAdmin))
+	a.registerAccount(dbPool, txManager, userService, twoFactor, tokenRepo, tlService, errHandler, mw.RouteProtector, loginLimit)
 
 	if err := a.serve(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// newMailer returns the SMTP mailer if smtp-host is set. Otherwise emails are written to mail-dir, or logged, which is allowed only outside production.
+func newMailer(conf Config, log *slog.Logger) (mailer.Mailer, error) {
+	switch {
+	case conf.Mail.SMTPHost != "":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     conf.Mail.SMTPHost,
+			Port:     conf.Mail.SMTPPort,
+			Username: conf.Mail.SMTPUsername,
+			Password: conf.Mail.SMTPPassword,
+			From:     conf.Mail.From,
+		}), nil
+	case conf.Env == "production":
+		return nil, errors.New("smtp-host must be set in production")
+	case conf.Mail.Dir != "":
+		return mailer.NewFileMailer(conf.Mail.Dir, conf.Mail.From)
+	default:
+		return mailer.NewLogMailer(log), nil
+	}
+}
+
+// configureEmailVerification falls back to EMAIL_VERIFICATION_SECRET and, outside production, to a random secret, which invalidates pending links on restart.
+func configureEmailVerification(conf *Config) error {
+	if len(conf.EmailVerification.Secret) == 0 {
+		conf.EmailVerification.Secret = []byte(os.Getenv("EMAIL_VERIFICATION_SECRET"))
+	}
+	if len(conf.EmailVerification.Secret) > 0 {
+		return nil
+	}
+	if conf.Env == "production" {
+		return errors.New("email-verification-secret or EMAIL_VERIFICATION_SECRET must be set in production")
+	}
+
+	conf.EmailVerification.Secret = make([]byte, 32)
+	_, err := rand.Read(conf.EmailVerification.Secret)
+	return err
+}
+
+func configureAccountDeletion(conf *Config) error {
+	policy, err := account.ParseLinkPolicy(conf.AccountDeletion.LinkPolicy)
+	if err != nil {
+		return err
+	}
+	if policy == account.LinkPolicyTransfer && conf.AccountDeletion.TransferTo == 0 {
+		return errors.New("account-deletion-transfer-to must be set when account-deletion-link-policy is transfer")
+	}
+	return nil
+}
+
+// configureAnalytics requires an IP salt in production, since unsalted IP hashes can be reversed by brute force. Outside production a random salt is used, so visitors are not matched across restarts.
+func configureAnalytics(conf *Config) error {
+	if conf.Analytics.IPSalt != "" {
+		return nil
+	}
+	if conf.Env == "production" {
+		return errors.New("analytics-ip-salt or ANALYTICS_IP_SALT must be set in production")
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	conf.Analytics.IPSalt = hex.EncodeToString(salt)
+	return nil
+}
+
+func configureJWT(conf Config) error {
+	if conf.JWT.SigningKey == "" {
+		secret := os.Getenv("JWT_SECRET_KEY")
+		if secret == "" && conf.Env == "production" {
+			return errors.New("either jwt-signing-key or JWT_SECRET_KEY must be set in production")
+		}
+		auth.SetKeySet(auth.NewHMACKeySet([]byte(secret)))
+		return nil
+	}
+
+	var verificationKeys []string
+	for _, file := range strings.Split(conf.JWT.VerificationKeys, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			verificationKeys = append(verificationKeys, file)
+		}
+	}
+
+	ks, err := auth.LoadKeySet(conf.JWT.SigningKey, verificationKeys...)
+	if err != nil {
+		return fmt.Errorf("failed to load jwt keys: %w", err)
+	}
+
+	auth.SetKeySet(ks)
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/Kostaaa1/tinylink/internal/infra/oauthprovider"
+)
+
+// builtinProviders are used when OAUTH_<NAME>_TYPE and OAUTH_<NAME>_ISSUER are not set.
+var builtinProviders = map[string]struct{ kind, issuer string }{
+	"google": {"oidc", "https://accounts.google.com"},
+	"gitlab": {"oidc", "https://gitlab.com"},
+	"github": {"github", ""},
+}
+
+// newOAuthRegistry builds the login providers listed in oauth-providers. Each provider is configured with OAUTH_<NAME>_CLIENT_ID,
+// OAUTH_<NAME>_CLIENT_SECRET and optionally OAUTH_<NAME>_TYPE (oidc|github), OAUTH_<NAME>_ISSUER, OAUTH_<NAME>_SCOPES and
+// OAUTH_<NAME>_REDIRECT_URL. Google also accepts the older GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET and GOOGLE_CALLBACK_URL.
+func newOAuthRegistry(conf Config) (*oauth.Registry, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var providers []oauth.Provider
+
+	for _, name := range strings.Split(conf.OAuthProviders, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		env := func(key string) string { return os.Getenv(prefix + key) }
+
+		clientID, clientSecret, redirectURL := env("CLIENT_ID"), env("CLIENT_SECRET"), env("REDIRECT_URL")
+		if name == "google" {
+			clientID = cmp.Or(clientID, os.Getenv("GOOGLE_CLIENT_ID"))
+			clientSecret = cmp.Or(clientSecret, os.Getenv("GOOGLE_CLIENT_SECRET"))
+			redirectURL = cmp.Or(redirectURL, os.Getenv("GOOGLE_CALLBACK_URL"))
+		}
+		if clientID == "" {
+			return nil, fmt.Errorf("oauth provider %s: %sCLIENT_ID must be set", name, prefix)
+		}
+		if redirectURL == "" {
+			redirectURL = strings.TrimSuffix(conf.AppURL, "/") + "/auth/" + name + "/callback"
+		}
+
+		var scopes []string
+		if s := env("SCOPES"); s != "" {
+			scopes = strings.Fields(strings.ReplaceAll(s, ",", " "))
+		}
+
+		builtin := builtinProviders[name]
+		kind := cmp.Or(env("TYPE"), builtin.kind)
+
+		switch kind {
+		case "oidc":
+			issuer := cmp.Or(env("ISSUER"), builtin.issuer)
+			if issuer == "" {
+				return nil, fmt.Errorf("oauth provider %s: %sISSUER must be set", name, prefix)
+			}
+			providers = append(providers, oauthprovider.NewOIDC(oauthprovider.OIDCConfig{
+				Name:         name,
+				Issuer:       issuer,
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       scopes,
+			}, client))
+		case "github":
+			providers = append(providers, oauthprovider.NewGitHub(oauthprovider.GitHubConfig{
+				Name:         name,
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       scopes,
+			}, client))
+		default:
+			return nil, fmt.Errorf("oauth provider %s: unknown type %q, set %sTYPE to oidc or github", name, kind, prefix)
+		}
+	}
+
+	return oauth.NewRegistry(providers...), nil
+}
+
+func defaultOAuthProviders() string {
+	if os.Getenv("GOOGLE_CLIENT_ID") != "" {
+		return "google"
+	}
+	return ""
+}
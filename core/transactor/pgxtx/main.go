@@ -2,10 +2,38 @@ package pgxtx
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+type txKey struct{}
+
+// Querier is implemented by both *pgxpool.Pool and pgx.Tx. Repositories use it so the same query runs inside the ambient transaction if there is one.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+var (
+	_ Querier = (*pgxpool.Pool)(nil)
+	_ Querier = (pgx.Tx)(nil)
+)
+
+// Conn returns the transaction stored in ctx, or the pool if ctx is not part of a transaction.
+func Conn(ctx context.Context, pool *pgxpool.Pool) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return pool
+}
+
 type pgxtxQuerier struct {
 	db *pgxpool.Pool
 }
@@ -14,6 +42,30 @@ func New(db *pgxpool.Pool) *pgxtxQuerier {
 	return &pgxtxQuerier{db: db}
 }
 
-func (p *pgxtxQuerier) WithTx(ctx context.Context, txFunc func(ctx context.Context) error) error {
+// WithTx runs txFunc inside a transaction which is stored in the context passed to txFunc. If ctx already carries a transaction, a savepoint is created instead, so only the nested part is rolled back when txFunc fails.
+func (p *pgxtxQuerier) WithTx(ctx context.Context, txFunc func(ctx context.Context) error) (err error) {
+	tx, err := Conn(ctx, p.db).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback(ctx)
+			panic(r)
+		}
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = txFunc(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,8 @@
+package account
+
+type DeleteAccountRequest struct {
+	// Password is required if the account has one
+	Password string `json:"password"`
+	// Code is a TOTP or recovery code, required if two-factor authentication is enabled
+	Code string `json:"code"`
+}
@@ -0,0 +1,64 @@
+package account
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+
+	tinylinkHandler "github.com/Kostaaa1/tinylink/internal/api/tinylink"
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+)
+
+var clicksCSVHeader = []string{"alias", "day", "clicks", "unique_visitors"}
+
+// writeArchive writes profile.json, links.jsonl and clicks.csv to zw. Links use the format accepted by /tinylink/import.
+func writeArchive(ctx context.Context, zw *zip.Writer, service *account.Service, profile *account.Profile) error {
+	userID := profile.ID
+
+	f, err := zw.Create("profile.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(profile); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("links.jsonl")
+	if err != nil {
+		return err
+	}
+	enc = json.NewEncoder(f)
+	err = service.Links(ctx, userID, func(tl *tinylink.Tinylink) error {
+		return enc.Encode(tinylinkHandler.NewExportRecord(tl))
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err = zw.Create("clicks.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write(clicksCSVHeader); err != nil {
+		return err
+	}
+	err = service.DailyClicks(ctx, userID, func(c *account.DailyClicks) error {
+		return cw.Write([]string{
+			c.Alias,
+			c.Day.Format("2006-01-02"),
+			strconv.FormatInt(c.Clicks, 10),
+			strconv.FormatInt(c.UniqueVisitors, 10),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
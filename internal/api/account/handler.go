@@ -0,0 +1,143 @@
+package account
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/gorilla/mux"
+)
+
+type AccountHandler struct {
+	errhandler.ErrorHandler
+	service *account.Service
+	log     *slog.Logger
+}
+
+func NewAccountHandler(service *account.Service, errHandler errhandler.ErrorHandler, log *slog.Logger) AccountHandler {
+	return AccountHandler{
+		ErrorHandler: errHandler,
+		service:      service,
+		log:          log,
+	}
+}
+
+// RegisterRoutes registers account deletion and export. Deletion checks the password and two-factor code, so it shares the login rate limit.
+func (h AccountHandler) RegisterRoutes(r *mux.Router, protected, loginLimit mux.MiddlewareFunc) {
+	r.Handle("/user", protected(loginLimit(http.HandlerFunc(h.Delete)))).Methods("DELETE")
+	r.Handle("/user/export", protected(http.HandlerFunc(h.Export))).Methods("GET")
+}
+
+// Delete account
+// @Summary Delete account
+// @Description Permanently deletes the account after confirming with the password and, if enabled, a two-factor code. Accounts with neither must have logged in within the last 10 minutes.
+// @Description Links are deleted, anonymized or transferred depending on server configuration. Sessions and API keys are revoked.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body DeleteAccountRequest true "password and two-factor code"
+// @Success 200 {object} jsonutil.Response
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Failure 429 {object} jsonutil.Response
+// @Router /user [delete]
+func (h AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	refreshToken, _ := auth.RefreshTokenFromCookie(r)
+	err := h.service.Delete(r.Context(), *userCtx.UserID, user.Reauth{
+		Password:     req.Password,
+		Code:         req.Code,
+		RefreshToken: refreshToken,
+		IP:           iputil.ClientIP(r),
+	})
+	if err != nil {
+		var locked *user.LockedError
+		switch {
+		case errors.Is(err, user.ErrInvalidCredentials):
+			h.InvalidCredentialsResponse(w, r)
+		case errors.Is(err, user.ErrInvalidTwoFactorCode), errors.Is(err, user.ErrReauthenticationRequired):
+			h.ErrorResponse(w, r, http.StatusUnauthorized, err.Error())
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			h.ErrorResponse(w, r, http.StatusTooManyRequests, locked.Error())
+		case errors.Is(err, account.ErrTransferAccount):
+			h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, constants.ErrNotFound):
+			h.NotFoundResponse(w, r)
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	auth.ClearRefreshToken(w)
+
+	if err := jsonutil.Write(w, http.StatusOK, "account deleted", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// Export account data
+// @Summary Export account data
+// @Description Downloads a zip archive with profile.json, links.jsonl (importable via /tinylink/import) and clicks.csv with daily click statistics of every link.
+// @Tags User
+// @Produce application/zip
+// @Success 200 {file} file
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/export [get]
+func (h AccountHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	profile, err := h.service.Profile(r.Context(), *userCtx.UserID)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			h.NotFoundResponse(w, r)
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("tinylink-account-%s.zip", time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// the archive is streamed, once the first entry is written errors can only be logged and the client gets a truncated zip
+	zw := zip.NewWriter(w)
+	if err := writeArchive(r.Context(), zw, h.service, profile); err != nil {
+		h.log.Error("account export failed", "error", err, "user_id", *userCtx.UserID)
+		return
+	}
+
+	if err := zw.Close(); err != nil {
+		h.log.Error("account export failed", "error", err, "user_id", *userCtx.UserID)
+	}
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/admin"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+type TakeDownRequest struct {
+	Reason string `json:"reason"`
+}
+
+// parseUserQuery reads search, disabled, limit and offset query params.
+func parseUserQuery(v *validator.Validator, q url.Values) admin.UserQuery {
+	query := admin.UserQuery{Search: q.Get("search")}
+	v.Check(len(query.Search) <= 254, "search", "must not be more than 254 bytes long")
+
+	if s := q.Get("disabled"); s != "" {
+		disabled, err := strconv.ParseBool(s)
+		v.Check(err == nil, "disabled", "must be a boolean")
+		query.Disabled = &disabled
+	}
+
+	query.Limit, query.Offset = parsePage(v, q)
+	return query
+}
+
+func parsePage(v *validator.Validator, q url.Values) (limit, offset int) {
+	limit = admin.DefaultPageSize
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		v.Check(err == nil && n > 0 && n <= admin.MaxPageSize, "limit", "must be between 1 and "+strconv.Itoa(admin.MaxPageSize))
+		limit = n
+	}
+
+	if s := q.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		v.Check(err == nil && n >= 0, "offset", "must be a non-negative integer")
+		offset = n
+	}
+
+	return limit, offset
+}
+
+func parseID(s string) (uint64, bool) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	return id, err == nil && id > 0
+}
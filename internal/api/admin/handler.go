@@ -0,0 +1,276 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/admin"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+	"github.com/gorilla/mux"
+)
+
+type AdminHandler struct {
+	errhandler.ErrorHandler
+	service *admin.Service
+	log     *slog.Logger
+}
+
+func NewAdminHandler(service *admin.Service, errHandler errhandler.ErrorHandler, log *slog.Logger) AdminHandler {
+	return AdminHandler{
+		ErrorHandler: errHandler,
+		service:      service,
+		log:          log,
+	}
+}
+
+// RegisterRoutes registers /admin routes. requireAdminMW must reject users without the admin role.
+func (h AdminHandler) RegisterRoutes(r *mux.Router, requireAuthMW, requireAdminMW mux.MiddlewareFunc) {
+	adminRoutes := r.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(requireAuthMW, requireAdminMW)
+
+	adminRoutes.HandleFunc("/users", h.ListUsers).Methods("GET")
+	adminRoutes.HandleFunc("/users/{id}", h.GetUser).Methods("GET")
+	adminRoutes.HandleFunc("/users/{id}", h.DeleteUser).Methods("DELETE")
+	adminRoutes.HandleFunc("/users/{id}/disable", h.DisableUser).Methods("POST")
+	adminRoutes.HandleFunc("/users/{id}/enable", h.EnableUser).Methods("POST")
+	adminRoutes.HandleFunc("/users/{id}/revoke-tokens", h.RevokeTokens).Methods("POST")
+
+	adminRoutes.HandleFunc("/tinylinks/{id}", h.GetLink).Methods("GET")
+	adminRoutes.HandleFunc("/tinylinks/{id}", h.TakeDownLink).Methods("DELETE")
+	adminRoutes.HandleFunc("/tinylinks/{id}/expire", h.ExpireLink).Methods("POST")
+
+	adminRoutes.HandleFunc("/audit", h.AuditLog).Methods("GET")
+}
+
+// ListUsers lists and searches users
+// @Summary List users
+// @Description Lists users ordered by id. Requires admin role.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param search query string false "substring of email or name"
+// @Param disabled query bool false "filter by disabled state"
+// @Param limit query int false "page size"
+// @Param offset query int false "number of users to skip"
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Failure 403 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Router /admin/users [get]
+func (h AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	query := parseUserQuery(v, r.URL.Query())
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, err := h.service.ListUsers(r.Context(), query)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"users": users}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseID(mux.Vars(r)["id"])
+	if !ok {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	u, err := h.service.GetUser(r.Context(), userID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"user": u}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.DisableUser, "user disabled")
+}
+
+func (h AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.EnableUser, "user enabled")
+}
+
+// DeleteUser deletes the account and all of its links
+// @Summary Delete user
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "user id"
+// @Success 200 {object} jsonutil.Response
+// @Failure 403 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Router /admin/users/{id} [delete]
+func (h AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.DeleteUser, "user deleted")
+}
+
+func (h AdminHandler) RevokeTokens(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.RevokeTokens, "refresh tokens revoked")
+}
+
+func (h AdminHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	linkID, ok := parseID(mux.Vars(r)["id"])
+	if !ok {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	tl, err := h.service.GetLink(r.Context(), linkID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"tinylink": tl}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ExpireLink expires the tinylink immediately
+// @Summary Force-expire tinylink
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "tinylink id"
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 404 {object} jsonutil.Response
+// @Router /admin/tinylinks/{id}/expire [post]
+func (h AdminHandler) ExpireLink(w http.ResponseWriter, r *http.Request) {
+	linkID, ok := parseID(mux.Vars(r)["id"])
+	if !ok {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	tl, err := h.service.ExpireLink(r.Context(), h.adminID(r), linkID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"tinylink": tl}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// TakeDownLink removes an abusive tinylink
+// @Summary Take down tinylink
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "tinylink id"
+// @Param request body TakeDownRequest false "reason recorded in the audit log"
+// @Success 200 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Router /admin/tinylinks/{id} [delete]
+func (h AdminHandler) TakeDownLink(w http.ResponseWriter, r *http.Request) {
+	linkID, ok := parseID(mux.Vars(r)["id"])
+	if !ok {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	var req TakeDownRequest
+	if r.ContentLength > 0 {
+		if err := jsonutil.Read(r, &req); err != nil {
+			h.BadRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+	v.Check(len(req.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := h.service.TakeDownLink(r.Context(), h.adminID(r), linkID, req.Reason); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "tinylink taken down", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// AuditLog lists admin actions, newest first
+// @Summary Admin audit log
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "page size"
+// @Param offset query int false "number of entries to skip"
+// @Success 200 {object} jsonutil.Envelope
+// @Router /admin/audit [get]
+func (h AdminHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	limit, offset := parsePage(v, r.URL.Query())
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, err := h.service.AuditLog(r.Context(), limit, offset)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"entries": entries}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h AdminHandler) userAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, adminID, userID uint64) error, msg string) {
+	userID, ok := parseID(mux.Vars(r)["id"])
+	if !ok {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	if err := action(r.Context(), h.adminID(r), userID); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, msg, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// adminID is always set, routes are protected by auth and admin middlewares
+func (h AdminHandler) adminID(r *http.Request) uint64 {
+	return *auth.FromContext(r.Context()).UserID
+}
+
+func (h AdminHandler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, constants.ErrNotFound):
+		h.NotFoundResponse(w, r)
+	case errors.Is(err, admin.ErrSelfAction):
+		h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+	default:
+		h.ServerErrorResponse(w, r, err)
+	}
+}
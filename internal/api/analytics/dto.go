@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+const (
+	defaultStatsRange = 30 * 24 * time.Hour
+	// hourly series are limited so a single response stays reasonably small
+	maxHourlyRange = 31 * 24 * time.Hour
+)
+
+// parseStatsQuery reads from, to (RFC3339 or YYYY-MM-DD) and interval (hour|day|month) query params. Defaults to the last 30 days with daily interval.
+func parseStatsQuery(v *validator.Validator, q url.Values, now time.Time) analytics.StatsQuery {
+	query := analytics.StatsQuery{
+		To: now,
+	}
+
+	if s := q.Get("to"); s != "" {
+		t, ok := parseTime(s)
+		v.Check(ok, "to", "must be RFC3339 timestamp or YYYY-MM-DD date")
+		query.To = t
+	}
+
+	query.From = query.To.Add(-defaultStatsRange)
+	if s := q.Get("from"); s != "" {
+		t, ok := parseTime(s)
+		v.Check(ok, "from", "must be RFC3339 timestamp or YYYY-MM-DD date")
+		query.From = t
+	}
+
+	interval, err := analytics.ParseInterval(q.Get("interval"))
+	v.Check(err == nil, "interval", "must be one of hour, day, month")
+	query.Interval = interval
+
+	v.Check(!query.From.After(query.To), "from", "must be before to")
+	if interval == analytics.IntervalHour {
+		v.Check(query.To.Sub(query.From) <= maxHourlyRange, "interval", "hourly series are limited to 31 days")
+	}
+
+	return query
+}
+
+func parseTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
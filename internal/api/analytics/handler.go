@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+	"github.com/gorilla/mux"
+)
+
+type AnalyticsHandler struct {
+	errhandler.ErrorHandler
+	service *analytics.Service
+	log     *slog.Logger
+}
+
+func NewAnalyticsHandler(service *analytics.Service, errHandler errhandler.ErrorHandler, log *slog.Logger) AnalyticsHandler {
+	return AnalyticsHandler{
+		ErrorHandler: errHandler,
+		service:      service,
+		log:          log,
+	}
+}
+
+func (h AnalyticsHandler) RegisterRoutes(r *mux.Router, protected, apiKeyScope mux.MiddlewareFunc) {
+	protectedTL := r.PathPrefix("/tinylink").Subrouter()
+	protectedTL.Use(apiKeyScope, protected)
+	protectedTL.HandleFunc("/{alias}/stats", h.Stats).Methods("GET")
+}
+
+// Stats for a single Tinylink
+// @Summary Tinylink statistics
+// @Description Returns total clicks, unique visitors, click series and top referrers, countries and devices for the tinylink owned by the authenticated user.
+// @Tags Tinylink
+// @Security ApiKeyAuth
+// @Produce json
+// @Param alias path string true "tinylink alias"
+// @Param from query string false "start of the range (RFC3339 or YYYY-MM-DD), defaults to 30 days before to"
+// @Param to query string false "end of the range (RFC3339 or YYYY-MM-DD), defaults to now"
+// @Param interval query string false "series interval (hour|day|month), defaults to day"
+// @Success 200 {object} analytics.Stats
+// @Failure 401 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Failure 500 {object} jsonutil.Response
+// @Router /tinylink/{alias}/stats [get]
+func (h AnalyticsHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	alias := mux.Vars(r)["alias"]
+
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	query := parseStatsQuery(v, r.URL.Query(), time.Now())
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*5)
+	defer cancel()
+
+	stats, err := h.service.Stats(ctx, *userCtx.UserID, alias, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, constants.ErrNotFound):
+			h.NotFoundResponse(w, r)
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, stats, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,31 @@
+package apikey
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional, keys without it never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (r CreateAPIKeyRequest) Validate(v *validator.Validator) {
+	v.Check(strings.TrimSpace(r.Name) != "", "name", "must be provided")
+	v.Check(len(r.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(apikey.ValidScopes(r.Scopes), "scopes", "must contain at least one of: "+strings.Join(apikey.Scopes, ", "))
+	if r.ExpiresAt != nil {
+		v.Check(r.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+}
+
+type CreateAPIKeyResponse struct {
+	*apikey.APIKey
+	// Key is shown only once, it cannot be retrieved later
+	Key string `json:"key"`
+}
@@ -0,0 +1,146 @@
+package apikey
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	errhandler.ErrorHandler
+	service *apikey.Service
+	log     *slog.Logger
+}
+
+func NewAPIKeyHandler(service *apikey.Service, errHandler errhandler.ErrorHandler, log *slog.Logger) APIKeyHandler {
+	return APIKeyHandler{
+		ErrorHandler: errHandler,
+		service:      service,
+		log:          log,
+	}
+}
+
+// RegisterRoutes registers /user/api-keys routes. Keys can be managed only with a user session, never with another API key.
+func (h APIKeyHandler) RegisterRoutes(r *mux.Router, protected mux.MiddlewareFunc) {
+	keyRoutes := r.PathPrefix("/user/api-keys").Subrouter()
+	keyRoutes.Use(protected)
+	keyRoutes.HandleFunc("", h.Create).Methods("POST")
+	keyRoutes.HandleFunc("", h.List).Methods("GET")
+	keyRoutes.HandleFunc("/{id}", h.Revoke).Methods("DELETE")
+}
+
+// Create API key
+// @Summary Create API key
+// @Description Creates a personal API key. The key is returned only in this response. Use it in "Authorization: ApiKey <key>" or "X-API-Key" header.
+// @Tags User
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateAPIKeyRequest true "key name, scopes and optional expiration"
+// @Success 201 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Router /user/api-keys [post]
+func (h APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if req.Validate(v); !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userCtx := auth.FromContext(r.Context())
+
+	key, plain, err := h.service.Create(r.Context(), *userCtx.UserID, apikey.CreateParams{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrTooManyKeys):
+			h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, apikey.ErrInvalidScope):
+			h.FailedValidationResponse(w, r, map[string]string{"scopes": err.Error()})
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	resp := CreateAPIKeyResponse{APIKey: key, Key: plain}
+	if err := jsonutil.Write(w, http.StatusCreated, jsonutil.Envelope{"api_key": resp}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// List API keys
+// @Summary List API keys
+// @Description Lists API keys of the authenticated user. Secrets are never returned, keys are identified by prefix.
+// @Tags User
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/api-keys [get]
+func (h APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+
+	keys, err := h.service.List(r.Context(), *userCtx.UserID)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"api_keys": keys}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// Revoke API key
+// @Summary Revoke API key
+// @Tags User
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "api key id"
+// @Success 200 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Router /user/api-keys/{id} [delete]
+func (h APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	userCtx := auth.FromContext(r.Context())
+
+	if err := h.service.Revoke(r.Context(), *userCtx.UserID, keyID); err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			h.NotFoundResponse(w, r)
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "api key revoked", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,137 @@
+package tinylink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+)
+
+const maxBulkBodySize = 32 << 20
+
+var (
+	errUnsupportedBulkFormat = errors.New("unsupported content type, use application/json, text/csv or multipart/form-data with a file field")
+	errMissingURLColumn      = errors.New("csv header must contain url column")
+)
+
+// readBulkRequests reads the bulk payload as a JSON array or CSV, either from the raw body or from the "file" field of a multipart form.
+func readBulkRequests(w http.ResponseWriter, r *http.Request) ([]CreateTinylinkRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkBodySize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/json":
+		return decodeJSONRequests(r.Body)
+	case "text/csv":
+		return decodeCSVRequests(r.Body)
+	case "multipart/form-data":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		defer file.Close()
+
+		if strings.EqualFold(filepath.Ext(header.Filename), ".json") {
+			return decodeJSONRequests(file)
+		}
+		return decodeCSVRequests(file)
+	default:
+		return nil, errUnsupportedBulkFormat
+	}
+}
+
+func decodeJSONRequests(r io.Reader) ([]CreateTinylinkRequest, error) {
+	var reqs []CreateTinylinkRequest
+	if err := json.NewDecoder(r).Decode(&reqs); err != nil {
+		return nil, fmt.Errorf("body must be a JSON array of tinylinks: %w", err)
+	}
+	if len(reqs) > tinylink.MaxBulkItems {
+		return nil, tinylink.ErrTooManyItems
+	}
+	return reqs, nil
+}
+
+// decodeCSVRequests expects a header row. Supported columns are url (required), alias, domain, private, expires_at (or expiration) and expires_in.
+func decodeCSVRequests(r io.Reader) ([]CreateTinylinkRequest, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["url"]; !ok {
+		return nil, errMissingURLColumn
+	}
+
+	field := func(record []string, name string) (string, bool) {
+		i, ok := cols[name]
+		if !ok || i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(record[i]), true
+	}
+
+	reqs := make([]CreateTinylinkRequest, 0)
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		if len(reqs) >= tinylink.MaxBulkItems {
+			return nil, tinylink.ErrTooManyItems
+		}
+
+		var req CreateTinylinkRequest
+		req.URL, _ = field(record, "url")
+		if alias, ok := field(record, "alias"); ok {
+			req.Alias = &alias
+		}
+		if domain, ok := field(record, "domain"); ok {
+			req.Domain = &domain
+		}
+		if priv, ok := field(record, "private"); ok {
+			req.Private, _ = strconv.ParseBool(priv)
+		}
+		exp, ok := field(record, "expires_at")
+		if !ok {
+			// export archives name the column expiration
+			exp, ok = field(record, "expiration")
+		}
+		if ok {
+			if t, err := time.Parse(time.RFC3339, exp); err == nil {
+				req.ExpiresAt = &t
+			} else {
+				// keep the invalid value visible to validation instead of silently dropping it
+				req.ExpiresIn = &exp
+			}
+		}
+		if exp, ok := field(record, "expires_in"); ok {
+			req.ExpiresIn = &exp
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
@@ -3,7 +3,10 @@ package tinylink
 import (
 	"net/url"
 	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
 	"github.com/Kostaaa1/tinylink/pkg/validator"
 )
 
@@ -11,17 +14,60 @@ var (
 	aliasRx = regexp.MustCompile("[a-zA-Z0-9]")
 )
 
+// Expiration can be provided either as an absolute time (expires_at, RFC3339) or as a duration relative to now (expires_in, e.g. "72h").
+type Expiration struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn *string    `json:"expires_in,omitempty"`
+}
+
+func (e Expiration) Validate(v *validator.Validator) {
+	v.Check(e.ExpiresAt == nil || e.ExpiresIn == nil, "expires_at", "provide either expires_at or expires_in, not both")
+	if e.ExpiresAt != nil {
+		v.Check(e.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+	if e.ExpiresIn != nil {
+		d, err := time.ParseDuration(*e.ExpiresIn)
+		v.Check(err == nil, "expires_in", "must be a valid duration (e.g. 30m, 24h)")
+		v.Check(err != nil || d > 0, "expires_in", "must be positive")
+	}
+}
+
+// Time resolves the expiration to an absolute time. Should be called only after Validate.
+func (e Expiration) Time(now time.Time) *time.Time {
+	if e.ExpiresAt != nil {
+		return e.ExpiresAt
+	}
+	if e.ExpiresIn != nil {
+		d, err := time.ParseDuration(*e.ExpiresIn)
+		if err != nil {
+			return nil
+		}
+		t := now.Add(d)
+		return &t
+	}
+	return nil
+}
+
 type CreateTinylinkRequest struct {
 	URL     string  `json:"url"`
 	Alias   *string `json:"alias"`
 	Domain  *string `json:"domain,omitempty"`
 	Private bool    `json:"private"`
+	Expiration
+	// imported requests come from an export archive and may carry an expiration that has already passed
+	imported bool
 }
 
 func (r CreateTinylinkRequest) Validate(v *validator.Validator) error {
+	v.Check(r.URL != "", "url", "must be provided")
 	_, err := url.Parse(r.URL)
 	v.Check(err == nil, "url", "malformed url (parsing failed)")
-	v.Check(aliasRx.MatchString(*r.Alias), "alias", "wrong format - use letters and numbers for aliases")
+	if r.Alias != nil {
+		v.Check(aliasRx.MatchString(*r.Alias), "alias", "wrong format - use letters and numbers for aliases")
+	}
+	if !r.imported {
+		r.Expiration.Validate(v)
+	}
 	return nil
 }
 
@@ -33,11 +79,59 @@ type UpdateTinylinkRequest struct {
 	Alias   *string `json:"alias"`
 	Domain  *string `json:"domain,omitempty"`
 	Private bool    `json:"private"`
+	Expiration
+	// ClearExpiration removes the current expiration, omitting expires_at and expires_in keeps it
+	ClearExpiration bool `json:"clear_expiration,omitempty"`
 }
 
 func (r UpdateTinylinkRequest) Validate(v *validator.Validator) error {
-	_, err := url.Parse(*r.URL)
-	v.Check(err == nil, "url", "malformed url (parsing failed)")
-	v.Check(aliasRx.MatchString(*r.Alias), "alias", "wrong format - use letters and numbers for aliases")
+	if r.URL != nil {
+		_, err := url.Parse(*r.URL)
+		v.Check(err == nil, "url", "malformed url (parsing failed)")
+	}
+	if r.Alias != nil {
+		v.Check(aliasRx.MatchString(*r.Alias), "alias", "wrong format - use letters and numbers for aliases")
+	}
+	r.Expiration.Validate(v)
+	v.Check(!r.ClearExpiration || (r.ExpiresAt == nil && r.ExpiresIn == nil), "clear_expiration", "cannot be combined with expires_at or expires_in")
 	return nil
 }
+
+// parseListParams reads pagination, filter and sort query params of GET /tinylink/list.
+func parseListParams(v *validator.Validator, q url.Values) tinylink.ListParams {
+	var params tinylink.ListParams
+
+	sort, err := tinylink.ParseSort(q.Get("sort"))
+	v.Check(err == nil, "sort", "must be one of created_at, -created_at, alias, -alias")
+	params.Sort = sort
+
+	if err == nil {
+		params.Cursor, err = tinylink.DecodeCursor(q.Get("cursor"), sort)
+		v.Check(err == nil, "cursor", "invalid or does not match sort")
+	}
+
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		v.Check(err == nil && limit > 0 && limit <= tinylink.MaxPageSize, "limit", "must be between 1 and 100")
+		params.Limit = limit
+	}
+
+	if s := q.Get("domain"); s != "" {
+		params.Filter.Domain = &s
+	}
+	params.Filter.Private = parseBoolParam(v, q, "private")
+	params.Filter.Expired = parseBoolParam(v, q, "expired")
+	params.Filter.Search = q.Get("q")
+
+	return params
+}
+
+func parseBoolParam(v *validator.Validator, q url.Values, key string) *bool {
+	s := q.Get(key)
+	if s == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	v.Check(err == nil, key, "must be true or false")
+	return &b
+}
@@ -0,0 +1,253 @@
+package tinylink
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+)
+
+type exportFormat string
+
+const (
+	formatCSV   exportFormat = "csv"
+	formatJSONL exportFormat = "jsonl"
+)
+
+var (
+	errUnsupportedExportFormat = errors.New("format must be csv or jsonl")
+	exportCSVHeader            = []string{"alias", "url", "domain", "private", "expiration", "version", "created_at"}
+)
+
+// ExportRecord is a single line of the export archive. The same shape is accepted by the import endpoint.
+type ExportRecord struct {
+	Alias      string     `json:"alias"`
+	URL        string     `json:"url"`
+	Domain     string     `json:"domain,omitempty"`
+	Private    bool       `json:"private"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	Version    uint64     `json:"version"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func NewExportRecord(tl *tinylink.Tinylink) ExportRecord {
+	return ExportRecord{
+		Alias:      tl.Alias,
+		URL:        tl.URL,
+		Domain:     tl.Domain,
+		Private:    tl.Private,
+		Expiration: tl.Expiration,
+		Version:    tl.Version,
+		CreatedAt:  tl.CreatedAt,
+	}
+}
+
+// createRequest maps the record to a create request. Links that expired after the export are imported as already expired, so a backup restores every row.
+func (rec ExportRecord) createRequest() CreateTinylinkRequest {
+	req := CreateTinylinkRequest{
+		URL:      rec.URL,
+		Private:  rec.Private,
+		imported: true,
+	}
+	req.ExpiresAt = rec.Expiration
+	if rec.Alias != "" {
+		req.Alias = &rec.Alias
+	}
+	if rec.Domain != "" {
+		req.Domain = &rec.Domain
+	}
+	return req
+}
+
+func parseExportFormat(s string) (exportFormat, error) {
+	switch f := exportFormat(strings.ToLower(s)); f {
+	case formatCSV, formatJSONL:
+		return f, nil
+	case "":
+		return formatCSV, nil
+	}
+	return "", errUnsupportedExportFormat
+}
+
+func (f exportFormat) contentType() string {
+	if f == formatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// exportWriter writes records in the chosen format, flushing buffered output every flushEvery records so large exports are streamed to the client.
+type exportWriter struct {
+	format  exportFormat
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher http.Flusher
+	count   int
+}
+
+const flushEvery = 500
+
+func newExportWriter(w http.ResponseWriter, format exportFormat) (*exportWriter, error) {
+	ew := &exportWriter{format: format, buf: bufio.NewWriter(w)}
+	ew.flusher, _ = w.(http.Flusher)
+
+	switch format {
+	case formatJSONL:
+		ew.json = json.NewEncoder(ew.buf)
+	default:
+		ew.csv = csv.NewWriter(ew.buf)
+		if err := ew.csv.Write(exportCSVHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	return ew, nil
+}
+
+func (ew *exportWriter) Write(tl *tinylink.Tinylink) error {
+	rec := NewExportRecord(tl)
+
+	var err error
+	switch ew.format {
+	case formatJSONL:
+		err = ew.json.Encode(rec)
+	default:
+		var expiration string
+		if rec.Expiration != nil {
+			expiration = rec.Expiration.Format(time.RFC3339)
+		}
+		err = ew.csv.Write([]string{
+			rec.Alias,
+			rec.URL,
+			rec.Domain,
+			strconv.FormatBool(rec.Private),
+			expiration,
+			strconv.FormatUint(rec.Version, 10),
+			rec.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	ew.count++
+	if ew.count%flushEvery == 0 {
+		return ew.Flush()
+	}
+	return nil
+}
+
+func (ew *exportWriter) Flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := ew.buf.Flush(); err != nil {
+		return err
+	}
+	if ew.flusher != nil {
+		ew.flusher.Flush()
+	}
+	return nil
+}
+
+// readImportRecords reads an export archive (csv or jsonl) from the raw body or from the "file" field of a multipart form. The format is taken from the format query param, the file extension or the content type, in that order.
+func readImportRecords(w http.ResponseWriter, r *http.Request) ([]ExportRecord, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkBodySize)
+
+	var body io.Reader = r.Body
+	format := exportFormat(strings.ToLower(r.URL.Query().Get("format")))
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = exportFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), "."))
+		}
+	}
+
+	if format == "" {
+		switch mediaType {
+		case "application/x-ndjson", "application/jsonl":
+			format = formatJSONL
+		default:
+			format = formatCSV
+		}
+	}
+
+	switch format {
+	case formatJSONL:
+		return decodeJSONLRecords(body)
+	case formatCSV:
+		return decodeCSVRecords(body)
+	default:
+		return nil, errUnsupportedExportFormat
+	}
+}
+
+func decodeJSONLRecords(r io.Reader) ([]ExportRecord, error) {
+	dec := json.NewDecoder(r)
+	records := make([]ExportRecord, 0)
+
+	for {
+		var rec ExportRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode line %d: %w", len(records)+1, err)
+		}
+		if len(records) >= tinylink.MaxBulkItems {
+			return nil, tinylink.ErrTooManyItems
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func decodeCSVRecords(r io.Reader) ([]ExportRecord, error) {
+	reqs, err := decodeCSVRequests(r)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]ExportRecord, len(reqs))
+	for i, req := range reqs {
+		if req.ExpiresIn != nil {
+			return nil, fmt.Errorf("row %d: expiration must be RFC3339 timestamp", i+1)
+		}
+		records[i] = ExportRecord{
+			URL:        req.URL,
+			Private:    req.Private,
+			Expiration: req.ExpiresAt,
+		}
+		if req.Alias != nil {
+			records[i].Alias = *req.Alias
+		}
+		if req.Domain != nil {
+			records[i].Domain = *req.Domain
+		}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,65 @@
+package tinylink
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportArchiveRoundTrip(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	past := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	links := []*tinylink.Tinylink{
+		{Alias: "abc", URL: "https://a.com/?q=1,2", Domain: "a.com", Private: true, Expiration: &exp, Version: 3, CreatedAt: exp.AddDate(-6, 0, 0)},
+		{Alias: "xyz", URL: "https://b.com", Version: 1, CreatedAt: exp.AddDate(-6, 0, 0)},
+		{Alias: "old", URL: "https://c.com", Expiration: &past, Version: 1, CreatedAt: past.AddDate(-1, 0, 0)},
+	}
+
+	for _, format := range []exportFormat{formatCSV, formatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ew, err := newExportWriter(rec, format)
+			require.NoError(t, err)
+			for _, tl := range links {
+				require.NoError(t, ew.Write(tl))
+			}
+			require.NoError(t, ew.Flush())
+
+			var records []ExportRecord
+			switch format {
+			case formatCSV:
+				records, err = decodeCSVRecords(bytes.NewReader(rec.Body.Bytes()))
+			case formatJSONL:
+				records, err = decodeJSONLRecords(bytes.NewReader(rec.Body.Bytes()))
+			}
+			require.NoError(t, err)
+			require.Len(t, records, len(links))
+
+			for i, tl := range links {
+				require.Equal(t, tl.Alias, records[i].Alias)
+				require.Equal(t, tl.URL, records[i].URL)
+				require.Equal(t, tl.Domain, records[i].Domain)
+				require.Equal(t, tl.Private, records[i].Private)
+				if tl.Expiration == nil {
+					require.Nil(t, records[i].Expiration)
+				} else {
+					require.True(t, tl.Expiration.Equal(*records[i].Expiration))
+				}
+
+				// expired links are imported as already expired instead of failing validation
+				v := validator.New()
+				req := records[i].createRequest()
+				require.NoError(t, req.Validate(v))
+				require.True(t, v.Valid(), v.Errors)
+				if tl.Expiration != nil {
+					require.True(t, tl.Expiration.Equal(*req.Time(time.Now())))
+				}
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Kostaaa1/tinylink/internal/domain/auth"
 	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
 	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
 	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
 	"github.com/Kostaaa1/tinylink/pkg/validator"
 	"github.com/gorilla/mux"
@@ -32,46 +33,239 @@ func NewTinylinkHandler(service *tinylink.Service, errHandler errhandler.ErrorHa
 	}
 }
 
-func (h TinylinkHandler) RegisterRoutes(r *mux.Router, protected mux.MiddlewareFunc) {
+// apiKeyScope lets API keys with link scopes use the routes, it has to run before protected. redirectLimit and createLimit are rate limits of redirects and link creation.
+func (h TinylinkHandler) RegisterRoutes(r *mux.Router, protected, apiKeyScope, redirectLimit, createLimit mux.MiddlewareFunc) {
 	// protectedRoute.HandleFunc("/p/{alias:[a-zA-Z0-9]+}", h.Redirect).Methods("GET")
 	protectedTL := r.PathPrefix("/tinylink").Subrouter()
-	protectedTL.Use(protected)
-	protectedTL.HandleFunc("/bulk-insert", h.BulkInsert).Methods("POST")
+	protectedTL.Use(apiKeyScope, protected)
+	protectedTL.Handle("/bulk-insert", createLimit(http.HandlerFunc(h.BulkInsert))).Methods("POST")
 	protectedTL.HandleFunc("/{alias}", h.Delete).Methods("DELETE")
 	protectedTL.HandleFunc("", h.Update).Methods("PATCH")
 	protectedTL.HandleFunc("/list", h.List).Methods("GET")
-	r.HandleFunc("/{alias:[a-zA-Z0-9]+}", h.Redirect).Methods("GET")
-	r.HandleFunc("/tinylink/create", h.Create).Methods("POST")
+	protectedTL.HandleFunc("/export", h.Export).Methods("GET")
+	protectedTL.Handle("/import", createLimit(http.HandlerFunc(h.Import))).Methods("POST")
+	r.Handle("/{alias:[a-zA-Z0-9]+}", redirectLimit(apiKeyScope(http.HandlerFunc(h.Redirect)))).Methods("GET")
+	r.Handle("/tinylink/create", createLimit(apiKeyScope(http.HandlerFunc(h.Create)))).Methods("POST")
 }
 
+// Bulk insert Tinylinks
+// @Summary Bulk insert Tinylinks
+// @Description Creates up to 50000 Tinylinks from a JSON array or CSV (raw body or multipart "file" field). Each row is reported separately, invalid or conflicting rows do not fail the batch.
+// @Tags Tinylink
+// @Security ApiKeyAuth
+// @Accept json
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Success 200 {array} tinylink.BulkResult
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 403 {object} jsonutil.Response
+// @Failure 413 {object} jsonutil.Response
+// @Failure 500 {object} jsonutil.Response
+// @Router /tinylink/bulk-insert [post]
 func (h TinylinkHandler) BulkInsert(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("bulk insert")
-	fmt.Fprint(w, "bulk insert")
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	reqs, err := readBulkRequests(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr), errors.Is(err, tinylink.ErrTooManyItems):
+			h.ErrorResponse(w, r, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			h.BadRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	h.bulkCreate(w, r, *userCtx.UserID, userCtx.GuestUUID, reqs)
+}
+
+// bulkCreate validates every request and inserts the valid ones, responding with one result per input row.
+func (h TinylinkHandler) bulkCreate(w http.ResponseWriter, r *http.Request, userID uint64, guestUUID string, reqs []CreateTinylinkRequest) {
+	now := time.Now()
+	results := make([]tinylink.BulkResult, len(reqs))
+	items := make([]tinylink.BulkItem, 0, len(reqs))
+
+	for i, req := range reqs {
+		row := i + 1
+		results[i].Row = row
+		if req.Alias != nil {
+			results[i].Alias = *req.Alias
+		}
+
+		v := validator.New()
+		if _ = req.Validate(v); !v.Valid() {
+			results[i].Errors = v.Errors
+			continue
+		}
+
+		items = append(items, tinylink.BulkItem{
+			Row: row,
+			Params: tinylink.CreateTinylinkParams{
+				URL:        req.URL,
+				Alias:      req.Alias,
+				Domain:     req.Domain,
+				Private:    req.Private,
+				Expiration: req.Expiration.Time(now),
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second*25)
+	defer cancel()
+
+	created, err := h.service.BulkCreate(ctx, userID, guestUUID, items)
+	if err != nil {
+		if errors.Is(err, tinylink.ErrEmailNotVerified) {
+			h.ErrorResponse(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	for _, res := range created {
+		results[res.Row-1] = res
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, results, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// Export all Tinylinks of the user
+// @Summary Export Tinylinks
+// @Description Streams every Tinylink of the authenticated user as CSV or JSON Lines. The output can be imported back with /tinylink/import.
+// @Tags Tinylink
+// @Security ApiKeyAuth
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv (default) or jsonl"
+// @Success 200 {array} ExportRecord
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Router /tinylink/export [get]
+func (h TinylinkHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	format, err := parseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("tinylinks-%s.%s", time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Type", format.contentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	ew, err := newExportWriter(w, format)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	// the status is already sent once the first row is written, so errors can only be logged
+	if err := h.service.Export(r.Context(), *userCtx.UserID, ew.Write); err != nil {
+		h.log.Error("tinylink export failed", "error", err, "user_id", *userCtx.UserID)
+		return
+	}
+
+	if err := ew.Flush(); err != nil {
+		h.log.Error("tinylink export failed", "error", err, "user_id", *userCtx.UserID)
+	}
+}
+
+// Import Tinylinks from an export archive
+// @Summary Import Tinylinks
+// @Description Imports an archive produced by /tinylink/export (csv or jsonl, raw body or multipart "file" field). Conflicting aliases are reported per row, links whose expiration has passed are imported as already expired.
+// @Tags Tinylink
+// @Security ApiKeyAuth
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Accept multipart/form-data
+// @Produce json
+// @Param format query string false "csv or jsonl, detected from file extension or content type if omitted"
+// @Success 200 {array} tinylink.BulkResult
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 403 {object} jsonutil.Response
+// @Failure 413 {object} jsonutil.Response
+// @Router /tinylink/import [post]
+func (h TinylinkHandler) Import(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	records, err := readImportRecords(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr), errors.Is(err, tinylink.ErrTooManyItems):
+			h.ErrorResponse(w, r, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			h.BadRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	reqs := make([]CreateTinylinkRequest, len(records))
+	for i, rec := range records {
+		reqs[i] = rec.createRequest()
+	}
+
+	h.bulkCreate(w, r, *userCtx.UserID, userCtx.GuestUUID, reqs)
 }
 
 // List all Tinylinks for user/guest
 // @Summary List Tinylinks
-// @Description Retrieves a list of Tinylinks for the authenticated user. This is protected route. Access tokey is needed
+// @Description Retrieves a page of Tinylinks for the authenticated user. This is protected route. Access tokey is needed
 // @Tags Tinylink
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} tinylink.Tinylink
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "page size (1-100), defaults to 20"
+// @Param sort query string false "created_at, -created_at (default), alias or -alias"
+// @Param domain query string false "filter by domain"
+// @Param private query bool false "filter by private flag"
+// @Param expired query bool false "filter expired or active links"
+// @Param q query string false "alias or url substring"
+// @Success 200 {object} tinylink.Page
 // @Failure 401 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
 // @Failure 500 {object} jsonutil.Response
 // @Router /tinylink/list [get]
 func (h TinylinkHandler) List(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	params := parseListParams(v, r.URL.Query())
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*3)
 	defer cancel()
 
 	userCtx := auth.FromContext(ctx)
 
-	links, err := h.service.List(ctx, userCtx)
+	page, err := h.service.List(ctx, userCtx, params)
 	if err != nil {
 		h.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	if err := jsonutil.Write(w, http.StatusOK, links, nil); err != nil {
+	if err := jsonutil.Write(w, http.StatusOK, page, nil); err != nil {
 		h.ServerErrorResponse(w, r, err)
 	}
 }
@@ -96,12 +290,14 @@ func (h TinylinkHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	params := tinylink.UpdateTinylinkParams{
-		ID:      req.ID,
-		URL:     req.URL,
-		Alias:   req.Alias,
-		Domain:  req.Domain,
-		Private: req.Private,
-		UserID:  *userCtx.UserID,
+		ID:              req.ID,
+		URL:             req.URL,
+		Alias:           req.Alias,
+		Domain:          req.Domain,
+		Private:         req.Private,
+		Expiration:      req.Expiration.Time(time.Now()),
+		ClearExpiration: req.ClearExpiration,
+		UserID:          *userCtx.UserID,
 	}
 
 	tl, err := h.service.Update(r.Context(), params)
@@ -111,6 +307,8 @@ func (h TinylinkHandler) Update(w http.ResponseWriter, r *http.Request) {
 			h.NotFoundResponse(w, r)
 		case errors.Is(err, tinylink.ErrAliasExists):
 			h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, tinylink.ErrEmailNotVerified):
+			h.ErrorResponse(w, r, http.StatusForbidden, err.Error())
 		default:
 			h.ServerErrorResponse(w, r, err)
 		}
@@ -136,22 +334,19 @@ func (h TinylinkHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sig := auth.GetUserSignature(r)
-	if sig.UserID == nil && sig.GuestUUID == nil {
-		h.UnauthorizedResponse(w, r)
-		return
-	}
+	userCtx := auth.FromContext(r.Context())
 
 	ctx, cancel := context.WithTimeout(r.Context(), time.Second*3)
 	defer cancel()
 
 	params := tinylink.CreateTinylinkParams{
-		URL:       req.URL,
-		Alias:     req.Alias,
-		Domain:    req.Domain,
-		Private:   req.Private,
-		UserID:    sig.UserID,
-		GuestUUID: *sig.GuestUUID,
+		URL:        req.URL,
+		Alias:      req.Alias,
+		Domain:     req.Domain,
+		Private:    req.Private,
+		Expiration: req.Expiration.Time(time.Now()),
+		UserID:     userCtx.UserID,
+		GuestUUID:  userCtx.GuestUUID,
 	}
 
 	tl, err := h.service.Create(ctx, params)
@@ -161,6 +356,8 @@ func (h TinylinkHandler) Create(w http.ResponseWriter, r *http.Request) {
 			h.UnauthorizedResponse(w, r)
 		case errors.Is(err, tinylink.ErrAliasExists):
 			h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, tinylink.ErrEmailNotVerified):
+			h.ErrorResponse(w, r, http.StatusForbidden, err.Error())
 		default:
 			h.ServerErrorResponse(w, r, err)
 		}
@@ -180,13 +377,22 @@ func (h TinylinkHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 	var url string
 
 	// isPrivateRoute := strings.HasPrefix(r.URL.Path, "/p/")
-	claims, _ := auth.ClaimsFromRequest(r)
+	userID := auth.FromContext(r.Context()).UserID
+
+	visitor := tinylink.Visitor{
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        iputil.ClientIP(r),
+		Country:   iputil.Country(r),
+	}
 
-	_, url, err = h.service.Redirect(r.Context(), &claims.UserID, alias)
+	_, url, err = h.service.Redirect(r.Context(), userID, alias, visitor)
 	if err != nil {
 		switch {
 		case errors.Is(err, constants.ErrNotFound):
 			h.NotFoundResponse(w, r)
+		case errors.Is(err, tinylink.ErrLinkExpired):
+			h.GoneResponse(w, r)
 		default:
 			h.ServerErrorResponse(w, r, err)
 		}
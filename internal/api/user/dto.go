@@ -20,60 +20,60 @@ type UserLoginRequest struct {
 
 func UserResponse(user *user.User) UserDTO {
 	dto := UserDTO{
-		ID:        user.ID,
-		Email:     user.Email,
-		Name:      user.Name,
-		CreatedAt: user.CreatedAt,
-	}
-
-	if user.Google != nil {
-		dto.Google = &GoogleUserDTO{
-			ID:            user.Google.ID,
-			Name:          user.Google.Name,
-			Picture:       user.Google.Picture,
-			FamilyName:    user.Google.FamilyName,
-			GivenName:     user.Google.GivenName,
-			VerifiedEmail: user.Google.VerifiedEmail,
-			CreatedAt:     user.Google.CreatedAt,
-		}
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified(),
+		Name:          user.Name,
+		CreatedAt:     user.CreatedAt,
+		Roles:         user.Roles,
+		Version:       user.Version,
 	}
 
 	return dto
 }
 
 type UserDTO struct {
-	ID        uint64         `json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	Name      string         `json:"name"`
-	Email     string         `json:"email"`
-	Google    *GoogleUserDTO `json:"google,omitempty"`
+	ID            uint64    `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Roles         []string  `json:"roles,omitempty"`
+	// Version is passed back on profile updates to detect concurrent changes
+	Version int `json:"version"`
 }
 
-type GoogleUserDTO struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	GivenName     string    `json:"given_name"`
-	FamilyName    string    `json:"family_name"`
-	Picture       string    `json:"picture"`
-	VerifiedEmail bool      `json:"is_verified"`
-	CreatedAt     time.Time `json:"created_at"`
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
 }
 
-type Role int
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
 
-const (
-	Admin Role = iota
-	Subscriber
-	User
-	Guest
-)
+type CompleteLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type LinkIdentityRequest struct {
+	Provider string `json:"provider"`
+}
 
-// type Permission int
-// const (
-// 	Read Permission = iota
-// 	Create
-// 	Update
-// 	Delete
-// 	BulkInsert
-// 	Metrics
-// )
+type ConfirmLinkRequest struct {
+	LinkToken string `json:"link_token"`
+}
+
+type UpdateProfileRequest struct {
+	Name    *string `json:"name"`
+	Email   *string `json:"email"`
+	Version *int    `json:"version"`
+	// Password and Code confirm a change of email. Code is a TOTP or recovery code, required if two-factor authentication is enabled
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
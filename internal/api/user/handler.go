@@ -1,65 +1,93 @@
 package api
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 
 	"github.com/Kostaaa1/tinylink/internal/constants"
 	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
 	"github.com/Kostaaa1/tinylink/internal/domain/user"
 	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
 	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
 	"github.com/Kostaaa1/tinylink/pkg/validator"
 	"github.com/gorilla/mux"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-)
-
-var (
-	oauth2State = "random-state-idk"
 )
 
 type UserHandler struct {
 	errhandler.ErrorHandler
-	userService  *user.Service
-	oauth2Config *oauth2.Config
-	log          *slog.Logger
+	userService   *user.Service
+	resetService  *user.PasswordResetService
+	verifyService *user.EmailVerificationService
+	twoFactor     *user.TwoFactorService
+	tokenService  *token.Service
+	oauthService  *oauth.Service
+	log           *slog.Logger
 }
 
-func NewUserHandler(userService *user.Service, errHandler errhandler.ErrorHandler, log *slog.Logger) UserHandler {
+func NewUserHandler(
+	userService *user.Service,
+	resetService *user.PasswordResetService,
+	verifyService *user.EmailVerificationService,
+	twoFactor *user.TwoFactorService,
+	tokenService *token.Service,
+	oauthService *oauth.Service,
+	errHandler errhandler.ErrorHandler,
+	log *slog.Logger,
+) UserHandler {
 	return UserHandler{
-		ErrorHandler: errHandler,
-		userService:  userService,
-		log:          log,
-		oauth2Config: &oauth2.Config{
-			ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-			RedirectURL:  os.Getenv("GOOGLE_CALLBACK_URL"),
-			Scopes:       []string{"profile", "email"},
-			Endpoint:     google.Endpoint,
-		},
+		ErrorHandler:  errHandler,
+		userService:   userService,
+		resetService:  resetService,
+		verifyService: verifyService,
+		twoFactor:     twoFactor,
+		tokenService:  tokenService,
+		oauthService:  oauthService,
+		log:           log,
 	}
 }
 
-func (h UserHandler) RegisterRoutes(r *mux.Router, requireAuthMW mux.MiddlewareFunc) {
-	r.HandleFunc("/login/google", h.HandleGoogleRedirect).Methods("GET")
-	r.HandleFunc("/auth/google/callback", h.HandleGoogleCallback).Methods("GET")
+// loginLimit is the rate limit of endpoints that accept credentials or two-factor codes, resendLimit throttles verification emails.
+func (h UserHandler) RegisterRoutes(r *mux.Router, requireAuthMW, loginLimit, resendLimit mux.MiddlewareFunc) {
+	r.HandleFunc("/auth/providers", h.ListProviders).Methods("GET")
+	r.HandleFunc("/login/{provider}", h.ExternalLoginRedirect).Methods("GET")
+	r.Handle("/auth/{provider}/callback", loginLimit(http.HandlerFunc(h.ExternalLoginCallback))).Methods("GET")
+	r.HandleFunc("/csrf-token", h.CSRFToken).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", h.JWKS).Methods("GET")
 
 	userRoutes := r.PathPrefix("/user").Subrouter()
-	userRoutes.HandleFunc("/register", h.Register).Methods("POST")
-	userRoutes.HandleFunc("/login", h.Login).Methods("POST")
+	userRoutes.Handle("/register", loginLimit(http.HandlerFunc(h.Register))).Methods("POST")
+	userRoutes.Handle("/login", loginLimit(http.HandlerFunc(h.Login))).Methods("POST")
+	userRoutes.Handle("/login/2fa", loginLimit(http.HandlerFunc(h.CompleteLogin))).Methods("POST")
+	userRoutes.HandleFunc("/refresh", h.Refresh).Methods("POST")
+	userRoutes.Handle("/password/forgot", loginLimit(http.HandlerFunc(h.ForgotPassword))).Methods("POST")
+	userRoutes.Handle("/password/reset", loginLimit(http.HandlerFunc(h.ResetPassword))).Methods("POST")
+	userRoutes.HandleFunc("/verify", h.VerifyEmail).Methods("GET")
 
 	protected := r.PathPrefix("/user").Subrouter()
 	protected.Use(requireAuthMW)
+	protected.HandleFunc("/me", h.Me).Methods("GET")
+	protected.Handle("/me", loginLimit(http.HandlerFunc(h.UpdateMe))).Methods("PATCH")
 	protected.HandleFunc("/change-password", h.ChangePassword).Methods("PATCH")
 	protected.HandleFunc("/logout", h.Logout).Methods("POST")
-
-	// TESTING ONLY - REMOVE LATER
-	// protected.HandleFunc("/refresh-token", h.HandleRefreshToken).Methods("GET")
+	protected.HandleFunc("/sessions", h.ListSessions).Methods("GET")
+	protected.HandleFunc("/sessions", h.RevokeAllSessions).Methods("DELETE")
+	protected.HandleFunc("/sessions/{id}", h.RevokeSession).Methods("DELETE")
+	protected.Handle("/verify/resend", resendLimit(http.HandlerFunc(h.ResendVerification))).Methods("POST")
+	protected.HandleFunc("/2fa", h.TwoFactorStatus).Methods("GET")
+	protected.Handle("/2fa", loginLimit(http.HandlerFunc(h.DisableTwoFactor))).Methods("DELETE")
+	protected.HandleFunc("/2fa/enroll", h.EnrollTwoFactor).Methods("POST")
+	protected.Handle("/2fa/confirm", loginLimit(http.HandlerFunc(h.ConfirmTwoFactor))).Methods("POST")
+	protected.Handle("/2fa/recovery-codes", loginLimit(http.HandlerFunc(h.RegenerateRecoveryCodes))).Methods("POST")
+	protected.HandleFunc("/identities", h.ListIdentities).Methods("GET")
+	protected.HandleFunc("/identities", h.StartLinkIdentity).Methods("POST")
+	protected.HandleFunc("/identities/confirm", h.ConfirmLinkIdentity).Methods("POST")
+	protected.HandleFunc("/identities/{id:[0-9]+}", h.UnlinkIdentity).Methods("DELETE")
 }
 
 func (h UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -69,7 +97,8 @@ func (h UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.userService.Logout(r.Context(), *userCtx.UserID); err != nil {
+	refreshToken, _ := auth.RefreshTokenFromCookie(r)
+	if err := h.userService.Logout(r.Context(), *userCtx.UserID, refreshToken); err != nil {
 		h.ServerErrorResponse(w, r, err)
 		return
 	}
@@ -104,11 +133,16 @@ func (h UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.userService.ChangePassword(r.Context(), *userCtx.UserID, input.OldPassword, input.NewPassword); err != nil {
-		if errors.Is(err, constants.ErrNotFound) {
+		switch {
+		case errors.Is(err, constants.ErrNotFound):
 			h.NotFoundResponse(w, r)
-			return
+		case errors.Is(err, user.ErrEditConflict), errors.Is(err, user.ErrNoUserPasswordSet):
+			h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, user.ErrInvalidCredentials):
+			h.InvalidCredentialsResponse(w, r)
+		default:
+			h.ServerErrorResponse(w, r, err)
 		}
-		h.ServerErrorResponse(w, r, err)
 		return
 	}
 
@@ -118,59 +152,6 @@ func (h UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h UserHandler) HandleGoogleRedirect(w http.ResponseWriter, r *http.Request) {
-	// TODO:
-	url := h.oauth2Config.AuthCodeURL(oauth2State, oauth2.AccessTypeOnline)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
-}
-
-func (h UserHandler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
-	googleToken, err := h.oauth2Config.Exchange(r.Context(), code)
-	if err != nil {
-		h.ServerErrorResponse(w, r, fmt.Errorf("failed to exchange token: %v", err))
-		return
-	}
-
-	client := h.oauth2Config.Client(r.Context(), googleToken)
-
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		h.BadRequestResponse(w, r, fmt.Errorf("failed to fetch user google data: %v", err))
-		return
-	}
-	defer resp.Body.Close()
-
-	var googleUser user.GoogleUser
-	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
-		h.ServerErrorResponse(w, r, fmt.Errorf("failed to read JSON: %v", err))
-		return
-	}
-
-	loggedUser, err := h.userService.HandleGoogleLogin(r.Context(), &googleUser)
-	if err != nil {
-		h.ServerErrorResponse(w, r, err)
-		return
-	}
-
-	token, err := auth.GenerateAccessToken(loggedUser.ID, nil)
-	if err != nil {
-		h.ServerErrorResponse(w, r, err)
-		return
-	}
-
-	w.Header().Set("Authorization", "Bearer "+token)
-
-	response := jsonutil.Envelope{
-		"user":  UserResponse(loggedUser),
-		"token": token,
-	}
-
-	if err := jsonutil.Write(w, http.StatusOK, response, nil); err != nil {
-		h.ServerErrorResponse(w, r, err)
-	}
-}
-
 func (h UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var input UserLoginRequest
 	if err := jsonutil.Read(r, &input); err != nil {
@@ -186,26 +167,30 @@ func (h UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	loggedUser, accessToken, refreshToken, err := h.userService.Login(r.Context(), input.Email, input.Password)
+	loggedUser, accessToken, refreshToken, err := h.userService.Login(r.Context(), input.Email, input.Password, clientFromRequest(r))
 	if err != nil {
-		switch {
-		case errors.Is(err, user.ErrInvalidCredentials):
-			h.InvalidCredentialsResponse(w, r)
-		case errors.Is(err, constants.ErrNotFound):
-			h.NotFoundResponse(w, r)
-		case errors.Is(err, user.ErrNoUserPasswordSet):
-			h.BadRequestResponse(w, r, user.ErrNoUserPasswordSet)
-		default:
-			h.ServerErrorResponse(w, r, err)
-		}
+		h.loginErrorResponse(w, r, err)
+		return
+	}
+
+	h.loginResponse(w, r, loggedUser, accessToken, refreshToken)
+}
+
+// loginResponse starts the session of the authenticated user and responds with the tokens.
+func (h UserHandler) loginResponse(w http.ResponseWriter, r *http.Request, loggedUser *user.User, accessToken, refreshToken string) {
+	sess, err := h.rotateSession(r)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
 		return
 	}
 
 	auth.SetTokens(w, refreshToken, accessToken)
 
 	resp := jsonutil.Envelope{
-		"user":  UserResponse(loggedUser),
-		"token": accessToken,
+		"user":          UserResponse(loggedUser),
+		"token":         accessToken,
+		"csrf_token":    sess.CSRF,
+		"claimed_links": h.claimGuestLinks(r, loggedUser.ID),
 	}
 
 	if err := jsonutil.Write(w, http.StatusOK, resp, nil); err != nil {
@@ -213,6 +198,34 @@ func (h UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// loginErrorResponse maps errors of both login steps. A required second step is not an error for the client, it gets the challenge token instead of tokens.
+func (h UserHandler) loginErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var locked *user.LockedError
+	var challenge *user.TwoFactorRequiredError
+	switch {
+	case errors.As(err, &challenge):
+		resp := jsonutil.Envelope{
+			"two_factor_required": true,
+			"challenge_token":     challenge.ChallengeToken,
+			"expires_at":          challenge.ExpiresAt,
+		}
+		if err := jsonutil.Write(w, http.StatusOK, resp, nil); err != nil {
+			h.ServerErrorResponse(w, r, err)
+		}
+	case errors.Is(err, user.ErrInvalidCredentials):
+		h.InvalidCredentialsResponse(w, r)
+	case errors.Is(err, user.ErrInvalidTwoFactorCode), errors.Is(err, user.ErrInvalidChallenge):
+		h.ErrorResponse(w, r, http.StatusUnauthorized, err.Error())
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		h.ErrorResponse(w, r, http.StatusTooManyRequests, locked.Error())
+	case errors.Is(err, user.ErrAccountDisabled):
+		h.ErrorResponse(w, r, http.StatusForbidden, err.Error())
+	default:
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
 func (h UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req UserRegisterRequest
 	if err := jsonutil.Read(r, &req); err != nil {
@@ -249,9 +262,64 @@ func (h UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := jsonutil.Envelope{"user": UserResponse(userData)}
+	h.sendVerification(r, userData)
+
+	resp := jsonutil.Envelope{
+		"user":          UserResponse(userData),
+		"claimed_links": h.claimGuestLinks(r, userData.ID),
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, resp, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// claimGuestLinks moves links the visitor created as a guest into the account. Failure to claim does not fail authentication, so errors are only logged.
+func (h UserHandler) claimGuestLinks(r *http.Request, userID uint64) *user.ClaimResult {
+	guestUUID := auth.FromContext(r.Context()).GuestUUID
+
+	res, err := h.userService.ClaimGuestLinks(r.Context(), guestUUID, userID)
+	if err != nil {
+		h.log.Error("failed to claim guest links", "error", err, "user_id", userID, "guest_uuid", guestUUID)
+		return nil
+	}
+
+	return res
+}
+
+// CSRFToken returns the CSRF token of the visitor session, creating the session if needed
+// @Summary Get CSRF token
+// @Description Returns the CSRF token that must be sent in the X-XSRF-Token header (or csrf_token form value) with every POST, PUT, PATCH and DELETE request.
+// @Tags User
+// @Produce json
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 500 {object} jsonutil.Response
+// @Router /csrf-token [get]
+func (h UserHandler) CSRFToken(w http.ResponseWriter, r *http.Request) {
+	guestUUID := auth.FromContext(r.Context()).GuestUUID
+
+	sess, err := h.tokenService.EnsureSession(r.Context(), guestUUID, iputil.ClientIP(r), r.UserAgent())
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	resp := jsonutil.Envelope{
+		"csrf_token": sess.CSRF,
+		"expires_at": sess.ExpiresAt,
+	}
 
 	if err := jsonutil.Write(w, http.StatusOK, resp, nil); err != nil {
 		h.ServerErrorResponse(w, r, err)
 	}
 }
+
+func (h UserHandler) rotateSession(r *http.Request) (*token.Session, error) {
+	guestUUID := auth.FromContext(r.Context()).GuestUUID
+	return h.tokenService.RotateSession(r.Context(), guestUUID, iputil.ClientIP(r), r.UserAgent())
+}
+
+// clientFromRequest identifies the device. The IP keys the per-IP lockout, so it comes from iputil.ClientIP which ignores forwarding headers of untrusted clients.
+func clientFromRequest(r *http.Request) token.Client {
+	return token.Client{IP: iputil.ClientIP(r), UserAgent: r.UserAgent()}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/gorilla/mux"
+)
+
+// ListProviders returns the configured external login providers
+// @Summary List login providers
+// @Tags User
+// @Produce json
+// @Success 200 {object} jsonutil.Response
+// @Router /auth/providers [get]
+func (h UserHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"providers": h.oauthService.Providers()}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ExternalLoginRedirect redirects to the consent page of the provider
+// @Summary Login with an external provider
+// @Description Starts the authorization code flow with PKCE. The state is stored server-side and bound to the guest cookie of the browser.
+// @Tags User
+// @Param provider path string true "provider name, e.g. google"
+// @Success 307
+// @Failure 404 {object} jsonutil.Response
+// @Router /login/{provider} [get]
+func (h UserHandler) ExternalLoginRedirect(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	guestUUID := auth.FromContext(r.Context()).GuestUUID
+
+	url, err := h.oauthService.AuthURL(r.Context(), provider, guestUUID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrUnknownProvider) {
+			h.NotFoundResponse(w, r)
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+}
+
+// ExternalLoginCallback completes the login with the provider
+// @Summary External login callback
+// @Description Redeems the authorization code, validates the ID token and logs in the linked user. A new user is created on first login. If the email belongs to an existing account, it responds 409 with a link token the account owner confirms at /user/identities/confirm. Flows started at POST /user/identities link the identity instead of logging in.
+// @Tags User
+// @Produce json
+// @Param provider path string true "provider name"
+// @Param state query string true "state returned by the provider"
+// @Param code query string true "authorization code"
+// @Success 200 {object} jsonutil.Response
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Router /auth/{provider}/callback [get]
+func (h UserHandler) ExternalLoginCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		h.ErrorResponse(w, r, http.StatusBadRequest, "login was cancelled or denied by the provider: "+e)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	guestUUID := auth.FromContext(r.Context()).GuestUUID
+
+	profile, err := h.oauthService.Exchange(r.Context(), provider, query.Get("state"), query.Get("code"), guestUUID)
+	if err != nil {
+		switch {
+		case errors.Is(err, oauth.ErrUnknownProvider):
+			h.NotFoundResponse(w, r)
+		case errors.Is(err, oauth.ErrInvalidState), errors.Is(err, oauth.ErrExchangeFailed):
+			h.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, oauth.ErrInvalidIDToken):
+			h.ErrorResponse(w, r, http.StatusUnauthorized, oauth.ErrInvalidIDToken.Error())
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	identity := &user.Identity{
+		Provider:      profile.Provider,
+		Subject:       profile.Subject,
+		Email:         profile.Email,
+		EmailVerified: profile.EmailVerified,
+		Name:          profile.Name,
+		Picture:       profile.Picture,
+	}
+
+	if profile.LinkUserID != nil {
+		if err := h.userService.LinkIdentity(r.Context(), *profile.LinkUserID, identity); err != nil {
+			h.identityErrorResponse(w, r, err)
+			return
+		}
+		if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"identity": identity}, nil); err != nil {
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	loggedUser, accessToken, refreshToken, err := h.userService.ExternalLogin(r.Context(), identity, clientFromRequest(r))
+	if err != nil {
+		var linkRequired *user.LinkRequiredError
+		switch {
+		case errors.As(err, &linkRequired):
+			resp := jsonutil.Envelope{
+				"error":         linkRequired.Error(),
+				"link_required": true,
+				"link_token":    linkRequired.LinkToken,
+				"provider":      linkRequired.Provider,
+				"email":         linkRequired.Email,
+				"expires_at":    linkRequired.ExpiresAt,
+			}
+			if err := jsonutil.Write(w, http.StatusConflict, resp, nil); err != nil {
+				h.ServerErrorResponse(w, r, err)
+			}
+		case errors.Is(err, user.ErrIdentityEmailRequired):
+			h.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		default:
+			h.loginErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	h.loginResponse(w, r, loggedUser, accessToken, refreshToken)
+}
+
+// ListIdentities returns the providers linked to the account
+// @Summary List linked identities
+// @Description Returns the external identities linked to the authenticated user and whether the user has a password.
+// @Tags User
+// @Produce json
+// @Success 200 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/identities [get]
+func (h UserHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	identities, err := h.userService.Identities(r.Context(), *userCtx.UserID)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, identities, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// StartLinkIdentity starts linking a provider to the account
+// @Summary Link a provider
+// @Description Returns the URL of the provider's consent page. After the user consents, the provider callback links the identity to the authenticated user instead of logging in.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param body body LinkIdentityRequest true "provider"
+// @Success 200 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Router /user/identities [post]
+func (h UserHandler) StartLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input LinkIdentityRequest
+	if err := jsonutil.Read(r, &input); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	url, err := h.oauthService.LinkURL(r.Context(), input.Provider, userCtx.GuestUUID, *userCtx.UserID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrUnknownProvider) {
+			h.NotFoundResponse(w, r)
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"url": url}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ConfirmLinkIdentity links the identity of an external login that matched the account's email
+// @Summary Confirm linking a provider
+// @Description Links the identity kept under the link token returned by the provider callback. The token is bound to the account with the same email, so the user has to log in to it first.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param body body ConfirmLinkRequest true "link token"
+// @Success 200 {object} jsonutil.Response
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Router /user/identities/confirm [post]
+func (h UserHandler) ConfirmLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	var input ConfirmLinkRequest
+	if err := jsonutil.Read(r, &input); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	identity, err := h.userService.ConfirmLink(r.Context(), *userCtx.UserID, input.LinkToken)
+	if err != nil {
+		h.identityErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"identity": identity}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// UnlinkIdentity removes a linked provider from the account
+// @Summary Unlink a provider
+// @Description Fails if the identity is the only way to log in, the user needs a password or another linked provider.
+// @Tags User
+// @Produce json
+// @Param id path int true "identity id"
+// @Success 200 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Router /user/identities/{id} [delete]
+func (h UserHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.NotFoundResponse(w, r)
+		return
+	}
+
+	if err := h.userService.UnlinkIdentity(r.Context(), *userCtx.UserID, id); err != nil {
+		h.identityErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "identity unlinked", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h UserHandler) identityErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, constants.ErrNotFound):
+		h.NotFoundResponse(w, r)
+	case errors.Is(err, user.ErrInvalidLinkToken):
+		h.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, user.ErrIdentityConflict), errors.Is(err, user.ErrLastLoginMethod):
+		h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+	default:
+		h.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+// ForgotPassword sends a password reset email
+// @Summary Request password reset
+// @Description Sends a single-use reset link if an account with the email exists. The response is the same whether it exists or not.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "account email"
+// @Success 202 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Failure 429 {object} jsonutil.Response
+// @Router /user/password/forgot [post]
+func (h UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if user.ValidateEmail(v, req.Email); !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := h.resetService.Forgot(r.Context(), req.Email); err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusAccepted, "if the account exists, a password reset email has been sent", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ResetPassword sets a new password using the emailed token
+// @Summary Reset password
+// @Description Sets a new password with the token from the reset email. All sessions of the user are logged out.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "reset token and new password"
+// @Success 200 {object} jsonutil.Response
+// @Failure 400 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Router /user/password/reset [post]
+func (h UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.Token != "", "token", "must be provided")
+	user.ValidatePasswordPlainText(v, req.Password)
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := h.resetService.Reset(r.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, user.ErrInvalidResetToken) {
+			h.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "password has been reset", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
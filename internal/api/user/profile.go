@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+// Me returns the current user
+// @Summary Get current user
+// @Tags User
+// @Produce json
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/me [get]
+func (h UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	userData, err := h.userService.Me(r.Context(), *userCtx.UserID)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			h.NotFoundResponse(w, r)
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"user": UserResponse(userData)}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// UpdateMe changes name and email of the current user
+// @Summary Update current user
+// @Description Omitted fields are left unchanged. Pass the version from /user/me to reject the update if the user changed in the meantime.
+// @Description A new email requires the password and, if enabled, a two-factor code. It stays unverified until the link sent to it is followed.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body UpdateProfileRequest true "fields to change"
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Failure 429 {object} jsonutil.Response
+// @Router /user/me [patch]
+func (h UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	refreshToken, _ := auth.RefreshTokenFromCookie(r)
+	params := user.ProfileUpdate{
+		Name:    req.Name,
+		Email:   req.Email,
+		Version: req.Version,
+		Reauth: user.Reauth{
+			Password:     req.Password,
+			Code:         req.Code,
+			RefreshToken: refreshToken,
+			IP:           iputil.ClientIP(r),
+		},
+	}
+
+	v := validator.New()
+	if user.ValidateProfileUpdate(v, params); !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userData, emailChanged, err := h.userService.UpdateProfile(r.Context(), *userCtx.UserID, params)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+
+	if emailChanged {
+		h.sendVerification(r, userData)
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"user": UserResponse(userData)}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h UserHandler) profileErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var locked *user.LockedError
+	switch {
+	case errors.Is(err, user.ErrDuplicateEmail):
+		h.ErrorResponse(w, r, http.StatusConflict, "user already exists with this email address")
+	case errors.Is(err, user.ErrEditConflict):
+		h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, user.ErrInvalidCredentials):
+		h.InvalidCredentialsResponse(w, r)
+	case errors.Is(err, user.ErrInvalidTwoFactorCode), errors.Is(err, user.ErrReauthenticationRequired):
+		h.ErrorResponse(w, r, http.StatusUnauthorized, err.Error())
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		h.ErrorResponse(w, r, http.StatusTooManyRequests, locked.Error())
+	case errors.Is(err, constants.ErrNotFound):
+		h.NotFoundResponse(w, r)
+	default:
+		h.ServerErrorResponse(w, r, err)
+	}
+}
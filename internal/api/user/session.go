@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/gorilla/mux"
+)
+
+type SessionDTO struct {
+	*token.Family
+	Current bool `json:"current"`
+}
+
+// ListSessions lists devices the user is logged in on
+// @Summary List active sessions
+// @Description Lists refresh token families of the user, one per logged in device, most recently used first.
+// @Tags User
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/sessions [get]
+func (h UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	families, err := h.tokenService.ListSessions(r.Context(), *userCtx.UserID)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	var currentID string
+	if refreshToken, err := auth.RefreshTokenFromCookie(r); err == nil {
+		currentID, _ = token.FamilyID(refreshToken)
+	}
+
+	sessions := make([]SessionDTO, len(families))
+	for i, fam := range families {
+		sessions[i] = SessionDTO{Family: fam, Current: fam.ID == currentID}
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"sessions": sessions}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// RevokeSession logs the user out on a single device
+// @Summary Revoke session
+// @Tags User
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path string true "session id"
+// @Success 200 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 404 {object} jsonutil.Response
+// @Router /user/sessions/{id} [delete]
+func (h UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	familyID := mux.Vars(r)["id"]
+
+	if err := h.tokenService.RevokeSession(r.Context(), *userCtx.UserID, familyID); err != nil {
+		switch {
+		case errors.Is(err, constants.ErrNotFound):
+			h.NotFoundResponse(w, r)
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if refreshToken, err := auth.RefreshTokenFromCookie(r); err == nil {
+		if currentID, _ := token.FamilyID(refreshToken); currentID == familyID {
+			auth.ClearRefreshToken(w)
+		}
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "session revoked", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// RevokeAllSessions logs the user out on all devices
+// @Summary Revoke all sessions
+// @Tags User
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/sessions [delete]
+func (h UserHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	if err := h.tokenService.RevokeAll(r.Context(), *userCtx.UserID); err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	auth.ClearRefreshToken(w)
+
+	if err := jsonutil.Write(w, http.StatusOK, "all sessions revoked", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// Refresh rotates the refresh token from the cookie and issues a new access token
+// @Summary Refresh tokens
+// @Description Does not require an access token. The new access token is returned in the body and the Authorization header, the rotated refresh token replaces the cookie. Reusing an already rotated refresh token revokes the whole session.
+// @Tags User
+// @Produce json
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Failure 500 {object} jsonutil.Response
+// @Router /user/refresh [post]
+func (h UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	refreshToken, err := auth.RefreshTokenFromCookie(r)
+	if err != nil {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(r.Context(), refreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrTokenNotValid),
+			errors.Is(err, constants.ErrNotFound),
+			errors.Is(err, token.ErrTokenReused):
+			auth.ClearRefreshToken(w)
+			h.UnauthorizedResponse(w, r)
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	auth.SetTokens(w, tokens.RefreshToken, tokens.AccessToken)
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"token": tokens.AccessToken}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// JWKS publishes public keys used for verifying access tokens
+// @Summary JSON Web Key Set
+// @Description Public keys of the current and previous access token signing keys. Tokens reference the key by the kid header.
+// @Tags User
+// @Produce json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h UserHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// written without the response envelope, JWKS clients expect the key set at the top level
+	js, err := json.Marshal(auth.Keys().JWKS())
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+// CompleteLogin finishes the login of an account with two-factor authentication
+// @Summary Complete two-step login
+// @Description Exchanges the challenge token returned by login and a TOTP or recovery code for access and refresh tokens.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body CompleteLoginRequest true "challenge token and code"
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 401 {object} jsonutil.Response
+// @Failure 422 {object} jsonutil.Response
+// @Failure 429 {object} jsonutil.Response
+// @Router /user/login/2fa [post]
+func (h UserHandler) CompleteLogin(w http.ResponseWriter, r *http.Request) {
+	var req CompleteLoginRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.ChallengeToken != "", "challenge_token", "must be provided")
+	v.Check(req.Code != "", "code", "must be provided")
+	if !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	loggedUser, accessToken, refreshToken, err := h.userService.CompleteLogin(r.Context(), req.ChallengeToken, req.Code, clientFromRequest(r))
+	if err != nil {
+		h.loginErrorResponse(w, r, err)
+		return
+	}
+
+	h.loginResponse(w, r, loggedUser, accessToken, refreshToken)
+}
+
+// TwoFactorStatus reports whether two-factor authentication is enabled
+// @Summary Two-factor authentication status
+// @Tags User
+// @Produce json
+// @Success 200 {object} user.TwoFactorStatus
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/2fa [get]
+func (h UserHandler) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	status, err := h.twoFactor.Status(r.Context(), *userCtx.UserID)
+	if err != nil {
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, status, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// EnrollTwoFactor generates a new TOTP secret
+// @Summary Enroll two-factor authentication
+// @Description Returns a new TOTP secret and its otpauth URI. Two-factor authentication is enabled after the enrollment is confirmed with a code.
+// @Tags User
+// @Produce json
+// @Success 200 {object} user.Enrollment
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Router /user/2fa/enroll [post]
+func (h UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	enrollment, err := h.twoFactor.Enroll(r.Context(), *userCtx.UserID)
+	if err != nil {
+		h.twoFactorErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, enrollment, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ConfirmTwoFactor enables two-factor authentication
+// @Summary Confirm two-factor authentication
+// @Description Enables two-factor authentication with a code from the authenticator app and returns recovery codes. They are shown only once.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Router /user/2fa/confirm [post]
+func (h UserHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, code, ok := h.readTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactor.Confirm(r.Context(), userID, code)
+	if err != nil {
+		h.twoFactorErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"recovery_codes": codes}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes
+// @Summary Regenerate recovery codes
+// @Description Invalidates all recovery codes and returns new ones. Requires a current TOTP or recovery code.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} jsonutil.Envelope
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/2fa/recovery-codes [post]
+func (h UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, code, ok := h.readTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(r.Context(), userID, code)
+	if err != nil {
+		h.twoFactorErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, jsonutil.Envelope{"recovery_codes": codes}, nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// DisableTwoFactor turns off two-factor authentication
+// @Summary Disable two-factor authentication
+// @Description Removes the TOTP secret and recovery codes. Requires a current TOTP or recovery code.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} jsonutil.Response
+// @Failure 400 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Router /user/2fa [delete]
+func (h UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, code, ok := h.readTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactor.Disable(r.Context(), userID, code); err != nil {
+		h.twoFactorErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "two-factor authentication disabled", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// readTwoFactorCode reads the code of the authenticated user. It writes the error response and returns false on failure.
+func (h UserHandler) readTwoFactorCode(w http.ResponseWriter, r *http.Request) (uint64, string, bool) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return 0, "", false
+	}
+
+	var req TwoFactorCodeRequest
+	if err := jsonutil.Read(r, &req); err != nil {
+		h.BadRequestResponse(w, r, err)
+		return 0, "", false
+	}
+
+	v := validator.New()
+	if v.Check(req.Code != "", "code", "must be provided"); !v.Valid() {
+		h.FailedValidationResponse(w, r, v.Errors)
+		return 0, "", false
+	}
+
+	return *userCtx.UserID, req.Code, true
+}
+
+func (h UserHandler) twoFactorErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, user.ErrInvalidTwoFactorCode), errors.Is(err, user.ErrTwoFactorNotEnrolled):
+		h.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, user.ErrTwoFactorEnabled):
+		h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+	default:
+		h.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/Kostaaa1/tinylink/pkg/jsonutil"
+)
+
+// VerifyEmail confirms the email address with the emailed token
+// @Summary Verify email
+// @Description Marks the email address as verified using the token from the verification email. Tokens are bound to the email, so they stop working once the email changes.
+// @Tags User
+// @Produce json
+// @Param token query string true "verification token"
+// @Success 200 {object} jsonutil.Response
+// @Failure 400 {object} jsonutil.Response
+// @Router /user/verify [get]
+func (h UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.ErrorResponse(w, r, http.StatusBadRequest, user.ErrInvalidVerificationToken.Error())
+		return
+	}
+
+	if err := h.verifyService.Verify(r.Context(), token); err != nil {
+		if errors.Is(err, user.ErrInvalidVerificationToken) {
+			h.ErrorResponse(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusOK, "email has been verified", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ResendVerification emails a new verification link
+// @Summary Resend verification email
+// @Description Sends a new verification link to the email address of the authenticated user.
+// @Tags User
+// @Produce json
+// @Success 202 {object} jsonutil.Response
+// @Failure 401 {object} jsonutil.Response
+// @Failure 409 {object} jsonutil.Response
+// @Failure 429 {object} jsonutil.Response
+// @Router /user/verify/resend [post]
+func (h UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userCtx := auth.FromContext(r.Context())
+	if !userCtx.IsAuthenticated {
+		h.UnauthorizedResponse(w, r)
+		return
+	}
+
+	if err := h.verifyService.Resend(r.Context(), *userCtx.UserID); err != nil {
+		switch {
+		case errors.Is(err, user.ErrAlreadyVerified):
+			h.ErrorResponse(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, constants.ErrNotFound):
+			h.NotFoundResponse(w, r)
+		default:
+			h.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := jsonutil.Write(w, http.StatusAccepted, "verification email has been sent", nil); err != nil {
+		h.ServerErrorResponse(w, r, err)
+	}
+}
+
+// sendVerification emails the verification link after registration. The account is usable without it, so errors are only logged.
+func (h UserHandler) sendVerification(r *http.Request, u *user.User) {
+	if err := h.verifyService.Send(r.Context(), u); err != nil && !errors.Is(err, user.ErrAlreadyVerified) {
+		h.log.Error("failed to send verification email", "error", err, "user_id", u.ID)
+	}
+}
@@ -0,0 +1,67 @@
+package account
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+)
+
+var (
+	ErrInvalidLinkPolicy = errors.New("link policy must be delete, anonymize or transfer")
+	// ErrTransferAccount is returned when the account receiving links of deleted users tries to delete itself
+	ErrTransferAccount = errors.New("account receives links of deleted users and can not be deleted")
+)
+
+// LinkPolicy decides what happens to links of a deleted account.
+type LinkPolicy string
+
+const (
+	// LinkPolicyDelete removes the links together with their click statistics.
+	LinkPolicyDelete LinkPolicy = "delete"
+	// LinkPolicyAnonymize keeps public links working without an owner. Private links can not be opened by anyone else, so they are removed.
+	LinkPolicyAnonymize LinkPolicy = "anonymize"
+	// LinkPolicyTransfer moves the links to Config.TransferTo. Links whose alias that account already owns are removed.
+	LinkPolicyTransfer LinkPolicy = "transfer"
+)
+
+func ParseLinkPolicy(s string) (LinkPolicy, error) {
+	switch p := LinkPolicy(s); p {
+	case LinkPolicyDelete, LinkPolicyAnonymize, LinkPolicyTransfer:
+		return p, nil
+	case "":
+		return LinkPolicyDelete, nil
+	}
+	return "", ErrInvalidLinkPolicy
+}
+
+type Config struct {
+	LinkPolicy LinkPolicy
+	// TransferTo is the account receiving links when LinkPolicy is transfer
+	TransferTo uint64
+}
+
+// Profile is the account data included in the export. Secrets like password and key hashes are never part of it.
+type Profile struct {
+	ID               uint64           `json:"id"`
+	Name             string           `json:"name"`
+	Email            string           `json:"email"`
+	EmailVerifiedAt  *time.Time       `json:"email_verified_at,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	HasPassword      bool             `json:"has_password"`
+	TwoFactorEnabled bool             `json:"two_factor_enabled"`
+	Identities       []*user.Identity `json:"identities"`
+	APIKeys          []*apikey.APIKey `json:"api_keys"`
+	Sessions         []*token.Family  `json:"sessions"`
+	ExportedAt       time.Time        `json:"exported_at"`
+}
+
+// DailyClicks are the click statistics of one link on one day.
+type DailyClicks struct {
+	Alias          string
+	Day            time.Time
+	Clicks         int64
+	UniqueVisitors int64
+}
@@ -0,0 +1,38 @@
+package account
+
+import (
+	"context"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+)
+
+// Repository applies the link policy of a deleted account and reads its click statistics. The link methods include archived links and
+// return aliases whose cached redirects must be evicted.
+type Repository interface {
+	DeleteLinks(ctx context.Context, userID uint64) ([]string, error)
+	AnonymizeLinks(ctx context.Context, userID uint64) ([]string, error)
+	TransferLinks(ctx context.Context, fromUserID, toUserID uint64) ([]string, error)
+	// StreamDailyClicks calls fn for every day with clicks of every link of the user, ordered by alias and day.
+	StreamDailyClicks(ctx context.Context, userID uint64, fn func(c *DailyClicks) error) error
+}
+
+// Reauthenticator confirms the user still controls the account. Implemented by user.Service.
+type Reauthenticator interface {
+	Reauthenticate(ctx context.Context, userID uint64, creds user.Reauth) error
+}
+
+// TwoFactorChecker is implemented by user.TwoFactorService.
+type TwoFactorChecker interface {
+	Enabled(ctx context.Context, userID uint64) (bool, error)
+}
+
+// LinkExporter streams links of the user. Implemented by tinylink.Service.
+type LinkExporter interface {
+	Export(ctx context.Context, userID uint64, fn func(tl *tinylink.Tinylink) error) error
+}
+
+// CacheEvicter removes cached redirects. Implemented by tinylink.Service.
+type CacheEvicter interface {
+	Evict(ctx context.Context, aliases ...string) error
+}
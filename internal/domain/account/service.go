@@ -0,0 +1,149 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+)
+
+// Service lets users delete their account and export its data.
+type Service struct {
+	repo       Repository
+	users      user.Repository
+	identities user.IdentityRepository
+	reauth     Reauthenticator
+	twoFactor  TwoFactorChecker
+	apiKeys    apikey.Repository
+	tokens     token.Repository
+	links      LinkExporter
+	cache      CacheEvicter
+	txManager  transactor.Transactor
+	conf       Config
+}
+
+// twoFactor is optional, without it exports report two-factor authentication as disabled.
+func NewService(
+	repo Repository,
+	users user.Repository,
+	identities user.IdentityRepository,
+	reauth Reauthenticator,
+	twoFactor TwoFactorChecker,
+	apiKeys apikey.Repository,
+	tokens token.Repository,
+	links LinkExporter,
+	cache CacheEvicter,
+	txManager transactor.Transactor,
+	conf Config,
+) *Service {
+	if conf.LinkPolicy == "" {
+		conf.LinkPolicy = LinkPolicyDelete
+	}
+	return &Service{
+		repo:       repo,
+		users:      users,
+		identities: identities,
+		reauth:     reauth,
+		twoFactor:  twoFactor,
+		apiKeys:    apiKeys,
+		tokens:     tokens,
+		links:      links,
+		cache:      cache,
+		txManager:  txManager,
+		conf:       conf,
+	}
+}
+
+// Delete removes the account after the user reauthenticates. Links are handled by the configured policy, refresh tokens and API keys are
+// revoked. Identities, two-factor secrets and other rows owned by the user are removed by the database.
+func (s *Service) Delete(ctx context.Context, userID uint64, creds user.Reauth) error {
+	if s.conf.LinkPolicy == LinkPolicyTransfer && s.conf.TransferTo == userID {
+		return ErrTransferAccount
+	}
+
+	if err := s.reauth.Reauthenticate(ctx, userID, creds); err != nil {
+		return err
+	}
+
+	// tokens are revoked first, if deleting fails the user is logged out instead of keeping sessions of a deleted account
+	if err := s.tokens.Revoke(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	var aliases []string
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		switch s.conf.LinkPolicy {
+		case LinkPolicyAnonymize:
+			aliases, err = s.repo.AnonymizeLinks(ctx, userID)
+		case LinkPolicyTransfer:
+			aliases, err = s.repo.TransferLinks(ctx, userID, s.conf.TransferTo)
+		default:
+			aliases, err = s.repo.DeleteLinks(ctx, userID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s links: %w", s.conf.LinkPolicy, err)
+		}
+
+		if err := s.apiKeys.DeleteByUser(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke api keys: %w", err)
+		}
+
+		return s.users.Delete(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(aliases) == 0 {
+		return nil
+	}
+	return s.cache.Evict(ctx, aliases...)
+}
+
+func (s *Service) Profile(ctx context.Context, userID uint64) (*Profile, error) {
+	userData, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{
+		ID:              userData.ID,
+		Name:            userData.Name,
+		Email:           userData.Email,
+		EmailVerifiedAt: userData.EmailVerifiedAt,
+		CreatedAt:       userData.CreatedAt,
+		HasPassword:     userData.HasPassword(),
+		ExportedAt:      time.Now().UTC(),
+	}
+
+	if s.twoFactor != nil {
+		if p.TwoFactorEnabled, err = s.twoFactor.Enabled(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	if p.Identities, err = s.identities.ListByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if p.APIKeys, err = s.apiKeys.ListByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if p.Sessions, err = s.tokens.ListByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (s *Service) Links(ctx context.Context, userID uint64, fn func(tl *tinylink.Tinylink) error) error {
+	return s.links.Export(ctx, userID, fn)
+}
+
+func (s *Service) DailyClicks(ctx context.Context, userID uint64, fn func(c *DailyClicks) error) error {
+	return s.repo.StreamDailyClicks(ctx, userID, fn)
+}
@@ -0,0 +1,116 @@
+package account_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/account"
+	apikeyMocks "github.com/Kostaaa1/tinylink/internal/mocks/apikey"
+	tokenMocks "github.com/Kostaaa1/tinylink/internal/mocks/token"
+	userMocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type deps struct {
+	repo    *mocks.MockRepository
+	users   *userMocks.MockRepository
+	reauth  *mocks.MockReauthenticator
+	apiKeys *apikeyMocks.MockRepository
+	tokens  *tokenMocks.MockRepository
+	cache   *mocks.MockCacheEvicter
+}
+
+func newService(conf account.Config) (*account.Service, *deps) {
+	d := &deps{
+		repo:    new(mocks.MockRepository),
+		users:   new(userMocks.MockRepository),
+		reauth:  new(mocks.MockReauthenticator),
+		apiKeys: new(apikeyMocks.MockRepository),
+		tokens:  new(tokenMocks.MockRepository),
+		cache:   new(mocks.MockCacheEvicter),
+	}
+	svc := account.NewService(d.repo, d.users, new(userMocks.MockIdentityRepository), d.reauth, nil, d.apiKeys, d.tokens,
+		new(mocks.MockLinkExporter), d.cache, mocks.Transactor{}, conf)
+	return svc, d
+}
+
+func TestAccountService_Delete(t *testing.T) {
+	ctx := context.Background()
+	creds := user.Reauth{Password: "secret-password", IP: "10.0.0.1"}
+
+	policies := []struct {
+		policy account.LinkPolicy
+		method string
+		args   []any
+	}{
+		{account.LinkPolicyDelete, "DeleteLinks", []any{ctx, uint64(2)}},
+		{account.LinkPolicyAnonymize, "AnonymizeLinks", []any{ctx, uint64(2)}},
+		{account.LinkPolicyTransfer, "TransferLinks", []any{ctx, uint64(2), uint64(7)}},
+	}
+
+	for _, p := range policies {
+		t.Run(string(p.policy)+" policy revokes tokens and api keys and deletes the user", func(t *testing.T) {
+			svc, d := newService(account.Config{LinkPolicy: p.policy, TransferTo: 7})
+
+			d.reauth.On("Reauthenticate", ctx, uint64(2), creds).Return(nil)
+			d.tokens.On("Revoke", ctx, uint64(2)).Return(nil)
+			d.repo.On(p.method, p.args...).Return([]string{"a", "b"}, nil)
+			d.apiKeys.On("DeleteByUser", ctx, uint64(2)).Return(nil)
+			d.users.On("Delete", ctx, uint64(2)).Return(nil)
+			d.cache.On("Evict", ctx, []string{"a", "b"}).Return(nil)
+
+			require.NoError(t, svc.Delete(ctx, 2, creds))
+			d.repo.AssertExpectations(t)
+			d.apiKeys.AssertExpectations(t)
+			d.users.AssertExpectations(t)
+			d.tokens.AssertExpectations(t)
+			d.cache.AssertExpectations(t)
+		})
+	}
+
+	t.Run("keeps the account if reauthentication fails", func(t *testing.T) {
+		svc, d := newService(account.Config{})
+
+		d.reauth.On("Reauthenticate", ctx, uint64(2), creds).Return(user.ErrInvalidCredentials)
+
+		require.ErrorIs(t, svc.Delete(ctx, 2, creds), user.ErrInvalidCredentials)
+		d.tokens.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+		d.users.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejects deleting the account receiving transferred links", func(t *testing.T) {
+		svc, d := newService(account.Config{LinkPolicy: account.LinkPolicyTransfer, TransferTo: 2})
+
+		require.ErrorIs(t, svc.Delete(ctx, 2, creds), account.ErrTransferAccount)
+		d.reauth.AssertNotCalled(t, "Reauthenticate", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("does not delete the user if links fail", func(t *testing.T) {
+		svc, d := newService(account.Config{})
+
+		d.reauth.On("Reauthenticate", ctx, uint64(2), creds).Return(nil)
+		d.tokens.On("Revoke", ctx, uint64(2)).Return(nil)
+		d.repo.On("DeleteLinks", ctx, uint64(2)).Return(nil, errors.New("db down"))
+
+		require.Error(t, svc.Delete(ctx, 2, creds))
+		d.users.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		d.cache.AssertNotCalled(t, "Evict", mock.Anything, mock.Anything)
+	})
+}
+
+func TestParseLinkPolicy(t *testing.T) {
+	p, err := account.ParseLinkPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, account.LinkPolicyDelete, p)
+
+	p, err = account.ParseLinkPolicy("anonymize")
+	require.NoError(t, err)
+	require.Equal(t, account.LinkPolicyAnonymize, p)
+
+	_, err = account.ParseLinkPolicy("keep")
+	require.ErrorIs(t, err, account.ErrInvalidLinkPolicy)
+}
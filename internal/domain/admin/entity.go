@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+var (
+	ErrSelfAction = errors.New("admin can not perform this action on own account")
+)
+
+type Action string
+
+const (
+	ActionDisableUser  Action = "user.disable"
+	ActionEnableUser   Action = "user.enable"
+	ActionDeleteUser   Action = "user.delete"
+	ActionRevokeTokens Action = "user.revoke_tokens"
+	ActionExpireLink   Action = "tinylink.expire"
+	ActionTakeDownLink Action = "tinylink.take_down"
+)
+
+type TargetType string
+
+const (
+	TargetUser     TargetType = "user"
+	TargetTinylink TargetType = "tinylink"
+)
+
+type UserSummary struct {
+	ID         uint64     `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Roles      []string   `json:"roles"`
+	LinkCount  int64      `json:"link_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+type UserQuery struct {
+	// Search matches a substring of the user's email or name
+	Search   string
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+// AuditEntry records a single admin action. Entries are written in the same transaction as the action itself.
+type AuditEntry struct {
+	ID         uint64         `json:"id"`
+	AdminID    uint64         `json:"admin_id"`
+	Action     Action         `json:"action"`
+	TargetType TargetType     `json:"target_type"`
+	TargetID   string         `json:"target_id"`
+	Details    map[string]any `json:"details,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+)
+
+type Repository interface {
+	ListUsers(ctx context.Context, q UserQuery) ([]*UserSummary, error)
+	GetUser(ctx context.Context, userID uint64) (*UserSummary, error)
+	// SetUserDisabled disables the user at disabledAt, or enables it when disabledAt is nil.
+	SetUserDisabled(ctx context.Context, userID uint64, disabledAt *time.Time) error
+	// DeleteUser removes the user with all of its links and returns aliases of the removed links.
+	DeleteUser(ctx context.Context, userID uint64) ([]string, error)
+
+	GetLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error)
+	// ExpireLink sets the link expiration to at and returns the updated link.
+	ExpireLink(ctx context.Context, linkID uint64, at time.Time) (*tinylink.Tinylink, error)
+	// DeleteLink removes the link and returns it as it was before removal.
+	DeleteLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error)
+
+	InsertAudit(ctx context.Context, entry *AuditEntry) error
+	ListAudit(ctx context.Context, limit, offset int) ([]*AuditEntry, error)
+}
+
+// CacheEvicter removes cached redirects of links changed by admins. Implemented by tinylink.Service.
+type CacheEvicter interface {
+	Evict(ctx context.Context, aliases ...string) error
+}
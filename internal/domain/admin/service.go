@@ -0,0 +1,179 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+)
+
+type Service struct {
+	repo      Repository
+	token     token.Repository
+	cache     CacheEvicter
+	txManager transactor.Transactor
+}
+
+func NewService(repo Repository, token token.Repository, cache CacheEvicter, txManager transactor.Transactor) *Service {
+	return &Service{
+		repo:      repo,
+		token:     token,
+		cache:     cache,
+		txManager: txManager,
+	}
+}
+
+func (s *Service) ListUsers(ctx context.Context, q UserQuery) ([]*UserSummary, error) {
+	if q.Limit <= 0 || q.Limit > MaxPageSize {
+		q.Limit = DefaultPageSize
+	}
+	return s.repo.ListUsers(ctx, q)
+}
+
+func (s *Service) GetUser(ctx context.Context, userID uint64) (*UserSummary, error) {
+	return s.repo.GetUser(ctx, userID)
+}
+
+// DisableUser prevents the user from logging in and revokes its refresh tokens, so existing sessions end once the access token expires.
+func (s *Service) DisableUser(ctx context.Context, adminID, userID uint64) error {
+	if adminID == userID {
+		return ErrSelfAction
+	}
+
+	now := time.Now().UTC()
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetUserDisabled(ctx, userID, &now); err != nil {
+			return err
+		}
+		return s.audit(ctx, adminID, ActionDisableUser, TargetUser, userID, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.token.Revoke(ctx, userID)
+}
+
+func (s *Service) EnableUser(ctx context.Context, adminID, userID uint64) error {
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetUserDisabled(ctx, userID, nil); err != nil {
+			return err
+		}
+		return s.audit(ctx, adminID, ActionEnableUser, TargetUser, userID, nil)
+	})
+}
+
+// DeleteUser removes the account together with its links, revokes its refresh tokens and evicts cached redirects of removed links.
+func (s *Service) DeleteUser(ctx context.Context, adminID, userID uint64) error {
+	if adminID == userID {
+		return ErrSelfAction
+	}
+
+	var aliases []string
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		aliases, err = s.repo.DeleteUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, adminID, ActionDeleteUser, TargetUser, userID, map[string]any{"links": len(aliases)})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.token.Revoke(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if len(aliases) == 0 {
+		return nil
+	}
+	return s.cache.Evict(ctx, aliases...)
+}
+
+func (s *Service) RevokeTokens(ctx context.Context, adminID, userID uint64) error {
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		// makes sure the user exists before recording the action
+		if _, err := s.repo.GetUser(ctx, userID); err != nil {
+			return err
+		}
+		return s.audit(ctx, adminID, ActionRevokeTokens, TargetUser, userID, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.token.Revoke(ctx, userID)
+}
+
+func (s *Service) GetLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error) {
+	return s.repo.GetLink(ctx, linkID)
+}
+
+// ExpireLink expires the link immediately. The row is kept until the reaper removes it.
+func (s *Service) ExpireLink(ctx context.Context, adminID, linkID uint64) (*tinylink.Tinylink, error) {
+	var tl *tinylink.Tinylink
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		tl, err = s.repo.ExpireLink(ctx, linkID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, adminID, ActionExpireLink, TargetTinylink, linkID, map[string]any{"alias": tl.Alias})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tl, s.cache.Evict(ctx, tl.Alias)
+}
+
+// TakeDownLink removes an abusive link. The reason is kept in the audit log.
+func (s *Service) TakeDownLink(ctx context.Context, adminID, linkID uint64, reason string) error {
+	var tl *tinylink.Tinylink
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		tl, err = s.repo.DeleteLink(ctx, linkID)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, adminID, ActionTakeDownLink, TargetTinylink, linkID, map[string]any{
+			"alias":  tl.Alias,
+			"url":    tl.URL,
+			"reason": reason,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.cache.Evict(ctx, tl.Alias)
+}
+
+func (s *Service) AuditLog(ctx context.Context, limit, offset int) ([]*AuditEntry, error) {
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+	return s.repo.ListAudit(ctx, limit, offset)
+}
+
+func (s *Service) audit(ctx context.Context, adminID uint64, action Action, targetType TargetType, targetID uint64, details map[string]any) error {
+	entry := &AuditEntry{
+		AdminID:    adminID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   strconv.FormatUint(targetID, 10),
+		Details:    details,
+	}
+	if err := s.repo.InsertAudit(ctx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
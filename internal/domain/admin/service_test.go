@@ -0,0 +1,78 @@
+package admin_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/admin"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/admin"
+	tokenmocks "github.com/Kostaaa1/tinylink/internal/mocks/token"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newService() (*admin.Service, *mocks.MockRepository, *tokenmocks.MockRepository, *mocks.MockCacheEvicter) {
+	repo := new(mocks.MockRepository)
+	tokens := new(tokenmocks.MockRepository)
+	cache := new(mocks.MockCacheEvicter)
+	return admin.NewService(repo, tokens, cache, mocks.Transactor{}), repo, tokens, cache
+}
+
+func auditEntry(action admin.Action, targetID string) interface{} {
+	return mock.MatchedBy(func(e *admin.AuditEntry) bool {
+		return e.AdminID == 1 && e.Action == action && e.TargetID == targetID
+	})
+}
+
+func TestAdminService_DisableUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disables user, records audit entry and revokes tokens", func(t *testing.T) {
+		svc, repo, tokens, _ := newService()
+
+		repo.On("SetUserDisabled", ctx, uint64(2), mock.AnythingOfType("*time.Time")).Return(nil)
+		repo.On("InsertAudit", ctx, auditEntry(admin.ActionDisableUser, "2")).Return(nil)
+		tokens.On("Revoke", ctx, uint64(2)).Return(nil)
+
+		require.NoError(t, svc.DisableUser(ctx, 1, 2))
+		repo.AssertExpectations(t)
+		tokens.AssertExpectations(t)
+	})
+
+	t.Run("rejects disabling own account", func(t *testing.T) {
+		svc, repo, tokens, _ := newService()
+
+		require.ErrorIs(t, svc.DisableUser(ctx, 1, 1), admin.ErrSelfAction)
+		repo.AssertNotCalled(t, "SetUserDisabled", mock.Anything, mock.Anything, mock.Anything)
+		tokens.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAdminService_DeleteUser(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, tokens, cache := newService()
+
+	repo.On("DeleteUser", ctx, uint64(2)).Return([]string{"a", "b"}, nil)
+	repo.On("InsertAudit", ctx, auditEntry(admin.ActionDeleteUser, "2")).Return(nil)
+	tokens.On("Revoke", ctx, uint64(2)).Return(nil)
+	cache.On("Evict", ctx, []string{"a", "b"}).Return(nil)
+
+	require.NoError(t, svc.DeleteUser(ctx, 1, 2))
+	cache.AssertExpectations(t)
+}
+
+func TestAdminService_TakeDownLink(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, _, cache := newService()
+
+	repo.On("DeleteLink", ctx, uint64(9)).Return(&tinylink.Tinylink{ID: 9, Alias: "spam", URL: "https://spam.example"}, nil)
+	repo.On("InsertAudit", ctx, mock.MatchedBy(func(e *admin.AuditEntry) bool {
+		return e.Action == admin.ActionTakeDownLink && e.TargetID == "9" && e.Details["reason"] == "phishing"
+	})).Return(nil)
+	cache.On("Evict", ctx, []string{"spam"}).Return(nil)
+
+	require.NoError(t, svc.TakeDownLink(ctx, 1, 9, "phishing"))
+	repo.AssertExpectations(t)
+	cache.AssertExpectations(t)
+}
@@ -0,0 +1,155 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CollectorConfig struct {
+	// QueueSize is the max number of pending clicks. When the queue is full new clicks are dropped.
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	IPSalt        string
+}
+
+// Collector buffers click events in a bounded in-process queue which is drained by a pool of workers that batch-insert clicks into the repository. Track never blocks, so the redirect path is not impacted by slow analytics writes.
+type Collector struct {
+	repo  ClickWriter
+	conf  CollectorConfig
+	log   *slog.Logger
+	queue chan Click
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	dropped atomic.Uint64
+}
+
+func NewCollector(repo ClickWriter, conf CollectorConfig, log *slog.Logger) *Collector {
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = 10_000
+	}
+	if conf.Workers <= 0 {
+		conf.Workers = 2
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 500
+	}
+	if conf.FlushInterval <= 0 {
+		conf.FlushInterval = time.Second
+	}
+
+	return &Collector{
+		repo:  repo,
+		conf:  conf,
+		log:   log,
+		queue: make(chan Click, conf.QueueSize),
+	}
+}
+
+func (c *Collector) Start() {
+	for range c.conf.Workers {
+		c.wg.Add(1)
+		go c.worker()
+	}
+}
+
+// Track enqueues the click without blocking. It returns false if the click was dropped because the queue is full or the collector is shut down.
+func (c *Collector) Track(click Click) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return false
+	}
+
+	if click.Timestamp.IsZero() {
+		click.Timestamp = time.Now().UTC()
+	}
+	if click.Device == "" {
+		click.Device = DeviceFromUserAgent(click.UserAgent)
+	}
+	click.IPHash = hashIP(c.conf.IPSalt, click.IP)
+	click.IP = ""
+
+	select {
+	case c.queue <- click:
+		return true
+	default:
+		if n := c.dropped.Add(1); n%1000 == 1 {
+			c.log.Warn("analytics queue is full, dropping clicks", "dropped_total", n)
+		}
+		return false
+	}
+}
+
+// Shutdown stops accepting new clicks and waits until all pending clicks are flushed or ctx is done.
+func (c *Collector) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Collector) worker() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.conf.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Click, 0, c.conf.BatchSize)
+
+	for {
+		select {
+		case click, ok := <-c.queue:
+			if !ok {
+				c.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= c.conf.BatchSize {
+				c.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				c.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (c *Collector) flush(batch []Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.repo.InsertClicks(ctx, batch); err != nil {
+		c.log.Error("failed to insert clicks", "error", err, "count", len(batch))
+	}
+}
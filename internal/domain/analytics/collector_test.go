@@ -0,0 +1,61 @@
+package analytics_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepo struct {
+	mu     sync.Mutex
+	clicks []analytics.Click
+}
+
+func (r *fakeRepo) InsertClicks(ctx context.Context, clicks []analytics.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clicks = append(r.clicks, clicks...)
+	return nil
+}
+
+func TestCollector_ShutdownFlushesPendingClicks(t *testing.T) {
+	repo := &fakeRepo{}
+	c := analytics.NewCollector(repo, analytics.CollectorConfig{
+		Workers:       2,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		IPSalt:        "salt",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Start()
+
+	for i := range 25 {
+		require.True(t, c.Track(analytics.Click{TinylinkID: uint64(i + 1), Alias: "abc", IP: "127.0.0.1"}))
+	}
+
+	require.NoError(t, c.Shutdown(context.Background()))
+	require.Len(t, repo.clicks, 25)
+
+	for _, click := range repo.clicks {
+		require.Empty(t, click.IP)
+		require.NotEmpty(t, click.IPHash)
+		require.NotEqual(t, "127.0.0.1", click.IPHash)
+		require.False(t, click.Timestamp.IsZero())
+	}
+
+	require.False(t, c.Track(analytics.Click{TinylinkID: 1}), "clicks tracked after shutdown must be dropped")
+}
+
+func TestCollector_DropsWhenQueueIsFull(t *testing.T) {
+	c := analytics.NewCollector(&fakeRepo{}, analytics.CollectorConfig{QueueSize: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// workers are not started so nothing drains the queue
+	require.True(t, c.Track(analytics.Click{TinylinkID: 1}))
+	require.True(t, c.Track(analytics.Click{TinylinkID: 2}))
+	require.False(t, c.Track(analytics.Click{TinylinkID: 3}))
+}
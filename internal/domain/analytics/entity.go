@@ -0,0 +1,74 @@
+package analytics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidInterval = errors.New("invalid interval")
+)
+
+type Interval string
+
+const (
+	IntervalHour  Interval = "hour"
+	IntervalDay   Interval = "day"
+	IntervalMonth Interval = "month"
+)
+
+func ParseInterval(s string) (Interval, error) {
+	switch i := Interval(s); i {
+	case IntervalHour, IntervalDay, IntervalMonth:
+		return i, nil
+	case "":
+		return IntervalDay, nil
+	}
+	return "", ErrInvalidInterval
+}
+
+type Click struct {
+	TinylinkID uint64
+	Alias      string
+	Timestamp  time.Time
+	Referrer   string
+	UserAgent  string
+	Country    string
+	Device     string
+	IPHash     string
+	// IP is used only to compute IPHash and is never persisted
+	IP string
+}
+
+func hashIP(salt, ip string) string {
+	if ip == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(salt + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+type Point struct {
+	Bucket time.Time `json:"bucket"`
+	Clicks int64     `json:"clicks"`
+}
+
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+type Stats struct {
+	Alias          string    `json:"alias"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Interval       Interval  `json:"interval"`
+	TotalClicks    int64     `json:"total_clicks"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+	Series         []Point   `json:"series"`
+	TopReferrers   []Count   `json:"top_referrers"`
+	TopCountries   []Count   `json:"top_countries"`
+	TopDevices     []Count   `json:"top_devices"`
+}
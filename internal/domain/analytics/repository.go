@@ -0,0 +1,24 @@
+package analytics
+
+import (
+	"context"
+	"time"
+)
+
+type ClickWriter interface {
+	// InsertClicks stores raw clicks and incrementally applies their rollups.
+	InsertClicks(ctx context.Context, clicks []Click) error
+}
+
+type StatsReader interface {
+	// OwnedLinkID returns the id of the tinylink with given alias that belongs to user.
+	OwnedLinkID(ctx context.Context, userID uint64, alias string) (uint64, error)
+	Totals(ctx context.Context, linkID uint64, from, to time.Time) (clicks int64, visitors int64, err error)
+	Series(ctx context.Context, linkID uint64, interval Interval, from, to time.Time) ([]Point, error)
+	Top(ctx context.Context, linkID uint64, dim Dimension, from, to time.Time, limit int) ([]Count, error)
+}
+
+type Repository interface {
+	ClickWriter
+	StatsReader
+}
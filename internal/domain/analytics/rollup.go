@@ -0,0 +1,149 @@
+package analytics
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Dimension string
+
+const (
+	DimensionReferrer Dimension = "referrer"
+	DimensionCountry  Dimension = "country"
+	DimensionDevice   Dimension = "device"
+)
+
+type HourlyKey struct {
+	TinylinkID uint64
+	Bucket     time.Time
+}
+
+type VisitorKey struct {
+	TinylinkID uint64
+	Day        time.Time
+	IPHash     string
+}
+
+type DimensionKey struct {
+	TinylinkID uint64
+	Day        time.Time
+	Dimension  Dimension
+	Value      string
+}
+
+// Rollups holds the increments that a batch of clicks contributes to the aggregate tables. Repositories apply them together with the raw clicks so stats never need to scan tinylink_clicks.
+type Rollups struct {
+	Hourly     map[HourlyKey]int64
+	Visitors   map[VisitorKey]struct{}
+	Dimensions map[DimensionKey]int64
+}
+
+func BuildRollups(clicks []Click) Rollups {
+	r := Rollups{
+		Hourly:     make(map[HourlyKey]int64),
+		Visitors:   make(map[VisitorKey]struct{}),
+		Dimensions: make(map[DimensionKey]int64),
+	}
+
+	for _, c := range clicks {
+		ts := c.Timestamp.UTC()
+		day := ts.Truncate(24 * time.Hour)
+
+		r.Hourly[HourlyKey{TinylinkID: c.TinylinkID, Bucket: ts.Truncate(time.Hour)}]++
+
+		if c.IPHash != "" {
+			r.Visitors[VisitorKey{TinylinkID: c.TinylinkID, Day: day, IPHash: c.IPHash}] = struct{}{}
+		}
+
+		dims := map[Dimension]string{
+			DimensionReferrer: ReferrerHost(c.Referrer),
+			DimensionCountry:  orUnknown(c.Country),
+			DimensionDevice:   orUnknown(c.Device),
+		}
+		for dim, value := range dims {
+			r.Dimensions[DimensionKey{TinylinkID: c.TinylinkID, Day: day, Dimension: dim, Value: value}]++
+		}
+	}
+
+	return r
+}
+
+// SortedHourly returns the hourly keys ordered by link and bucket. Repositories apply rollups in sorted order, so concurrent flushes touching the same rows lock them in the same order instead of deadlocking.
+func (r Rollups) SortedHourly() []HourlyKey {
+	keys := make([]HourlyKey, 0, len(r.Hourly))
+	for k := range r.Hourly {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b HourlyKey) int {
+		return cmp.Or(cmp.Compare(a.TinylinkID, b.TinylinkID), a.Bucket.Compare(b.Bucket))
+	})
+	return keys
+}
+
+// SortedVisitors returns the visitor keys ordered by link, day and ip hash.
+func (r Rollups) SortedVisitors() []VisitorKey {
+	keys := make([]VisitorKey, 0, len(r.Visitors))
+	for k := range r.Visitors {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b VisitorKey) int {
+		return cmp.Or(cmp.Compare(a.TinylinkID, b.TinylinkID), a.Day.Compare(b.Day), cmp.Compare(a.IPHash, b.IPHash))
+	})
+	return keys
+}
+
+// SortedDimensions returns the dimension keys ordered by link, day, dimension and value.
+func (r Rollups) SortedDimensions() []DimensionKey {
+	keys := make([]DimensionKey, 0, len(r.Dimensions))
+	for k := range r.Dimensions {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b DimensionKey) int {
+		return cmp.Or(
+			cmp.Compare(a.TinylinkID, b.TinylinkID),
+			a.Day.Compare(b.Day),
+			cmp.Compare(a.Dimension, b.Dimension),
+			cmp.Compare(a.Value, b.Value),
+		)
+	})
+	return keys
+}
+
+// ReferrerHost reduces the referrer to its host, so stats are grouped per site instead of per page.
+func ReferrerHost(referrer string) string {
+	if referrer == "" {
+		return "direct"
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+}
+
+// DeviceFromUserAgent classifies the user agent as bot, tablet, mobile or desktop.
+func DeviceFromUserAgent(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "bot"), strings.Contains(ua, "crawler"), strings.Contains(ua, "spider"), strings.Contains(ua, "curl"):
+		return "bot"
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobi"), strings.Contains(ua, "iphone"), strings.Contains(ua, "android"):
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildRollups(t *testing.T) {
+	base := time.Date(2025, 3, 10, 14, 5, 0, 0, time.UTC)
+
+	clicks := []analytics.Click{
+		{TinylinkID: 1, Timestamp: base, IPHash: "a", Referrer: "https://www.google.com/search?q=x", Country: "DE", Device: "mobile"},
+		{TinylinkID: 1, Timestamp: base.Add(10 * time.Minute), IPHash: "a", Referrer: "https://google.com/", Country: "DE", Device: "desktop"},
+		{TinylinkID: 1, Timestamp: base.Add(time.Hour), IPHash: "b"},
+		{TinylinkID: 2, Timestamp: base, IPHash: "a"},
+	}
+
+	r := analytics.BuildRollups(clicks)
+
+	hour := base.Truncate(time.Hour)
+	day := base.Truncate(24 * time.Hour)
+
+	require.Equal(t, int64(2), r.Hourly[analytics.HourlyKey{TinylinkID: 1, Bucket: hour}])
+	require.Equal(t, int64(1), r.Hourly[analytics.HourlyKey{TinylinkID: 1, Bucket: hour.Add(time.Hour)}])
+	require.Equal(t, int64(1), r.Hourly[analytics.HourlyKey{TinylinkID: 2, Bucket: hour}])
+
+	// same visitor on the same day is counted once
+	require.Len(t, r.Visitors, 3)
+
+	require.Equal(t, int64(2), r.Dimensions[analytics.DimensionKey{TinylinkID: 1, Day: day, Dimension: analytics.DimensionReferrer, Value: "google.com"}])
+	require.Equal(t, int64(1), r.Dimensions[analytics.DimensionKey{TinylinkID: 1, Day: day, Dimension: analytics.DimensionReferrer, Value: "direct"}])
+	require.Equal(t, int64(2), r.Dimensions[analytics.DimensionKey{TinylinkID: 1, Day: day, Dimension: analytics.DimensionCountry, Value: "DE"}])
+	require.Equal(t, int64(1), r.Dimensions[analytics.DimensionKey{TinylinkID: 1, Day: day, Dimension: analytics.DimensionDevice, Value: "unknown"}])
+}
+
+func TestDeviceFromUserAgent(t *testing.T) {
+	cases := map[string]string{
+		"":                        "unknown",
+		"Googlebot/2.1":           "bot",
+		"Mozilla/5.0 (iPad; CPU)": "tablet",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148": "mobile",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0":               "desktop",
+	}
+	for ua, expected := range cases {
+		require.Equal(t, expected, analytics.DeviceFromUserAgent(ua), ua)
+	}
+}
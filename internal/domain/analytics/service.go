@@ -0,0 +1,67 @@
+package analytics
+
+import (
+	"context"
+	"time"
+)
+
+const topLimit = 10
+
+type StatsQuery struct {
+	From     time.Time
+	To       time.Time
+	Interval Interval
+}
+
+type Service struct {
+	stats StatsReader
+}
+
+func NewService(stats StatsReader) *Service {
+	return &Service{stats: stats}
+}
+
+// Stats returns aggregated click statistics for the user's tinylink. Returns constants.ErrNotFound if the alias does not belong to the user.
+func (s *Service) Stats(ctx context.Context, userID uint64, alias string, q StatsQuery) (*Stats, error) {
+	linkID, err := s.stats.OwnedLinkID(ctx, userID, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := q.From.UTC(), q.To.UTC()
+
+	stats := &Stats{
+		Alias:    alias,
+		From:     from,
+		To:       to,
+		Interval: q.Interval,
+	}
+
+	stats.TotalClicks, stats.UniqueVisitors, err = s.stats.Totals(ctx, linkID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Series, err = s.stats.Series(ctx, linkID, q.Interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	top := []struct {
+		dim  Dimension
+		dest *[]Count
+	}{
+		{DimensionReferrer, &stats.TopReferrers},
+		{DimensionCountry, &stats.TopCountries},
+		{DimensionDevice, &stats.TopDevices},
+	}
+
+	for _, t := range top {
+		*t.dest, err = s.stats.Top(ctx, linkID, t.dim, from, to, topLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
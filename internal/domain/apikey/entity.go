@@ -0,0 +1,91 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+
+	// MaxKeysPerUser limits active keys a single user can hold.
+	MaxKeysPerUser = 25
+
+	keyPrefix  = "tl"
+	keySepChar = "_"
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrKeyExpired   = errors.New("api key expired")
+	ErrInvalidScope = errors.New("invalid api key scope")
+	ErrTooManyKeys  = errors.New("too many api keys")
+
+	Scopes = []string{ScopeLinksRead, ScopeLinksWrite}
+)
+
+// APIKey is a long lived credential for programmatic access. Only the hash of the key is stored, the prefix identifies it in listings and lookups.
+type APIKey struct {
+	ID         uint64     `json:"id"`
+	UserID     uint64     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// ValidScopes reports whether every scope is known. Empty scopes are not valid, a key must be able to do something.
+func ValidScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, s := range scopes {
+		if !slices.Contains(Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// newKey returns a key in "tl_<prefix>_<secret>" format together with its prefix.
+func newKey() (key, prefix string) {
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	prefix = hex.EncodeToString(buf)
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	return keyPrefix + keySepChar + prefix + keySepChar + hex.EncodeToString(secret), prefix
+}
+
+// ParsePrefix returns the prefix of the key without validating it.
+func ParsePrefix(key string) (string, error) {
+	parts := strings.Split(key, keySepChar)
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[1]) != 12 || parts[2] == "" {
+		return "", ErrInvalidKey
+	}
+	return parts[1], nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,18 @@
+package apikey
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	Insert(ctx context.Context, key *APIKey) error
+	// GetByPrefix returns the key with the prefix. Keys of disabled users are treated as missing.
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID uint64) ([]*APIKey, error)
+	CountByUser(ctx context.Context, userID uint64) (int, error)
+	// Delete removes the key only if it belongs to the user. Returns constants.ErrNotFound otherwise.
+	Delete(ctx context.Context, userID, keyID uint64) error
+	DeleteByUser(ctx context.Context, userID uint64) error
+	TouchLastUsed(ctx context.Context, keyID uint64, at time.Time) error
+}
@@ -0,0 +1,109 @@
+package apikey
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+)
+
+// touchInterval limits how often last_used_at is written for a busy key.
+const touchInterval = time.Minute
+
+type CreateParams struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type Service struct {
+	repo Repository
+	log  *slog.Logger
+}
+
+func NewService(repo Repository, log *slog.Logger) *Service {
+	return &Service{repo: repo, log: log}
+}
+
+// Create generates a new key for the user. The plaintext key is returned only here and cannot be recovered later.
+func (s *Service) Create(ctx context.Context, userID uint64, params CreateParams) (*APIKey, string, error) {
+	if !ValidScopes(params.Scopes) {
+		return nil, "", ErrInvalidScope
+	}
+
+	n, err := s.repo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if n >= MaxKeysPerUser {
+		return nil, "", ErrTooManyKeys
+	}
+
+	plain, prefix := newKey()
+	key := &APIKey{
+		UserID: userID,
+		Name:   params.Name,
+		Prefix: prefix,
+		Hash:   hashKey(plain),
+		Scopes: params.Scopes,
+	}
+	if params.ExpiresAt != nil {
+		exp := params.ExpiresAt.UTC()
+		key.ExpiresAt = &exp
+	}
+
+	if err := s.repo.Insert(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, plain, nil
+}
+
+func (s *Service) List(ctx context.Context, userID uint64) ([]*APIKey, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *Service) Revoke(ctx context.Context, userID, keyID uint64) error {
+	return s.repo.Delete(ctx, userID, keyID)
+}
+
+func (s *Service) RevokeAll(ctx context.Context, userID uint64) error {
+	return s.repo.DeleteByUser(ctx, userID)
+}
+
+// Authenticate resolves the plaintext key. Unknown keys, keys of disabled users and hash mismatches all return ErrInvalidKey.
+func (s *Service) Authenticate(ctx context.Context, plain string) (*APIKey, error) {
+	prefix, err := ParsePrefix(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashKey(plain))) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now().UTC()
+	if key.Expired(now) {
+		return nil, ErrKeyExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		// failing to record usage must not fail the request
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			s.log.Error("failed to update api key last usage", "error", err, "key_id", key.ID)
+		}
+	}
+
+	return key, nil
+}
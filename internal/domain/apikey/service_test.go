@@ -0,0 +1,123 @@
+package apikey_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/apikey"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(repo apikey.Repository) *apikey.Service {
+	return apikey.NewService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestAPIKeyService_Create(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stores only the hash", func(t *testing.T) {
+		repo := new(mocks.MockRepository)
+		svc := newService(repo)
+
+		var stored *apikey.APIKey
+		repo.On("CountByUser", ctx, uint64(7)).Return(0, nil)
+		repo.On("Insert", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*apikey.APIKey)
+		}).Return(nil)
+
+		key, plain, err := svc.Create(ctx, 7, apikey.CreateParams{Name: "ci", Scopes: []string{apikey.ScopeLinksWrite}})
+		require.NoError(t, err)
+		require.Same(t, stored, key)
+		require.NotContains(t, key.Hash, plain)
+
+		prefix, err := apikey.ParsePrefix(plain)
+		require.NoError(t, err)
+		require.Equal(t, key.Prefix, prefix)
+	})
+
+	t.Run("rejects unknown scopes", func(t *testing.T) {
+		svc := newService(new(mocks.MockRepository))
+
+		_, _, err := svc.Create(ctx, 7, apikey.CreateParams{Name: "ci", Scopes: []string{"admin"}})
+		require.ErrorIs(t, err, apikey.ErrInvalidScope)
+	})
+
+	t.Run("limits number of keys", func(t *testing.T) {
+		repo := new(mocks.MockRepository)
+		svc := newService(repo)
+		repo.On("CountByUser", ctx, uint64(7)).Return(apikey.MaxKeysPerUser, nil)
+
+		_, _, err := svc.Create(ctx, 7, apikey.CreateParams{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}})
+		require.ErrorIs(t, err, apikey.ErrTooManyKeys)
+		repo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	ctx := context.Background()
+
+	// create a real key so the test does not depend on the key format
+	issue := func(t *testing.T, expiresAt *time.Time) (*apikey.APIKey, string) {
+		repo := new(mocks.MockRepository)
+		repo.On("CountByUser", ctx, uint64(7)).Return(0, nil)
+		repo.On("Insert", ctx, mock.Anything).Return(nil)
+
+		key, plain, err := newService(repo).Create(ctx, 7, apikey.CreateParams{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}, ExpiresAt: expiresAt})
+		require.NoError(t, err)
+		key.ID = 3
+		return key, plain
+	}
+
+	t.Run("valid key", func(t *testing.T) {
+		key, plain := issue(t, nil)
+		repo := new(mocks.MockRepository)
+		repo.On("GetByPrefix", ctx, key.Prefix).Return(key, nil)
+		repo.On("TouchLastUsed", ctx, key.ID, mock.Anything).Return(nil)
+
+		got, err := newService(repo).Authenticate(ctx, plain)
+		require.NoError(t, err)
+		require.Equal(t, uint64(7), got.UserID)
+	})
+
+	t.Run("wrong secret with known prefix", func(t *testing.T) {
+		key, plain := issue(t, nil)
+		repo := new(mocks.MockRepository)
+		repo.On("GetByPrefix", ctx, key.Prefix).Return(key, nil)
+
+		_, err := newService(repo).Authenticate(ctx, plain[:len(plain)-1]+"x")
+		require.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("unknown prefix", func(t *testing.T) {
+		key, plain := issue(t, nil)
+		repo := new(mocks.MockRepository)
+		repo.On("GetByPrefix", ctx, key.Prefix).Return(nil, constants.ErrNotFound)
+
+		_, err := newService(repo).Authenticate(ctx, plain)
+		require.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("malformed key", func(t *testing.T) {
+		_, err := newService(new(mocks.MockRepository)).Authenticate(ctx, "not-a-key")
+		require.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		exp := time.Now().Add(time.Hour)
+		key, plain := issue(t, &exp)
+		past := time.Now().Add(-time.Minute)
+		key.ExpiresAt = &past
+
+		repo := new(mocks.MockRepository)
+		repo.On("GetByPrefix", ctx, key.Prefix).Return(key, nil)
+
+		_, err := newService(repo).Authenticate(ctx, plain)
+		require.ErrorIs(t, err, apikey.ErrKeyExpired)
+	})
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var apiKeyAllowedKey contextKey = "api_key_allowed"
+
+// APIKeyFromRequest returns the key from "Authorization: ApiKey <key>" or "X-API-Key" header, or empty string if there is none.
+func APIKeyFromRequest(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+		return strings.TrimSpace(key)
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// HasScope reports whether the request may use the scope. Requests that are not authenticated with an API key are not limited by scopes.
+func (u UserContext) HasScope(scope string) bool {
+	if u.APIKeyID == nil {
+		return true
+	}
+	return slices.Contains(u.Scopes, scope)
+}
+
+// AllowAPIKey marks the request as passing through a route that accepts API keys.
+func AllowAPIKey(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiKeyAllowedKey, true)
+}
+
+func APIKeyAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(apiKeyAllowedKey).(bool)
+	return allowed
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -14,7 +13,6 @@ import (
 type contextKey string
 
 var (
-	jwtSecret                 = []byte(os.Getenv("JWT_SECRET_KEY"))
 	userContextKey contextKey = "user_context"
 
 	ErrAccessTokenExpired  = errors.New("access token expired")
@@ -32,7 +30,10 @@ type UserContext struct {
 	UserID          *uint64
 	GuestUUID       string
 	Roles           []string
-	Error           error
+	// APIKeyID is set when the request is authenticated with an API key. Scopes then limit what the request can do.
+	APIKeyID *uint64
+	Scopes   []string
+	Error    error
 }
 
 type Claims struct {
@@ -68,16 +69,20 @@ func ClaimsFromRequest(r *http.Request) (Claims, error) {
 	return ParseToken(token)
 }
 
-// Parse cookie as claims struct. Used for validation of jwt token
+// Parse cookie as claims struct. Used for validation of jwt token.
+// For a correctly signed but expired token, claims are returned together with ErrAccessTokenExpired, so the caller knows whose session to refresh.
 func ParseToken(tokenStr string) (Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return jwtSecret, nil
-	})
+	token, err := Keys().Parse(tokenStr, &Claims{})
 
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			if token != nil {
+				if claims, ok := token.Claims.(*Claims); ok && claims.UserID > 0 {
+					return *claims, ErrAccessTokenExpired
+				}
+			}
+			return Claims{}, ErrAccessTokenExpired
+		}
 		return Claims{}, err
 	}
 
@@ -106,12 +111,5 @@ func GenerateAccessToken(userID uint64, roles []string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	signed, err := token.SignedString(jwtSecret)
-	if err != nil {
-		return "", err
-	}
-
-	return signed, nil
+	return Keys().Sign(claims)
 }
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrUnsupportedKey  = errors.New("unsupported key type, expected RSA or Ed25519")
+	ErrAlgKeyMismatch  = errors.New("token algorithm does not match the signing key")
+	ErrNoSigningKey    = errors.New("key set has no signing key")
+	errMissingKeyBlock = errors.New("no PEM block found")
+)
+
+// Key is a single JWT key. Verification-only keys (previous keys kept around during rotation) have no private part.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// private is crypto.Signer for asymmetric keys or []byte for HMAC
+	private any
+	public  any
+}
+
+func (k *Key) CanSign() bool { return k.private != nil }
+
+// KeySet signs access tokens with a single key and verifies them with any key it knows, matched by the kid header.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewHMACKeySet returns a key set with a single HS256 secret. HMAC keys are never published in JWKS.
+func NewHMACKeySet(secret []byte) *KeySet {
+	k := &Key{ID: "hs256", Method: jwt.SigningMethodHS256, private: secret, public: secret}
+	return &KeySet{signing: k, keys: map[string]*Key{k.ID: k}}
+}
+
+// LoadKeySet reads the PKCS#8 private key used for signing and any number of PKIX public (or PKCS#8 private) keys that are only used for verification.
+func LoadKeySet(signingKeyFile string, verificationKeyFiles ...string) (*KeySet, error) {
+	signing, err := loadKeyFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("%s: signing key must be a private key", signingKeyFile)
+	}
+
+	ks := &KeySet{signing: signing, keys: map[string]*Key{signing.ID: signing}}
+
+	for _, file := range verificationKeyFiles {
+		k, err := loadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ks.keys[k.ID]; ok {
+			continue
+		}
+		// only the public part of previous keys is needed
+		k.private = nil
+		ks.keys[k.ID] = k
+	}
+
+	return ks, nil
+}
+
+func loadKeyFile(file string) (*Key, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	k, err := parseKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return k, nil
+}
+
+func parseKey(b []byte) (*Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errMissingKeyBlock
+	}
+
+	var private any
+	var public any
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, ErrUnsupportedKey
+		}
+		private, public = signer, signer.Public()
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private, public = key, key.Public()
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public = key
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+
+	k := &Key{private: private, public: public}
+
+	switch public.(type) {
+	case *rsa.PublicKey:
+		k.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	k.ID = keyID(der)
+
+	return k, nil
+}
+
+// keyID is derived from the public key, so the same key file always gets the same kid.
+func keyID(publicDER []byte) string {
+	sum := sha256.Sum256(publicDER)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signing == nil || !ks.signing.CanSign() {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+
+	return token.SignedString(ks.signing.private)
+}
+
+// keyFunc picks the verification key by kid. Tokens without kid are checked against the signing key, which keeps tokens issued before kid was introduced valid.
+func (ks *KeySet) keyFunc(t *jwt.Token) (any, error) {
+	k := ks.signing
+	if kid, ok := t.Header["kid"].(string); ok {
+		k, ok = ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	// the algorithm must come from our key, never from the token
+	if k == nil || t.Method.Alg() != k.Method.Alg() {
+		return nil, ErrAlgKeyMismatch
+	}
+
+	return k.public, nil
+}
+
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc, jwt.WithValidMethods(ks.methods()))
+}
+
+func (ks *KeySet) methods() []string {
+	var methods []string
+	for _, k := range ks.keys {
+		methods = append(methods, k.Method.Alg())
+	}
+	return methods
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns public parts of all asymmetric keys, so other services can verify access tokens locally.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+
+	for _, k := range ks.keys {
+		jwk := JWK{Kid: k.ID, Alg: k.Method.Alg(), Use: "sig"}
+
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   = NewHMACKeySet([]byte(os.Getenv("JWT_SECRET_KEY")))
+)
+
+// SetKeySet replaces the key set used by GenerateAccessToken and ParseToken. Called once on startup.
+func SetKeySet(ks *KeySet) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	keys = ks
+}
+
+func Keys() *KeySet {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keys
+}
@@ -0,0 +1,139 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func writePrivateKey(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return file
+}
+
+func writePublicKey(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "key.pub.pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return file
+}
+
+func claims(userID uint64) auth.Claims {
+	return auth.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	oldKeys, err := auth.LoadKeySet(writePrivateKey(t, rsaKey))
+	require.NoError(t, err)
+
+	oldToken, err := oldKeys.Sign(claims(1))
+	require.NoError(t, err)
+
+	// EdDSA key becomes the signing key, the RSA key is kept only for verification
+	newKeys, err := auth.LoadKeySet(writePrivateKey(t, edKey), writePublicKey(t, &rsaKey.PublicKey))
+	require.NoError(t, err)
+
+	newToken, err := newKeys.Sign(claims(2))
+	require.NoError(t, err)
+
+	t.Run("tokens signed by previous key are still valid", func(t *testing.T) {
+		var c auth.Claims
+		_, err := newKeys.Parse(oldToken, &c)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), c.UserID)
+	})
+
+	t.Run("tokens signed by current key are valid", func(t *testing.T) {
+		var c auth.Claims
+		tok, err := newKeys.Parse(newToken, &c)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), c.UserID)
+		require.Equal(t, "EdDSA", tok.Method.Alg())
+		require.NotEmpty(t, tok.Header["kid"])
+	})
+
+	t.Run("unknown kid is rejected", func(t *testing.T) {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		otherKeys, err := auth.LoadKeySet(writePrivateKey(t, otherKey))
+		require.NoError(t, err)
+
+		_, err = otherKeys.Parse(oldToken, &auth.Claims{})
+		require.ErrorIs(t, err, auth.ErrUnknownKey)
+	})
+
+	t.Run("jwks publishes both keys", func(t *testing.T) {
+		jwks := newKeys.JWKS()
+		require.Len(t, jwks.Keys, 2)
+
+		byType := map[string]auth.JWK{}
+		for _, k := range jwks.Keys {
+			byType[k.Kty] = k
+		}
+		require.Equal(t, "RS256", byType["RSA"].Alg)
+		require.Equal(t, "AQAB", byType["RSA"].E)
+		require.Equal(t, "Ed25519", byType["OKP"].Crv)
+		require.NotEmpty(t, byType["OKP"].X)
+	})
+}
+
+func TestKeySet_RejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ks, err := auth.LoadKeySet(writePrivateKey(t, rsaKey))
+	require.NoError(t, err)
+
+	valid, err := ks.Sign(claims(1))
+	require.NoError(t, err)
+	kid, _, err := jwt.NewParser().ParseUnverified(valid, &auth.Claims{})
+	require.NoError(t, err)
+
+	// HS256 token "signed" with the public key and pointing at the RSA kid
+	pubDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(1))
+	forged.Header["kid"] = kid.Header["kid"]
+	forgedStr, err := forged.SignedString(pubDER)
+	require.NoError(t, err)
+
+	_, err = ks.Parse(forgedStr, &auth.Claims{})
+	require.Error(t, err)
+}
+
+func TestKeySet_HMACHasNoPublicKeys(t *testing.T) {
+	ks := auth.NewHMACKeySet([]byte("secret"))
+
+	tok, err := ks.Sign(claims(1))
+	require.NoError(t, err)
+
+	_, err = ks.Parse(tok, &auth.Claims{})
+	require.NoError(t, err)
+	require.Empty(t, ks.JWKS().Keys)
+}
@@ -0,0 +1,21 @@
+package auth
+
+import "slices"
+
+// Roles match the values of roles_enum in the database.
+const (
+	RoleAdmin      = "admin"
+	RoleSubscriber = "subscriber"
+	RoleUser       = "user"
+	RoleGuest      = "guest"
+)
+
+// HasRole reports whether the user has at least one of the provided roles.
+func (u UserContext) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(u.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"errors"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown login provider")
+	ErrInvalidState    = errors.New("invalid or expired login state")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+	// ErrExchangeFailed wraps errors of the provider while redeeming the code, usually caused by an invalid or reused code
+	ErrExchangeFailed = errors.New("failed to complete login with the provider")
+)
+
+// Profile is the account returned by the provider after a successful login.
+type Profile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+	// LinkUserID is set when a logged in user started the flow to link the identity to the account, instead of logging in with it
+	LinkUserID *uint64
+}
+
+// State is kept server-side between the redirect to the provider and the callback. It is looked up by the random state parameter
+// and bound to the guest session that started the login, so a callback URL can not be replayed in another browser.
+type State struct {
+	Provider  string `json:"provider"`
+	GuestUUID string `json:"guest_uuid"`
+	// Verifier is the PKCE code verifier, only its S256 challenge is sent to the provider
+	Verifier string `json:"verifier"`
+	// Nonce is echoed back in the ID token of OIDC providers
+	Nonce string `json:"nonce"`
+	// LinkUserID is the user that started linking the provider
+	LinkUserID *uint64 `json:"link_user_id,omitempty"`
+}
@@ -0,0 +1,46 @@
+package oauth
+
+import (
+	"context"
+	"sort"
+)
+
+// Provider is an external login provider using the authorization code flow.
+type Provider interface {
+	Name() string
+	// AuthCodeURL returns the URL of the provider's consent page. verifier is the PKCE code verifier.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems the code and returns the authenticated profile. OIDC providers must reject ID tokens with a different nonce.
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Profile, error)
+}
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names returns the names of configured providers in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,12 @@
+package oauth
+
+import (
+	"context"
+	"time"
+)
+
+type StateRepository interface {
+	Save(ctx context.Context, key string, state State, ttl time.Duration) error
+	// Consume returns and removes the state, so it can be used only once. Unknown and expired keys return constants.ErrNotFound.
+	Consume(ctx context.Context, key string) (*State, error)
+}
@@ -0,0 +1,116 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"golang.org/x/oauth2"
+)
+
+type Service struct {
+	providers *Registry
+	states    StateRepository
+	stateTTL  time.Duration
+}
+
+func NewService(providers *Registry, states StateRepository, stateTTL time.Duration) *Service {
+	if stateTTL <= 0 {
+		stateTTL = 10 * time.Minute
+	}
+	return &Service{providers: providers, states: states, stateTTL: stateTTL}
+}
+
+func (s *Service) Providers() []string {
+	return s.providers.Names()
+}
+
+// AuthURL starts the login with the provider and returns the URL the user is redirected to.
+func (s *Service) AuthURL(ctx context.Context, provider, guestUUID string) (string, error) {
+	return s.start(ctx, provider, guestUUID, nil)
+}
+
+// LinkURL is like AuthURL, but the callback links the identity to the user instead of logging in.
+func (s *Service) LinkURL(ctx context.Context, provider, guestUUID string, userID uint64) (string, error) {
+	return s.start(ctx, provider, guestUUID, &userID)
+}
+
+func (s *Service) start(ctx context.Context, provider, guestUUID string, linkUserID *uint64) (string, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	st := State{
+		Provider:  provider,
+		GuestUUID: guestUUID,
+		Verifier:  oauth2.GenerateVerifier(),
+		Nonce:     nonce,
+		// the callback is a redirect from the provider without the access token, so the user is remembered in the state
+		LinkUserID: linkUserID,
+	}
+	if err := s.states.Save(ctx, hashState(state), st, s.stateTTL); err != nil {
+		return "", fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	return p.AuthCodeURL(ctx, state, st.Nonce, st.Verifier)
+}
+
+// Exchange finishes the login started by AuthURL. The state is single use and must come back to the same provider and guest session.
+func (s *Service) Exchange(ctx context.Context, provider, state, code, guestUUID string) (*Profile, error) {
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidState
+	}
+
+	st, err := s.states.Consume(ctx, hashState(state))
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return nil, ErrInvalidState
+		}
+		return nil, err
+	}
+	if st.Provider != provider || st.GuestUUID != guestUUID {
+		return nil, ErrInvalidState
+	}
+
+	profile, err := p.Exchange(ctx, code, st.Verifier, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	profile.Provider = provider
+	profile.LinkUserID = st.LinkUserID
+
+	return profile, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
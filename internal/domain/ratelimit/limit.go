@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New(`invalid rate limit, expected "<requests>/<window>" (e.g. 30/1m) or "off"`)
+
+// Limit allows Requests per Window. Requests are refilled evenly across the window, so a client can burst up to Requests and then continue at Requests/Window.
+// Zero Limit disables limiting.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l Limit) Disabled() bool {
+	return l.Requests <= 0 || l.Window <= 0
+}
+
+// Interval is the time needed to refill a single request.
+func (l Limit) Interval() time.Duration {
+	return l.Window / time.Duration(l.Requests)
+}
+
+func (l Limit) String() string {
+	if l.Disabled() {
+		return "off"
+	}
+	return strconv.Itoa(l.Requests) + "/" + l.Window.String()
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses limits like "30/1m" or "off". Makes Limit usable with flag.TextVar.
+func (l *Limit) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "off" || s == "" {
+		*l = Limit{}
+		return nil
+	}
+
+	requests, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return ErrInvalidLimit
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return ErrInvalidLimit
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return ErrInvalidLimit
+	}
+	// stores work with millisecond precision
+	if d/time.Duration(n) < time.Millisecond {
+		return fmt.Errorf("%w: window is too short for %d requests", ErrInvalidLimit, n)
+	}
+
+	*l = Limit{Requests: n, Window: d}
+	return nil
+}
+
+// Result describes the state of the bucket after the request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request is allowed. Zero for allowed requests.
+	RetryAfter time.Duration
+}
+
+// NewResult builds the result of the GCRA (token bucket equivalent) evaluation. tatOffset is the theoretical arrival time of the next request relative to now,
+// after the request was counted if it was allowed.
+func NewResult(limit Limit, allowed bool, tatOffset time.Duration) Result {
+	interval := limit.Interval()
+	res := Result{
+		Allowed:    allowed,
+		Limit:      limit.Requests,
+		Remaining:  max(int((limit.Window-tatOffset)/interval), 0),
+		ResetAfter: max(tatOffset, 0),
+	}
+	if !allowed {
+		res.RetryAfter = max(tatOffset+interval-limit.Window, 0)
+	}
+	return res
+}
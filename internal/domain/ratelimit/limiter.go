@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store counts the request for the key and reports whether it fits the limit. Implementations must be atomic per key.
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// errLogInterval limits logging while the primary store is unavailable, since every request would log otherwise.
+const errLogInterval = 10 * time.Second
+
+// Limiter applies limits using the primary store and falls back to the in-memory store when the primary one fails.
+// Fallback limits are per instance, so they are looser when the API runs on multiple nodes, but requests are never rejected because the store is down.
+type Limiter struct {
+	store      Store
+	fallback   *MemoryStore
+	log        *slog.Logger
+	lastErrLog atomic.Int64
+}
+
+// NewLimiter creates a limiter. If store is nil, only the in-memory store is used.
+func NewLimiter(store Store, log *slog.Logger) *Limiter {
+	return &Limiter{store: store, fallback: NewMemoryStore(), log: log}
+}
+
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) Result {
+	now := time.Now()
+	if l.store != nil {
+		res, err := l.store.Allow(ctx, key, limit, now)
+		if err == nil {
+			return res
+		}
+
+		last := l.lastErrLog.Load()
+		if now.UnixNano()-last >= int64(errLogInterval) && l.lastErrLog.CompareAndSwap(last, now.UnixNano()) {
+			l.log.Error("rate limit store failed, using in-memory fallback", "error", err)
+		}
+	}
+
+	res, _ := l.fallback.Allow(ctx, key, limit, now)
+	return res
+}
+
+// sweepEvery is the number of Allow calls between removals of full buckets.
+const sweepEvery = 1024
+
+// MemoryStore keeps theoretical arrival times of all keys in memory.
+type MemoryStore struct {
+	mu    sync.Mutex
+	tats  map[string]time.Time
+	calls int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	tat := s.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(limit.Interval())
+	if newTat.Sub(now) > limit.Window {
+		return NewResult(limit, false, tat.Sub(now)), nil
+	}
+
+	s.tats[key] = newTat
+	return NewResult(limit, true, newTat.Sub(now)), nil
+}
+
+// sweep removes keys whose bucket is full again, they behave the same as missing keys.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+}
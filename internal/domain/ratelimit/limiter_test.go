@@ -0,0 +1,70 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_Allow(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 3, Window: 3 * time.Second}
+	now := time.Now()
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Allow(ctx, "k", limit, now)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, i, res.Remaining)
+	}
+
+	res, _ := store.Allow(ctx, "k", limit, now)
+	require.False(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.Equal(t, time.Second, res.RetryAfter)
+	require.Equal(t, 3*time.Second, res.ResetAfter)
+
+	// other keys have their own budget
+	res, _ = store.Allow(ctx, "other", limit, now)
+	require.True(t, res.Allowed)
+
+	// a single request is refilled after the interval
+	res, _ = store.Allow(ctx, "k", limit, now.Add(time.Second))
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+}
+
+func TestLimit_UnmarshalText(t *testing.T) {
+	var l ratelimit.Limit
+	require.NoError(t, l.UnmarshalText([]byte("30/1m")))
+	require.Equal(t, ratelimit.Limit{Requests: 30, Window: time.Minute}, l)
+	require.Equal(t, "30/1m0s", l.String())
+
+	require.NoError(t, l.UnmarshalText([]byte("off")))
+	require.True(t, l.Disabled())
+
+	for _, s := range []string{"30", "0/1m", "x/1m", "30/x", "30/-1m"} {
+		require.ErrorIs(t, l.UnmarshalText([]byte(s)), ratelimit.ErrInvalidLimit, s)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Allow(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestLimiter_Fallback(t *testing.T) {
+	limiter := ratelimit.NewLimiter(failingStore{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	limit := ratelimit.Limit{Requests: 1, Window: time.Minute}
+
+	require.True(t, limiter.Allow(context.Background(), "k", limit).Allowed)
+	require.False(t, limiter.Allow(context.Background(), "k", limit).Allowed)
+}
@@ -0,0 +1,122 @@
+package tinylink
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	MaxBulkItems  = 50_000
+	bulkChunkSize = 1_000
+)
+
+var (
+	ErrTooManyItems = errors.New("too many items in bulk request")
+)
+
+type BulkItem struct {
+	// Row is the position of the item in the uploaded payload, used to match results with input rows.
+	Row    int
+	Params CreateTinylinkParams
+}
+
+type BulkResult struct {
+	Row      int               `json:"row"`
+	Alias    string            `json:"alias,omitempty"`
+	Tinylink *Tinylink         `json:"tinylink,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// BulkCreate inserts all items for the user. Aliases for items without one are generated in a single round trip and rows are inserted in chunks
+// within one transaction. A conflicting alias fails only its own row, any other error fails the whole request.
+func (s *Service) BulkCreate(ctx context.Context, userID uint64, guestUUID string, items []BulkItem) ([]BulkResult, error) {
+	if len(items) > MaxBulkItems {
+		return nil, ErrTooManyItems
+	}
+
+	missing := 0
+	restricted := false
+	for _, item := range items {
+		if item.Params.Alias == nil {
+			missing++
+		} else {
+			restricted = true
+		}
+		if item.Params.Private {
+			restricted = true
+		}
+	}
+
+	if restricted {
+		if err := s.requireVerified(ctx, &userID); err != nil {
+			return nil, err
+		}
+	}
+
+	generated, err := s.cache.GenerateAliases(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResult, len(items))
+	links := make([]*Tinylink, 0, len(items))
+	linkIdx := make([]int, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+
+	for i, item := range items {
+		results[i].Row = item.Row
+
+		tl := &Tinylink{
+			URL:        item.Params.URL,
+			UserID:     &userID,
+			GuestUUID:  guestUUID,
+			Private:    item.Params.Private,
+			Expiration: utcTime(item.Params.Expiration),
+		}
+		if item.Params.Domain != nil {
+			tl.Domain = *item.Params.Domain
+		}
+		if item.Params.Alias != nil {
+			tl.Alias = *item.Params.Alias
+		} else {
+			tl.Alias, generated = generated[0], generated[1:]
+		}
+		results[i].Alias = tl.Alias
+
+		// duplicates inside the same payload would be silently skipped by the database, so the first occurrence wins
+		if _, ok := seen[tl.Alias]; ok {
+			results[i].Error = ErrAliasExists.Error()
+			continue
+		}
+		seen[tl.Alias] = struct{}{}
+
+		links = append(links, tl)
+		linkIdx = append(linkIdx, i)
+	}
+
+	// chunks share one transaction, so a failed request inserts nothing and can be retried as a whole
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		for start := 0; start < len(links); start += bulkChunkSize {
+			end := min(start+bulkChunkSize, len(links))
+			if err := s.repo.InsertBatch(ctx, links[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for j, tl := range links {
+		i := linkIdx[j]
+		if tl.ID == 0 {
+			results[i].Error = ErrAliasExists.Error()
+			continue
+		}
+		results[i].Tinylink = tl
+	}
+
+	return results, nil
+}
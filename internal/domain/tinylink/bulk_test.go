@@ -0,0 +1,51 @@
+package tinylink_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/tinylink"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type txKey struct{}
+
+// recordingTransactor marks the context passed to fn, so repository calls can be checked to run inside the transaction.
+type recordingTransactor struct {
+	calls int
+}
+
+func (t *recordingTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.calls++
+	return fn(context.WithValue(ctx, txKey{}, true))
+}
+
+func TestTinylinkService_BulkCreate_ChunkFailure(t *testing.T) {
+	ctx := context.Background()
+	mockDb := new(mocks.MockDbRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	tx := &recordingTransactor{}
+	svc := tinylink.NewService(mockDb, mockCache, nil, nil, tx)
+
+	items := make([]tinylink.BulkItem, 1500)
+	aliases := make([]string, len(items))
+	for i := range items {
+		items[i] = tinylink.BulkItem{Row: i + 1, Params: tinylink.CreateTinylinkParams{URL: "https://example.com"}}
+		aliases[i] = fmt.Sprintf("alias%d", i)
+	}
+
+	inTx := mock.MatchedBy(func(ctx context.Context) bool { return ctx.Value(txKey{}) == true })
+	mockCache.On("GenerateAliases", ctx, len(items)).Return(aliases, nil)
+	mockDb.On("InsertBatch", inTx, mock.MatchedBy(func(l []*tinylink.Tinylink) bool { return len(l) == 1000 })).Return(nil).Once()
+	mockDb.On("InsertBatch", inTx, mock.MatchedBy(func(l []*tinylink.Tinylink) bool { return len(l) == 500 })).Return(errors.New("connection reset")).Once()
+
+	results, err := svc.BulkCreate(ctx, 1, "guest", items)
+	require.Error(t, err)
+	require.Nil(t, results)
+	require.Equal(t, 1, tx.calls)
+	mockDb.AssertExpectations(t)
+}
@@ -9,6 +9,8 @@ import (
 var (
 	ErrAliasNotProvided = errors.New("alias not provided")
 	ErrAliasExists      = errors.New("alias already exists")
+	ErrLinkExpired      = errors.New("tinylink has expired")
+	ErrEmailNotVerified = errors.New("custom aliases and private links require a verified email address")
 	defaultTTL          = time.Hour
 )
 
@@ -26,6 +28,10 @@ type Tinylink struct {
 	Expiration *time.Time `json:"expiration,omitempty"`
 }
 
+func (tl *Tinylink) Expired(now time.Time) bool {
+	return isExpired(tl.Expiration, now)
+}
+
 func (tl *Tinylink) ToMap() map[string]string {
 	m := map[string]string{
 		"id":         fmt.Sprintf("%d", tl.ID),
@@ -116,8 +122,32 @@ func FromMap(m map[string]string) (*Tinylink, error) {
 	return &tl, nil
 }
 
+type Eviction struct {
+	ID        uint64
+	Alias     string
+	Attempts  int
+	CreatedAt time.Time
+}
+
 type RedirectValue struct {
-	RowID uint64
-	Alias string
-	URL   string
+	RowID      uint64
+	Alias      string
+	URL        string
+	Expiration *time.Time
+}
+
+func (v *RedirectValue) Expired(now time.Time) bool {
+	return isExpired(v.Expiration, now)
+}
+
+// cacheTTL caps the default cache ttl at the remaining lifetime of the link, so cached aliases never outlive their expiration.
+func (v *RedirectValue) cacheTTL(now time.Time) time.Duration {
+	if v.Expiration == nil {
+		return defaultTTL
+	}
+	return min(defaultTTL, v.Expiration.Sub(now))
+}
+
+func isExpired(expiration *time.Time, now time.Time) bool {
+	return expiration != nil && !expiration.After(now)
 }
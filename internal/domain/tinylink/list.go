@@ -0,0 +1,124 @@
+package tinylink
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+type SortField string
+
+const (
+	SortCreatedAt SortField = "created_at"
+	SortAlias     SortField = "alias"
+)
+
+type Sort struct {
+	Field SortField
+	Desc  bool
+}
+
+// ParseSort parses values like "created_at", "-created_at" or "alias" where "-" prefix means descending order. Defaults to newest first.
+func ParseSort(s string) (Sort, error) {
+	if s == "" {
+		return Sort{Field: SortCreatedAt, Desc: true}, nil
+	}
+
+	sort := Sort{Field: SortField(strings.TrimPrefix(s, "-")), Desc: strings.HasPrefix(s, "-")}
+	switch sort.Field {
+	case SortCreatedAt, SortAlias:
+		return sort, nil
+	}
+
+	return Sort{}, ErrInvalidSort
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + string(s.Field)
+	}
+	return string(s.Field)
+}
+
+// Cursor points at the last row of the previous page. It holds the sort key of that row and its id as a tie breaker.
+type Cursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Alias     string    `json:"a,omitempty"`
+	ID        uint64    `json:"i"`
+}
+
+func newCursor(sort Sort, tl *Tinylink) Cursor {
+	c := Cursor{Sort: sort.String(), ID: tl.ID}
+	switch sort.Field {
+	case SortAlias:
+		c.Alias = tl.Alias
+	default:
+		c.CreatedAt = tl.CreatedAt
+	}
+	return c
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes the cursor and verifies it was issued for the same sort order.
+func DecodeCursor(s string, sort Sort) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 || c.Sort != sort.String() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+type ListFilter struct {
+	Domain  *string
+	Private *bool
+	Expired *bool
+	// Search matches a substring of alias or url
+	Search string
+}
+
+type ListParams struct {
+	Filter ListFilter
+	Sort   Sort
+	Cursor *Cursor
+	Limit  int
+}
+
+// ListQuery is passed to the repository. Exactly one of UserID and GuestUUID is set.
+type ListQuery struct {
+	ListParams
+	UserID    *uint64
+	GuestUUID *string
+	Now       time.Time
+}
+
+type Page struct {
+	Tinylinks  []*Tinylink `json:"tinylinks"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
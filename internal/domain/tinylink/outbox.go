@@ -0,0 +1,72 @@
+package tinylink
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// EvictionRelay retries cache evictions stored in the outbox until the cache accepts them.
+type EvictionRelay struct {
+	outbox    EvictionOutbox
+	cache     CacheRepository
+	interval  time.Duration
+	batchSize int
+	log       *slog.Logger
+}
+
+func NewEvictionRelay(outbox EvictionOutbox, cache CacheRepository, interval time.Duration, log *slog.Logger) *EvictionRelay {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &EvictionRelay{
+		outbox:    outbox,
+		cache:     cache,
+		interval:  interval,
+		batchSize: 100,
+		log:       log,
+	}
+}
+
+func (r *EvictionRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Relay(ctx); err != nil {
+				r.log.Error("failed to relay cache evictions", "error", err)
+			}
+		}
+	}
+}
+
+// Relay applies one batch of pending evictions.
+func (r *EvictionRelay) Relay(ctx context.Context) error {
+	pending, err := r.outbox.PendingEvictions(ctx, r.batchSize)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]uint64, len(pending))
+	aliases := make([]string, len(pending))
+	for i, e := range pending {
+		ids[i] = e.ID
+		aliases[i] = e.Alias
+	}
+
+	if err := r.cache.Evict(ctx, aliases...); err != nil {
+		if failErr := r.outbox.FailEvictions(ctx, ids, err.Error()); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+
+	return r.outbox.CompleteEvictions(ctx, ids)
+}
@@ -0,0 +1,90 @@
+package tinylink
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type ReaperMode string
+
+const (
+	ReaperModeDelete  ReaperMode = "delete"
+	ReaperModeArchive ReaperMode = "archive"
+)
+
+type ReaperConfig struct {
+	Mode      ReaperMode
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Reaper periodically removes expired tinylinks in batches. Depending on the mode, expired rows are either deleted or moved to the archive table.
+type Reaper struct {
+	repo LinkReaper
+	conf ReaperConfig
+	log  *slog.Logger
+}
+
+func NewReaper(repo LinkReaper, conf ReaperConfig, log *slog.Logger) *Reaper {
+	if conf.Interval <= 0 {
+		conf.Interval = time.Minute
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 500
+	}
+	if conf.Mode == "" {
+		conf.Mode = ReaperModeArchive
+	}
+	return &Reaper{repo: repo, conf: conf, log: log}
+}
+
+// Run blocks until ctx is cancelled, reaping expired links on every tick.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := r.Reap(ctx)
+			if err != nil {
+				r.log.Error("failed to reap expired tinylinks", "error", err, "mode", r.conf.Mode)
+				continue
+			}
+			if n > 0 {
+				r.log.Info("reaped expired tinylinks", "count", n, "mode", r.conf.Mode)
+			}
+		}
+	}
+}
+
+// Reap processes batches until there are no expired links left (or ctx is done) and returns the total number of reaped rows.
+func (r *Reaper) Reap(ctx context.Context) (int64, error) {
+	var total int64
+	now := time.Now().UTC()
+
+	for ctx.Err() == nil {
+		var n int64
+		var err error
+
+		switch r.conf.Mode {
+		case ReaperModeDelete:
+			n, err = r.repo.DeleteExpired(ctx, now, r.conf.BatchSize)
+		default:
+			n, err = r.repo.ArchiveExpired(ctx, now, r.conf.BatchSize)
+		}
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		if n < int64(r.conf.BatchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
@@ -8,17 +8,40 @@ import (
 type LinkLister interface {
 	ListByUserID(ctx context.Context, userID uint64) ([]*Tinylink, error)
 	ListByGuestUUID(ctx context.Context, guestUUID string) ([]*Tinylink, error)
+	// List returns at most q.Limit links matching the query, ordered by q.Sort and starting after q.Cursor.
+	List(ctx context.Context, q ListQuery) ([]*Tinylink, error)
+	// StreamByUserID calls fn for every link of the user, one row at a time, without loading all of them in memory.
+	StreamByUserID(ctx context.Context, userID uint64, fn func(tl *Tinylink) error) error
 }
 
 type LinkWriter interface {
 	Insert(ctx context.Context, tl *Tinylink) error
+	// InsertBatch inserts all links, skipping the ones whose alias already exists. Skipped links are left with zero ID.
+	InsertBatch(ctx context.Context, links []*Tinylink) error
 	Update(ctx context.Context, tl *Tinylink) error
 	Delete(ctx context.Context, userID uint64, alias string) error
 }
 
+type LinkReaper interface {
+	// DeleteExpired removes at most limit links that expired before now and returns the number of removed rows.
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+	// ArchiveExpired moves at most limit links that expired before now into the archive table and returns the number of moved rows.
+	ArchiveExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
+
+// EvictionOutbox persists cache evictions that could not be applied, so they can be retried once the cache is reachable again.
+type EvictionOutbox interface {
+	EnqueueEvictions(ctx context.Context, aliases []string) error
+	PendingEvictions(ctx context.Context, limit int) ([]Eviction, error)
+	CompleteEvictions(ctx context.Context, ids []uint64) error
+	FailEvictions(ctx context.Context, ids []uint64, reason string) error
+}
+
 type DbRepository interface {
 	LinkWriter
 	LinkLister
+	LinkReaper
+	EvictionOutbox
 	Redirect(ctx context.Context, userID *uint64, alias string) (*RedirectValue, error)
 	Get(ctx context.Context, rowID uint64) (*Tinylink, error)
 }
@@ -26,5 +49,12 @@ type DbRepository interface {
 type CacheRepository interface {
 	Redirect(ctx context.Context, alias string) (*RedirectValue, error)
 	Cache(ctx context.Context, value RedirectValue, ttl time.Duration) error
+	Evict(ctx context.Context, aliases ...string) error
 	GenerateAlias(ctx context.Context) (string, error)
+	GenerateAliases(ctx context.Context, n int) ([]string, error)
+}
+
+// VerificationChecker reports whether the user verified the email address.
+type VerificationChecker interface {
+	EmailVerified(ctx context.Context, userID uint64) (bool, error)
 }
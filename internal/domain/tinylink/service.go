@@ -3,48 +3,122 @@ package tinylink
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/core/transactor"
 	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
 	"github.com/Kostaaa1/tinylink/internal/domain/auth"
 )
 
 type CreateTinylinkParams struct {
-	URL       string  `json:"url"`
-	Alias     *string `json:"alias"`
-	Domain    *string `json:"domain,omitempty"`
-	Private   bool    `json:"private"`
-	UserID    *uint64
-	GuestUUID string
+	URL        string     `json:"url"`
+	Alias      *string    `json:"alias"`
+	Domain     *string    `json:"domain,omitempty"`
+	Private    bool       `json:"private"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	UserID     *uint64
+	GuestUUID  string
 }
 
 type UpdateTinylinkParams struct {
-	ID      uint64  `json:"id"`
-	URL     *string `json:"url"`
-	UserID  uint64  `json:"user_id"`
-	Alias   *string `json:"alias"`
-	Domain  *string `json:"domain,omitempty"`
-	Private bool    `json:"private"`
+	ID         uint64     `json:"id"`
+	URL        *string    `json:"url"`
+	UserID     uint64     `json:"user_id"`
+	Alias      *string    `json:"alias"`
+	Domain     *string    `json:"domain,omitempty"`
+	Private    bool       `json:"private"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	// ClearExpiration removes the expiration of the link. Without it, a nil Expiration keeps the current one.
+	ClearExpiration bool `json:"clear_expiration,omitempty"`
+}
+
+// Visitor describes the client that requested the redirect.
+type Visitor struct {
+	Referrer  string
+	UserAgent string
+	IP        string
+	Country   string
+}
+
+// ClickTracker receives a click event for every successful redirect. Implementations must not block.
+type ClickTracker interface {
+	Track(click analytics.Click) bool
 }
 
 type Service struct {
 	// repo     DbRepository
 	// provider *transactor.Provider[DbRepository]
-	cache CacheRepository
-	repo  DbRepository
+	cache     CacheRepository
+	repo      DbRepository
+	clicks    ClickTracker
+	verified  VerificationChecker
+	txManager transactor.Transactor
 }
 
-func NewService(dbRepo DbRepository, cacheRepo CacheRepository) *Service {
+// clicks is optional, if nil redirects are not tracked. verified is optional, if set custom aliases and private links are allowed only to users with verified email.
+func NewService(dbRepo DbRepository, cacheRepo CacheRepository, clicks ClickTracker, verified VerificationChecker, txManager transactor.Transactor) *Service {
 	return &Service{
-		repo:  dbRepo,
-		cache: cacheRepo,
+		repo:      dbRepo,
+		cache:     cacheRepo,
+		clicks:    clicks,
+		verified:  verified,
+		txManager: txManager,
 	}
 }
 
-func (s *Service) List(ctx context.Context, userCtx auth.UserContext) ([]*Tinylink, error) {
-	if userCtx.UserID != nil {
-		return s.repo.ListByUserID(ctx, *userCtx.UserID)
+// requireVerified returns ErrEmailNotVerified unless the user verified the email. Guests are never verified.
+func (s *Service) requireVerified(ctx context.Context, userID *uint64) error {
+	if s.verified == nil {
+		return nil
+	}
+	if userID == nil {
+		return ErrEmailNotVerified
+	}
+	ok, err := s.verified.EmailVerified(ctx, *userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// List returns one page of the user's tinylinks, or of the guest's when the user is not authenticated.
+func (s *Service) List(ctx context.Context, userCtx auth.UserContext, params ListParams) (*Page, error) {
+	if params.Limit <= 0 || params.Limit > MaxPageSize {
+		params.Limit = DefaultPageSize
+	}
+
+	q := ListQuery{ListParams: params, Now: time.Now().UTC()}
+	if userCtx.IsAuthenticated && userCtx.UserID != nil {
+		q.UserID = userCtx.UserID
+	} else {
+		q.GuestUUID = &userCtx.GuestUUID
+	}
+
+	// one extra row tells whether there is a next page
+	q.Limit++
+
+	links, err := s.repo.List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Tinylinks: links}
+	if len(links) > params.Limit {
+		page.Tinylinks = links[:params.Limit]
+		page.NextCursor = newCursor(params.Sort, page.Tinylinks[params.Limit-1]).Encode()
 	}
-	return s.repo.ListByGuestUUID(ctx, userCtx.GuestUUID)
+
+	return page, nil
+}
+
+func (s *Service) Export(ctx context.Context, userID uint64, fn func(tl *Tinylink) error) error {
+	return s.repo.StreamByUserID(ctx, userID, fn)
 }
 
 func (s *Service) Create(ctx context.Context, params CreateTinylinkParams) (*Tinylink, error) {
@@ -57,7 +131,18 @@ func (s *Service) Create(ctx context.Context, params CreateTinylinkParams) (*Tin
 		return nil, constants.ErrUnauthenticated
 	}
 
-	tl := &Tinylink{URL: params.URL, GuestUUID: params.GuestUUID}
+	if params.Alias != nil || params.Private {
+		if err := s.requireVerified(ctx, params.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	tl := &Tinylink{
+		URL:        params.URL,
+		GuestUUID:  params.GuestUUID,
+		Private:    params.Private,
+		Expiration: utcTime(params.Expiration),
+	}
 
 	if params.Alias != nil {
 		tl.Alias = *params.Alias
@@ -84,10 +169,32 @@ func (s *Service) Create(ctx context.Context, params CreateTinylinkParams) (*Tin
 }
 
 func (s *Service) Update(ctx context.Context, req UpdateTinylinkParams) (*Tinylink, error) {
+	// alias can change, so the old one is needed for cache eviction
+	old, err := s.repo.Get(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if old.UserID == nil || *old.UserID != req.UserID {
+		return nil, constants.ErrNotFound
+	}
+
+	if (req.Alias != nil && *req.Alias != old.Alias) || (req.Private && !old.Private) {
+		if err := s.requireVerified(ctx, &req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
 	tl := &Tinylink{
-		ID:      req.ID,
-		Private: req.Private,
-		UserID:  &req.UserID,
+		ID:         req.ID,
+		Private:    req.Private,
+		UserID:     &req.UserID,
+		Expiration: old.Expiration,
+	}
+	switch {
+	case req.ClearExpiration:
+		tl.Expiration = nil
+	case req.Expiration != nil:
+		tl.Expiration = utcTime(req.Expiration)
 	}
 	if req.Domain != nil {
 		tl.Domain = *req.Domain
@@ -103,22 +210,48 @@ func (s *Service) Update(ctx context.Context, req UpdateTinylinkParams) (*Tinyli
 		return nil, err
 	}
 
+	if err := s.Evict(ctx, old.Alias, tl.Alias); err != nil {
+		return nil, err
+	}
+
 	return tl, nil
 }
 
 // only authenticated users can delete their records. The cleanup will be based on in-active tinylinks
 func (s *Service) Delete(ctx context.Context, userID uint64, alias string) error {
-	return s.repo.Delete(ctx, userID, alias)
+	if err := s.repo.Delete(ctx, userID, alias); err != nil {
+		return err
+	}
+	return s.Evict(ctx, alias)
+}
+
+// Evict removes cached redirects for aliases. If the cache is unavailable, evictions are written to the outbox and retried by EvictionRelay, so stale targets are never served for longer than the relay interval.
+func (s *Service) Evict(ctx context.Context, aliases ...string) error {
+	aliases = slices.Compact(slices.Sorted(slices.Values(aliases)))
+
+	if err := s.cache.Evict(ctx, aliases...); err != nil {
+		if err := s.repo.EnqueueEvictions(ctx, aliases); err != nil {
+			return fmt.Errorf("failed to enqueue cache eviction: %w", err)
+		}
+	}
+
+	return nil
 }
 
-func (s *Service) Redirect(ctx context.Context, userID *uint64, alias string) (uint64, string, error) {
+func (s *Service) Redirect(ctx context.Context, userID *uint64, alias string, visitor Visitor) (uint64, string, error) {
 	val, err := s.cache.Redirect(ctx, alias)
 
 	if err != nil && !errors.Is(err, constants.ErrNotFound) {
 		return 0, "", err
 	}
 
+	now := time.Now()
+
 	if err == nil && val.URL != "" && val.RowID > 0 {
+		if val.Expired(now) {
+			return 0, "", ErrLinkExpired
+		}
+		s.trackClick(val, visitor, now)
 		return val.RowID, val.URL, nil
 	}
 
@@ -131,14 +264,18 @@ func (s *Service) Redirect(ctx context.Context, userID *uint64, alias string) (u
 		return 0, "", constants.ErrNotFound
 	}
 
-	// implement some logic to collect metrics and analysis when redirect happens. Use something like rabitMQ, kafka, redis pub/sub, or even my own event pool. This should not impact performance in any way.
-	// cache it - add hit count - implement worker pool
+	if val.Expired(now) {
+		return 0, "", ErrLinkExpired
+	}
+
+	s.trackClick(val, visitor, now)
 
 	err = s.cache.Cache(ctx, RedirectValue{
-		RowID: val.RowID,
-		Alias: val.Alias,
-		URL:   val.URL,
-	}, defaultTTL)
+		RowID:      val.RowID,
+		Alias:      val.Alias,
+		URL:        val.URL,
+		Expiration: val.Expiration,
+	}, val.cacheTTL(now))
 
 	if err != nil {
 		return 0, "", err
@@ -146,3 +283,27 @@ func (s *Service) Redirect(ctx context.Context, userID *uint64, alias string) (u
 
 	return val.RowID, val.URL, nil
 }
+
+func (s *Service) trackClick(val *RedirectValue, visitor Visitor, now time.Time) {
+	if s.clicks == nil {
+		return
+	}
+	s.clicks.Track(analytics.Click{
+		TinylinkID: val.RowID,
+		Alias:      val.Alias,
+		Timestamp:  now.UTC(),
+		Referrer:   visitor.Referrer,
+		UserAgent:  visitor.UserAgent,
+		Country:    visitor.Country,
+		IP:         visitor.IP,
+	})
+}
+
+// timestamps are stored without time zone, so every expiration is normalized to UTC before it reaches the repository
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -3,10 +3,12 @@ package tinylink_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
 	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
 	mocks "github.com/Kostaaa1/tinylink/internal/mocks/tinylink"
 	"github.com/stretchr/testify/mock"
@@ -118,7 +120,7 @@ func TestTinylinkService_Redirect(t *testing.T) {
 
 			mockDb := new(mocks.MockDbRepository)
 			mockCache := new(mocks.MockCacheRepository)
-			svc := tinylink.NewService(mockDb, mockCache)
+			svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
 
 			if tc.cacheRedirectReturn != nil {
 				mockCache.On("Redirect", ctx, expected.Alias).Return(tc.cacheRedirectReturn...)
@@ -132,13 +134,312 @@ func TestTinylinkService_Redirect(t *testing.T) {
 				mockDb.On("Redirect", ctx, userID, expected.Alias).Return(tc.dbRedirectReturn...)
 			}
 
-			rowID, url, err := svc.Redirect(ctx, userID, expected.Alias)
+			rowID, url, err := svc.Redirect(ctx, userID, expected.Alias, tinylink.Visitor{})
 			tc.assertFn(t, ctx, rowID, url, err)
 			tc.mockAssertions(t, ctx, mockDb, mockCache)
 		})
 	}
 }
 
+func TestTinylinkService_RedirectExpired(t *testing.T) {
+	alias := "expired1"
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(10 * time.Minute)
+
+	t.Run("expired value from cache", func(t *testing.T) {
+		ctx := context.Background()
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+		mockCache.On("Redirect", ctx, alias).Return(&tinylink.RedirectValue{RowID: 1, Alias: alias, URL: "https://a.com", Expiration: &past}, nil)
+
+		rowID, url, err := svc.Redirect(ctx, nil, alias, tinylink.Visitor{})
+		require.ErrorIs(t, err, tinylink.ErrLinkExpired)
+		require.Zero(t, rowID)
+		require.Empty(t, url)
+		mockDb.AssertNotCalled(t, "Redirect")
+	})
+
+	t.Run("expired value from db is not cached", func(t *testing.T) {
+		ctx := context.Background()
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+		mockCache.On("Redirect", ctx, alias).Return(nil, constants.ErrNotFound)
+		mockDb.On("Redirect", ctx, (*uint64)(nil), alias).Return(&tinylink.RedirectValue{RowID: 1, Alias: alias, URL: "https://a.com", Expiration: &past}, nil)
+
+		_, _, err := svc.Redirect(ctx, nil, alias, tinylink.Visitor{})
+		require.ErrorIs(t, err, tinylink.ErrLinkExpired)
+		mockCache.AssertNotCalled(t, "Cache")
+	})
+
+	t.Run("cache ttl is capped at remaining lifetime", func(t *testing.T) {
+		ctx := context.Background()
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+		val := &tinylink.RedirectValue{RowID: 1, Alias: alias, URL: "https://a.com", Expiration: &future}
+		mockCache.On("Redirect", ctx, alias).Return(nil, constants.ErrNotFound)
+		mockDb.On("Redirect", ctx, (*uint64)(nil), alias).Return(val, nil)
+		mockCache.On("Cache", ctx, *val, mock.AnythingOfType("time.Duration")).Return(nil)
+
+		rowID, url, err := svc.Redirect(ctx, nil, alias, tinylink.Visitor{})
+		require.NoError(t, err)
+		require.Equal(t, val.RowID, rowID)
+		require.Equal(t, val.URL, url)
+
+		ttl := mockCache.Calls[1].Arguments.Get(2).(time.Duration)
+		require.LessOrEqual(t, ttl, 10*time.Minute)
+		require.Greater(t, ttl, time.Duration(0))
+	})
+}
+
+func TestTinylinkService_UpdateEvictsCache(t *testing.T) {
+	userID := uint64(7)
+	newAlias := "newalias"
+	newURL := "https://new.com"
+
+	old := &tinylink.Tinylink{ID: 1, Alias: "oldalias", URL: "https://old.com", UserID: &userID}
+	params := tinylink.UpdateTinylinkParams{ID: old.ID, UserID: userID, Alias: &newAlias, URL: &newURL}
+
+	t.Run("evicts old and new alias", func(t *testing.T) {
+		ctx := context.Background()
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+		mockDb.On("Get", ctx, old.ID).Return(old, nil)
+		mockDb.On("Update", ctx, mock.AnythingOfType("*tinylink.Tinylink")).Return(nil)
+		mockCache.On("Evict", ctx, []string{newAlias, old.Alias}).Return(nil)
+
+		tl, err := svc.Update(ctx, params)
+		require.NoError(t, err)
+		require.Equal(t, newAlias, tl.Alias)
+		mockCache.AssertExpectations(t)
+		mockDb.AssertNotCalled(t, "EnqueueEvictions")
+	})
+
+	t.Run("falls back to outbox when cache is down", func(t *testing.T) {
+		ctx := context.Background()
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+		mockDb.On("Get", ctx, old.ID).Return(old, nil)
+		mockDb.On("Update", ctx, mock.AnythingOfType("*tinylink.Tinylink")).Return(nil)
+		mockCache.On("Evict", ctx, []string{newAlias, old.Alias}).Return(errors.New("connection refused"))
+		mockDb.On("EnqueueEvictions", ctx, []string{newAlias, old.Alias}).Return(nil)
+
+		_, err := svc.Update(ctx, params)
+		require.NoError(t, err)
+		mockDb.AssertExpectations(t)
+	})
+
+	t.Run("other user's link is not found", func(t *testing.T) {
+		ctx := context.Background()
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+		mockDb.On("Get", ctx, old.ID).Return(old, nil)
+
+		other := params
+		other.UserID = userID + 1
+		_, err := svc.Update(ctx, other)
+		require.ErrorIs(t, err, constants.ErrNotFound)
+		mockDb.AssertNotCalled(t, "Update")
+		mockCache.AssertNotCalled(t, "Evict")
+	})
+}
+
+func TestTinylinkService_UpdateExpiration(t *testing.T) {
+	userID := uint64(7)
+	url := "https://new.com"
+	current := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := current.AddDate(1, 0, 0)
+
+	old := &tinylink.Tinylink{ID: 1, Alias: "alias", URL: "https://old.com", UserID: &userID, Expiration: &current}
+
+	cases := []struct {
+		name     string
+		params   tinylink.UpdateTinylinkParams
+		expected *time.Time
+	}{
+		{"keeps expiration when omitted", tinylink.UpdateTinylinkParams{URL: &url}, &current},
+		{"sets new expiration", tinylink.UpdateTinylinkParams{URL: &url, Expiration: &next}, &next},
+		{"clears expiration", tinylink.UpdateTinylinkParams{URL: &url, ClearExpiration: true}, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockDb := new(mocks.MockDbRepository)
+			mockCache := new(mocks.MockCacheRepository)
+			svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+			params := tc.params
+			params.ID, params.UserID = old.ID, userID
+
+			mockDb.On("Get", ctx, old.ID).Return(old, nil)
+			mockDb.On("Update", ctx, mock.AnythingOfType("*tinylink.Tinylink")).Return(nil)
+			mockCache.On("Evict", ctx, mock.Anything).Return(nil)
+
+			tl, err := svc.Update(ctx, params)
+			require.NoError(t, err)
+			if tc.expected == nil {
+				require.Nil(t, tl.Expiration)
+			} else {
+				require.NotNil(t, tl.Expiration)
+				require.True(t, tc.expected.Equal(*tl.Expiration))
+			}
+		})
+	}
+}
+
+func TestTinylinkService_BulkCreate(t *testing.T) {
+	ctx := context.Background()
+	userID := uint64(3)
+	taken := "taken"
+	dup := "dup"
+
+	mockDb := new(mocks.MockDbRepository)
+	mockCache := new(mocks.MockCacheRepository)
+	svc := tinylink.NewService(mockDb, mockCache, nil, nil, mocks.Transactor{})
+
+	items := []tinylink.BulkItem{
+		{Row: 1, Params: tinylink.CreateTinylinkParams{URL: "https://a.com"}},
+		{Row: 2, Params: tinylink.CreateTinylinkParams{URL: "https://b.com", Alias: &taken}},
+		{Row: 4, Params: tinylink.CreateTinylinkParams{URL: "https://c.com", Alias: &dup}},
+		{Row: 5, Params: tinylink.CreateTinylinkParams{URL: "https://d.com", Alias: &dup}},
+	}
+
+	mockCache.On("GenerateAliases", ctx, 1).Return([]string{"gen1"}, nil)
+	mockDb.On("InsertBatch", ctx, mock.AnythingOfType("[]*tinylink.Tinylink")).
+		Run(func(args mock.Arguments) {
+			links := args.Get(1).([]*tinylink.Tinylink)
+			require.Len(t, links, 3)
+			for i, tl := range links {
+				if tl.Alias == taken {
+					continue
+				}
+				tl.ID = uint64(i + 100)
+			}
+		}).
+		Return(nil)
+
+	results, err := svc.BulkCreate(ctx, userID, "guest", items)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.Equal(t, 1, results[0].Row)
+	require.NotNil(t, results[0].Tinylink)
+	require.Equal(t, "gen1", results[0].Tinylink.Alias)
+
+	require.Equal(t, 2, results[1].Row)
+	require.Nil(t, results[1].Tinylink)
+	require.Equal(t, tinylink.ErrAliasExists.Error(), results[1].Error)
+
+	require.NotNil(t, results[2].Tinylink)
+	require.Equal(t, tinylink.ErrAliasExists.Error(), results[3].Error)
+}
+
+func TestTinylinkService_RequireVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	userID := uint64(4)
+	alias := "custom"
+
+	setup := func(verified bool) (*tinylink.Service, *mocks.MockDbRepository, *mocks.MockCacheRepository) {
+		mockDb := new(mocks.MockDbRepository)
+		mockCache := new(mocks.MockCacheRepository)
+		checker := new(mocks.MockVerificationChecker)
+		checker.On("EmailVerified", ctx, userID).Return(verified, nil)
+		return tinylink.NewService(mockDb, mockCache, nil, checker, mocks.Transactor{}), mockDb, mockCache
+	}
+
+	t.Run("unverified user cannot use custom alias", func(t *testing.T) {
+		svc, mockDb, _ := setup(false)
+
+		_, err := svc.Create(ctx, tinylink.CreateTinylinkParams{URL: "https://a.com", Alias: &alias, UserID: &userID, GuestUUID: "guest"})
+		require.ErrorIs(t, err, tinylink.ErrEmailNotVerified)
+		mockDb.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unverified user cannot create private links in bulk", func(t *testing.T) {
+		svc, mockDb, _ := setup(false)
+
+		items := []tinylink.BulkItem{{Row: 1, Params: tinylink.CreateTinylinkParams{URL: "https://a.com", Private: true}}}
+		_, err := svc.BulkCreate(ctx, userID, "guest", items)
+		require.ErrorIs(t, err, tinylink.ErrEmailNotVerified)
+		mockDb.AssertNotCalled(t, "InsertBatch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("guest cannot use custom alias", func(t *testing.T) {
+		svc, _, _ := setup(true)
+
+		_, err := svc.Create(ctx, tinylink.CreateTinylinkParams{URL: "https://a.com", Alias: &alias, GuestUUID: "guest"})
+		require.ErrorIs(t, err, tinylink.ErrEmailNotVerified)
+	})
+
+	t.Run("unverified user can create generated public link", func(t *testing.T) {
+		svc, mockDb, mockCache := setup(false)
+		mockCache.On("GenerateAlias", ctx).Return("gen", nil)
+		mockDb.On("Insert", ctx, mock.AnythingOfType("*tinylink.Tinylink")).Return(nil)
+		mockCache.On("Cache", ctx, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+		tl, err := svc.Create(ctx, tinylink.CreateTinylinkParams{URL: "https://a.com", UserID: &userID, GuestUUID: "guest"})
+		require.NoError(t, err)
+		require.Equal(t, "gen", tl.Alias)
+	})
+
+	t.Run("verified user can use custom alias", func(t *testing.T) {
+		svc, mockDb, mockCache := setup(true)
+		mockDb.On("Insert", ctx, mock.AnythingOfType("*tinylink.Tinylink")).Return(nil)
+		mockCache.On("Cache", ctx, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+		tl, err := svc.Create(ctx, tinylink.CreateTinylinkParams{URL: "https://a.com", Alias: &alias, UserID: &userID, GuestUUID: "guest"})
+		require.NoError(t, err)
+		require.Equal(t, alias, tl.Alias)
+	})
+}
+
+func TestTinylinkService_ListPagination(t *testing.T) {
+	ctx := context.Background()
+	userID := uint64(9)
+	userCtx := auth.UserContext{IsAuthenticated: true, UserID: &userID, GuestUUID: "guest"}
+
+	links := make([]*tinylink.Tinylink, 3)
+	for i := range links {
+		links[i] = &tinylink.Tinylink{ID: uint64(i + 1), Alias: fmt.Sprintf("a%d", i), CreatedAt: time.Date(2025, 1, 3-i, 0, 0, 0, 0, time.UTC)}
+	}
+
+	sort, err := tinylink.ParseSort("")
+	require.NoError(t, err)
+
+	mockDb := new(mocks.MockDbRepository)
+	svc := tinylink.NewService(mockDb, new(mocks.MockCacheRepository), nil, nil, mocks.Transactor{})
+
+	mockDb.On("List", ctx, mock.MatchedBy(func(q tinylink.ListQuery) bool {
+		return q.UserID != nil && *q.UserID == userID && q.GuestUUID == nil && q.Limit == 3
+	})).Return(links, nil)
+
+	page, err := svc.List(ctx, userCtx, tinylink.ListParams{Sort: sort, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Tinylinks, 2)
+	require.NotEmpty(t, page.NextCursor)
+
+	cursor, err := tinylink.DecodeCursor(page.NextCursor, sort)
+	require.NoError(t, err)
+	require.Equal(t, links[1].ID, cursor.ID)
+	require.True(t, links[1].CreatedAt.Equal(cursor.CreatedAt))
+
+	aliasSort, _ := tinylink.ParseSort("alias")
+	_, err = tinylink.DecodeCursor(page.NextCursor, aliasSort)
+	require.ErrorIs(t, err, tinylink.ErrInvalidCursor)
+}
+
 // func TestTinylinkService_Create(t *testing.T) {
 // 	type testCase struct {
 // 		params          tinylink.CreateTinylinkParams
@@ -266,7 +567,7 @@ func TestTinylinkService_Redirect(t *testing.T) {
 // 			mockDb := new(mocks.MockDbRepository)
 // 			mockCache := new(mocks.MockCacheRepository)
 
-// 			svc := tinylink.NewService(mockDb, mockCache)
+// 			svc := tinylink.NewService(mockDb, mockCache, nil, nil)
 
 // 			if tc.mockCacheReturn != nil {
 // 				mockCache.On("GenerateAlias", ctx).Return(tc.mockCacheReturn...)
@@ -0,0 +1,59 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTokenReused      = errors.New("refresh token reused")
+	ErrMalformedToken   = errors.New("malformed refresh token")
+	refreshTokenSepChar = "."
+)
+
+// Family is a chain of refresh tokens issued to a single device. Every rotation replaces the current token of the family, so presenting an older token means it was stolen or replayed.
+type Family struct {
+	ID         string    `json:"id"`
+	UserID     uint64    `json:"-"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// Client describes the device a family is issued to.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// newRefreshToken returns a token in "<family id>.<secret>" format.
+func newRefreshToken(familyID string) string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return familyID + refreshTokenSepChar + base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// FamilyID returns the family of the refresh token without validating it.
+func FamilyID(refreshToken string) (string, error) {
+	familyID, secret, ok := strings.Cut(refreshToken, refreshTokenSepChar)
+	if !ok || secret == "" {
+		return "", ErrMalformedToken
+	}
+	if _, err := uuid.Parse(familyID); err != nil {
+		return "", ErrMalformedToken
+	}
+	return familyID, nil
+}
+
+func hashToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
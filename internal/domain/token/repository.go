@@ -5,8 +5,27 @@ import (
 	"time"
 )
 
+// Repository stores refresh token families. Only hashes of refresh tokens are stored.
 type Repository interface {
-	Save(ctx context.Context, userID uint64, refreshToken string, ttl time.Duration) error
+	// Create starts a new family with tokenHash as its current token.
+	Create(ctx context.Context, fam *Family, tokenHash string, ttl time.Duration) error
+	// Rotate replaces the current token of the family. Returns constants.ErrNotFound if the family does not exist.
+	// If oldHash is not the current token, the family is returned together with ErrTokenReused.
+	Rotate(ctx context.Context, familyID, oldHash, newHash string, now time.Time, ttl time.Duration) (*Family, error)
+	Get(ctx context.Context, familyID string) (*Family, error)
+	ListByUser(ctx context.Context, userID uint64) ([]*Family, error)
+	RevokeFamily(ctx context.Context, userID uint64, familyID string) error
+	// Revoke removes all refresh token families of the user.
 	Revoke(ctx context.Context, userID uint64) error
-	Valid(ctx context.Context, userID uint64, refreshToken string) error
+}
+
+type SessionRepository interface {
+	SaveSession(ctx context.Context, sess *Session, ttl time.Duration) error
+	// GetSession returns constants.ErrNotFound if the session does not exist or has expired.
+	GetSession(ctx context.Context, guestUUID string) (*Session, error)
+}
+
+// RoleReader loads user roles, so that rotated access tokens reflect role changes.
+type RoleReader interface {
+	Roles(ctx context.Context, userID uint64) ([]string, error)
 }
@@ -2,51 +2,155 @@ package token
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/internal/constants"
 	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/google/uuid"
 )
 
 type Service struct {
-	token Repository
+	token    Repository
+	sessions SessionRepository
+	roles    RoleReader
 }
 
-func NewService(tokenRepo Repository) *Service {
-	return &Service{token: tokenRepo}
+func NewService(tokenRepo Repository, sessions SessionRepository, roles RoleReader) *Service {
+	return &Service{token: tokenRepo, sessions: sessions, roles: roles}
 }
 
-func (s *Service) ValidateAndRotateTokens(ctx context.Context, userID uint64, oldToken string) (refreshToken string, accessToken string, err error) {
-	// validate if provided token matches the stored one in redis
-	if err := s.token.Valid(ctx, userID, oldToken); err != nil {
-		return "", "", err
+// Issue starts a new token family for the device and returns its first refresh token.
+func (s *Service) Issue(ctx context.Context, userID uint64, client Client) (string, error) {
+	now := time.Now().UTC()
+	fam := &Family{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
 	}
 
-	refreshToken = auth.GenerateRefreshToken()
-	if err := s.token.Save(ctx, userID, refreshToken, auth.RefreshTokenTTL); err != nil {
-		return "", "", err
+	refreshToken := newRefreshToken(fam.ID)
+	if err := s.token.Create(ctx, fam, hashToken(refreshToken), auth.RefreshTokenTTL); err != nil {
+		return "", fmt.Errorf("failed to create token family: %w", err)
 	}
 
-	accessToken, err = auth.GenerateAccessToken(userID, nil)
+	return refreshToken, nil
+}
+
+// Tokens is the result of a successful refresh.
+type Tokens struct {
+	UserID       uint64
+	Roles        []string
+	AccessToken  string
+	RefreshToken string
+}
+
+// Refresh rotates the refresh token and issues a new access token for the owner of its family. Roles are reloaded from the database, so role changes take effect on the next refresh.
+// If refreshToken was already rotated, the whole family is revoked and ErrTokenReused is returned.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	familyID, err := FamilyID(refreshToken)
+	if err != nil {
+		return nil, auth.ErrTokenNotValid
+	}
+	return s.rotate(ctx, familyID, refreshToken)
+}
+
+// RefreshFor works like Refresh, but fails with auth.ErrTokenNotValid without rotating if the token family does not belong to userID.
+func (s *Service) RefreshFor(ctx context.Context, userID uint64, refreshToken string) (*Tokens, error) {
+	familyID, err := FamilyID(refreshToken)
+	if err != nil {
+		return nil, auth.ErrTokenNotValid
+	}
+
+	fam, err := s.token.Get(ctx, familyID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
+	}
+	if fam.UserID != userID {
+		return nil, auth.ErrTokenNotValid
 	}
 
-	return refreshToken, accessToken, nil
+	return s.rotate(ctx, familyID, refreshToken)
 }
 
-type Session struct {
-	GuestUUID string
-	CSRF      string
-	IssuedAt  string
-	ExpiresAt string
-	IP        string
-	UserAgent string
+func (s *Service) rotate(ctx context.Context, familyID, oldToken string) (*Tokens, error) {
+	newToken := newRefreshToken(familyID)
+
+	fam, err := s.token.Rotate(ctx, familyID, hashToken(oldToken), hashToken(newToken), time.Now().UTC(), auth.RefreshTokenTTL)
+	if errors.Is(err, ErrTokenReused) {
+		if err := s.token.RevokeFamily(ctx, fam.UserID, familyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke reused token family: %w", err)
+		}
+		return nil, ErrTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roles.Roles(ctx, fam.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+
+	accessToken, err := auth.GenerateAccessToken(fam.UserID, roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &Tokens{
+		UserID:       fam.UserID,
+		Roles:        roles,
+		AccessToken:  accessToken,
+		RefreshToken: newToken,
+	}, nil
+}
+
+// ListSessions returns active token families of the user, most recently used first.
+func (s *Service) ListSessions(ctx context.Context, userID uint64) ([]*Family, error) {
+	return s.token.ListByUser(ctx, userID)
+}
+
+// RevokeSession revokes a single token family. Returns constants.ErrNotFound if it does not belong to the user.
+func (s *Service) RevokeSession(ctx context.Context, userID uint64, familyID string) error {
+	fam, err := s.token.Get(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	if fam.UserID != userID {
+		return constants.ErrNotFound
+	}
+	return s.token.RevokeFamily(ctx, userID, familyID)
 }
 
-func (s *Service) CreateSession(ctx context.Context, sess Session) *Session {
-	return nil
+// CurrentSession returns the family the refresh token belongs to. Returns constants.ErrNotFound if it is not an active session of the user.
+func (s *Service) CurrentSession(ctx context.Context, userID uint64, refreshToken string) (*Family, error) {
+	familyID, err := FamilyID(refreshToken)
+	if err != nil {
+		return nil, constants.ErrNotFound
+	}
+	fam, err := s.token.Get(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if fam.UserID != userID {
+		return nil, constants.ErrNotFound
+	}
+	return fam, nil
+}
+
+// RevokeToken revokes the family the refresh token belongs to, used on logout.
+func (s *Service) RevokeToken(ctx context.Context, userID uint64, refreshToken string) error {
+	familyID, err := FamilyID(refreshToken)
+	if err != nil {
+		return err
+	}
+	return s.RevokeSession(ctx, userID, familyID)
 }
 
-func (s *Service) GetSession(ctx context.Context, guestUUID string) *Session {
-	return nil
+func (s *Service) RevokeAll(ctx context.Context, userID uint64) error {
+	return s.token.Revoke(ctx, userID)
 }
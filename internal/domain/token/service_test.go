@@ -0,0 +1,83 @@
+package token_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/token"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenService_Refresh(t *testing.T) {
+	ctx := context.Background()
+	const familyID = "2f1b6f0e-8d4a-4c7e-9b1a-3e5d7c9f1a2b"
+	oldToken := familyID + ".secret"
+
+	t.Run("rotates token within the family", func(t *testing.T) {
+		repo := new(mocks.MockRepository)
+		roles := new(mocks.MockRoleReader)
+		svc := token.NewService(repo, nil, roles)
+
+		repo.On("Rotate", ctx, familyID, mock.Anything, mock.Anything, mock.Anything, auth.RefreshTokenTTL).
+			Return(&token.Family{ID: familyID, UserID: 7}, nil)
+		roles.On("Roles", ctx, uint64(7)).Return([]string{auth.RoleUser}, nil)
+
+		tokens, err := svc.Refresh(ctx, oldToken)
+		require.NoError(t, err)
+		require.Equal(t, uint64(7), tokens.UserID)
+		require.NotEmpty(t, tokens.AccessToken)
+		require.NotEqual(t, oldToken, tokens.RefreshToken)
+		require.Equal(t, []string{auth.RoleUser}, tokens.Roles)
+
+		id, err := token.FamilyID(tokens.RefreshToken)
+		require.NoError(t, err)
+		require.Equal(t, familyID, id)
+	})
+
+	t.Run("revokes the family when a rotated token is reused", func(t *testing.T) {
+		repo := new(mocks.MockRepository)
+		svc := token.NewService(repo, nil, nil)
+
+		repo.On("Rotate", ctx, familyID, mock.Anything, mock.Anything, mock.Anything, auth.RefreshTokenTTL).
+			Return(&token.Family{ID: familyID, UserID: 7}, token.ErrTokenReused)
+		repo.On("RevokeFamily", ctx, uint64(7), familyID).Return(nil)
+
+		_, err := svc.Refresh(ctx, oldToken)
+		require.ErrorIs(t, err, token.ErrTokenReused)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("rejects malformed token", func(t *testing.T) {
+		repo := new(mocks.MockRepository)
+		svc := token.NewService(repo, nil, nil)
+
+		_, err := svc.Refresh(ctx, "not-a-token")
+		require.ErrorIs(t, err, auth.ErrTokenNotValid)
+		repo.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("does not rotate token of another user", func(t *testing.T) {
+		repo := new(mocks.MockRepository)
+		svc := token.NewService(repo, nil, nil)
+
+		repo.On("Get", ctx, familyID).Return(&token.Family{ID: familyID, UserID: 8}, nil)
+
+		_, err := svc.RefreshFor(ctx, 7, oldToken)
+		require.ErrorIs(t, err, auth.ErrTokenNotValid)
+		repo.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestTokenService_RevokeSession(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mocks.MockRepository)
+	svc := token.NewService(repo, nil, nil)
+
+	repo.On("Get", ctx, "fam-1").Return(&token.Family{ID: "fam-1", UserID: 8}, nil)
+
+	require.Error(t, svc.RevokeSession(ctx, 7, "fam-1"))
+	repo.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything, mock.Anything)
+}
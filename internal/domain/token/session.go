@@ -0,0 +1,88 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+)
+
+// SessionTTL matches the lifetime of the guest cookie the session is keyed by.
+var SessionTTL = auth.GuestIDDuration
+
+// Session is the server-side state of a visitor, identified by the guest UUID cookie. It holds the CSRF token required for state-changing requests.
+type Session struct {
+	GuestUUID string
+	CSRF      string
+	IP        string
+	UserAgent string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// ValidCSRF compares the token in constant time.
+func (s *Session) ValidCSRF(token string) bool {
+	if s == nil || token == "" || s.CSRF == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(s.CSRF), []byte(token)) == 1
+}
+
+func generateCSRFToken() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// CreateSession issues a new session with a fresh CSRF token, replacing the existing one for the same guest.
+func (s *Service) CreateSession(ctx context.Context, guestUUID, ip, userAgent string) (*Session, error) {
+	now := time.Now().UTC()
+	sess := &Session{
+		GuestUUID: guestUUID,
+		CSRF:      generateCSRFToken(),
+		IP:        ip,
+		UserAgent: userAgent,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(SessionTTL),
+	}
+
+	if err := s.sessions.SaveSession(ctx, sess, SessionTTL); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func (s *Service) GetSession(ctx context.Context, guestUUID string) (*Session, error) {
+	sess, err := s.sessions.GetSession(ctx, guestUUID)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Expired(time.Now()) {
+		return nil, constants.ErrNotFound
+	}
+	return sess, nil
+}
+
+// EnsureSession returns the existing session of the guest or creates a new one.
+func (s *Service) EnsureSession(ctx context.Context, guestUUID, ip, userAgent string) (*Session, error) {
+	sess, err := s.GetSession(ctx, guestUUID)
+	if errors.Is(err, constants.ErrNotFound) {
+		return s.CreateSession(ctx, guestUUID, ip, userAgent)
+	}
+	return sess, err
+}
+
+// RotateSession replaces the CSRF token of the guest. Called on login, so a token obtained before authentication can not be reused after it.
+func (s *Service) RotateSession(ctx context.Context, guestUUID, ip, userAgent string) (*Session, error) {
+	return s.CreateSession(ctx, guestUUID, ip, userAgent)
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"errors"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/Kostaaa1/tinylink/pkg/validator"
@@ -14,18 +15,13 @@ var (
 	ErrDuplicateEmail     = errors.New("duplicate email")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrNoUserPasswordSet  = errors.New("password not set for user")
+	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrEditConflict       = errors.New("user was modified by another request, reload and try again")
 )
 
-type GoogleUser struct {
-	UserID        uint64
-	ID            string    `json:"id"`
-	Email         string    `json:"email"`
-	VerifiedEmail bool      `json:"verified_email"`
-	Name          string    `json:"name"`
-	GivenName     string    `json:"given_name"`
-	FamilyName    string    `json:"family_name"`
-	Picture       string    `json:"picture"`
-	CreatedAt     time.Time `json:"created_at"`
+type ClaimResult struct {
+	Claimed   []string `json:"claimed"`
+	Conflicts []string `json:"conflicts"`
 }
 
 type User struct {
@@ -35,7 +31,12 @@ type User struct {
 	Password  password
 	CreatedAt time.Time
 	Version   int
-	Google    *GoogleUser
+	// DisabledAt is set when an admin disables the account
+	DisabledAt *time.Time
+	// EmailVerifiedAt is set once the user confirms the email address, or signs up with an identity whose email the provider verified
+	EmailVerifiedAt *time.Time
+	// Roles are loaded on login and embedded in the access token
+	Roles []string
 }
 
 func (u *User) SetPassword(pwHash []byte) {
@@ -72,6 +73,15 @@ func (p *password) Matches(plainPW string) (bool, error) {
 	return true, nil
 }
 
+// dummyPassword is compared against when the user has no password, so failed logins take the same time whether the account exists or not.
+var dummyPassword = sync.OnceValue(func() password {
+	var p password
+	p.Set("dummy password never matches")
+	return p
+})
+
+func (u *User) EmailVerified() bool { return u.EmailVerifiedAt != nil }
+
 func (u *User) HasPassword() bool { return len(u.Password.Hash) > 0 }
 
 func ValidateEmail(v *validator.Validator, email string) {
@@ -0,0 +1,189 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+)
+
+// pendingLinkTTL is how long the user has to log in and confirm linking an identity found by email.
+const pendingLinkTTL = 15 * time.Minute
+
+var (
+	ErrIdentityConflict      = errors.New("identity is already linked to another account")
+	ErrIdentityEmailRequired = errors.New("login provider did not return an email address")
+	ErrLinkRequired          = errors.New("an account with this email already exists, log in and confirm linking the provider")
+	ErrInvalidLinkToken      = errors.New("invalid or expired link token")
+	ErrLastLoginMethod       = errors.New("can not unlink the only way to log in, set a password or link another provider first")
+)
+
+// LinkRequiredError is returned by ExternalLogin when the identity is new but its email belongs to an existing account. The identity
+// is kept under LinkToken until the owner of the account logs in and confirms the link with ConfirmLink.
+type LinkRequiredError struct {
+	LinkToken string
+	Provider  string
+	Email     string
+	ExpiresAt time.Time
+}
+
+func (e *LinkRequiredError) Error() string { return ErrLinkRequired.Error() }
+func (e *LinkRequiredError) Unwrap() error { return ErrLinkRequired }
+
+// Identity is an account at an external login provider linked to the user. Provider and Subject identify it, the email is only a hint
+// from the provider and is never used to find the identity.
+type Identity struct {
+	ID            uint64     `json:"id"`
+	UserID        uint64     `json:"-"`
+	Provider      string     `json:"provider"`
+	Subject       string     `json:"-"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	Name          string     `json:"name"`
+	Picture       string     `json:"picture,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+}
+
+type IdentityRepository interface {
+	Get(ctx context.Context, provider, subject string) (*Identity, error)
+	ListByUser(ctx context.Context, userID uint64) ([]*Identity, error)
+	// LockByUser lists the identities of the user and locks them until the transaction ends.
+	LockByUser(ctx context.Context, userID uint64) ([]*Identity, error)
+	// Insert returns ErrIdentityConflict if the provider and subject are already linked.
+	Insert(ctx context.Context, identity *Identity) error
+	// Touch refreshes profile fields of the identity with the ones from the latest login and sets its last login time.
+	Touch(ctx context.Context, identity *Identity, at time.Time) error
+	Delete(ctx context.Context, userID, id uint64) error
+}
+
+// PendingLinkRepository keeps identities waiting for the account owner to confirm the link. UserID of the identity is the account it
+// will be linked to.
+type PendingLinkRepository interface {
+	Save(ctx context.Context, tokenHash string, identity *Identity, ttl time.Duration) error
+	// Consume returns and removes the identity. Unknown and expired tokens return constants.ErrNotFound.
+	Consume(ctx context.Context, tokenHash string) (*Identity, error)
+}
+
+type Identities struct {
+	Identities  []*Identity `json:"identities"`
+	HasPassword bool        `json:"has_password"`
+}
+
+func (s *Service) Identities(ctx context.Context, userID uint64) (*Identities, error) {
+	userData, err := s.user.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := s.identities.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identities{Identities: identities, HasPassword: userData.HasPassword()}, nil
+}
+
+// LinkIdentity links the identity to the user. Linking an identity the user already has only refreshes it.
+func (s *Service) LinkIdentity(ctx context.Context, userID uint64, identity *Identity) error {
+	now := time.Now().UTC()
+
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		existing, err := s.identities.Get(ctx, identity.Provider, identity.Subject)
+		switch {
+		case err == nil:
+			if existing.UserID != userID {
+				return ErrIdentityConflict
+			}
+			identity.ID, identity.UserID = existing.ID, existing.UserID
+			return s.identities.Touch(ctx, identity, now)
+		case !errors.Is(err, constants.ErrNotFound):
+			return err
+		}
+
+		identity.UserID = userID
+		if err := s.identities.Insert(ctx, identity); err != nil {
+			return fmt.Errorf("failed to insert identity: %w", err)
+		}
+		return nil
+	})
+}
+
+// ConfirmLink links the identity kept by ExternalLogin under linkToken. It must be called by the account the identity's email belongs to,
+// which proves the caller controls both the account and the identity.
+func (s *Service) ConfirmLink(ctx context.Context, userID uint64, linkToken string) (*Identity, error) {
+	identity, err := s.pending.Consume(ctx, hashChallenge(linkToken))
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return nil, ErrInvalidLinkToken
+		}
+		return nil, err
+	}
+	if identity.UserID != userID {
+		return nil, ErrInvalidLinkToken
+	}
+
+	if err := s.LinkIdentity(ctx, userID, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// UnlinkIdentity removes the identity unless it is the last way the user can log in.
+func (s *Service) UnlinkIdentity(ctx context.Context, userID, identityID uint64) error {
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		// locking the identities serializes concurrent unlinks, otherwise two of them could remove the last two identities
+		identities, err := s.identities.LockByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for _, i := range identities {
+			if i.ID == identityID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return constants.ErrNotFound
+		}
+
+		if len(identities) == 1 {
+			userData, err := s.user.GetByID(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if !userData.HasPassword() {
+				return ErrLastLoginMethod
+			}
+		}
+
+		return s.identities.Delete(ctx, userID, identityID)
+	})
+}
+
+func (s *Service) requireLink(ctx context.Context, identity *Identity, userID uint64) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	plain := base64.RawURLEncoding.EncodeToString(b)
+
+	pending := *identity
+	pending.UserID = userID
+	if err := s.pending.Save(ctx, hashChallenge(plain), &pending, pendingLinkTTL); err != nil {
+		return fmt.Errorf("failed to save pending link: %w", err)
+	}
+
+	return &LinkRequiredError{
+		LinkToken: plain,
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+		ExpiresAt: time.Now().Add(pendingLinkTTL).UTC(),
+	}
+}
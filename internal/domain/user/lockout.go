@@ -0,0 +1,183 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var ErrAccountLocked = errors.New("too many failed login attempts, try again later")
+
+// LockedError is returned while the account or the IP is locked. It wraps ErrAccountLocked.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string { return ErrAccountLocked.Error() }
+func (e *LockedError) Unwrap() error { return ErrAccountLocked }
+
+// AttemptRepository counts failed login attempts and keeps temporary locks. Keys are opaque, see accountKey and ipKey.
+type AttemptRepository interface {
+	// RecordFailure increments the counter of the key and returns its new value. Counter expires window after the first failure.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Failures(ctx context.Context, key string) (int, error)
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+	// LockedFor returns the remaining lock duration, or zero if the key is not locked.
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, keys ...string) error
+}
+
+type AccountLockedEvent struct {
+	Email       string
+	IP          string
+	Failures    int
+	LockedUntil time.Time
+}
+
+// LockoutNotifier is notified when an account gets locked.
+type LockoutNotifier interface {
+	AccountLocked(ctx context.Context, event AccountLockedEvent)
+}
+
+type logNotifier struct {
+	log *slog.Logger
+}
+
+// NewLogNotifier returns a notifier that only logs the lock.
+func NewLogNotifier(log *slog.Logger) LockoutNotifier {
+	return logNotifier{log: log}
+}
+
+func (n logNotifier) AccountLocked(ctx context.Context, e AccountLockedEvent) {
+	n.log.Warn("account locked after failed login attempts", "email", e.Email, "ip", e.IP, "failures", e.Failures, "locked_until", e.LockedUntil)
+}
+
+type LockoutConfig struct {
+	// MaxAccountFailures locks the account after that many failures within Window.
+	MaxAccountFailures int
+	// MaxIPFailures locks the IP after that many failures within Window, across all accounts.
+	MaxIPFailures int
+	Window        time.Duration
+	LockDuration  time.Duration
+	// Every failure after the first one doubles the delay of the next attempt, starting at BaseDelay and capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultLockoutConfig() LockoutConfig {
+	return LockoutConfig{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      50,
+		Window:             15 * time.Minute,
+		LockDuration:       15 * time.Minute,
+		BaseDelay:          250 * time.Millisecond,
+		MaxDelay:           4 * time.Second,
+	}
+}
+
+// Lockout protects password login from brute force. Accounts are tracked by email, so unknown emails behave the same as existing ones.
+type Lockout struct {
+	repo     AttemptRepository
+	conf     LockoutConfig
+	notifier LockoutNotifier
+}
+
+func NewLockout(repo AttemptRepository, conf LockoutConfig, notifier LockoutNotifier) *Lockout {
+	return &Lockout{repo: repo, conf: conf, notifier: notifier}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns LockedError if the account or IP is locked, otherwise it waits the progressive delay based on previous account failures.
+func (l *Lockout) Check(ctx context.Context, email, ip string) error {
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		ttl, err := l.repo.LockedFor(ctx, key)
+		if err != nil {
+			return err
+		}
+		if ttl > 0 {
+			return &LockedError{RetryAfter: ttl}
+		}
+	}
+
+	failures, err := l.repo.Failures(ctx, accountKey(email))
+	if err != nil {
+		return err
+	}
+
+	return sleepCtx(ctx, l.delay(failures))
+}
+
+func (l *Lockout) delay(failures int) time.Duration {
+	if failures <= 0 || l.conf.BaseDelay <= 0 {
+		return 0
+	}
+	d := l.conf.BaseDelay
+	for i := 1; i < failures && d < l.conf.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, l.conf.MaxDelay)
+}
+
+// Fail records the failed attempt and locks the account or IP when they reach their limit.
+func (l *Lockout) Fail(ctx context.Context, email, ip string) error {
+	accountFailures, err := l.repo.RecordFailure(ctx, accountKey(email), l.conf.Window)
+	if err != nil {
+		return err
+	}
+	ipFailures, err := l.repo.RecordFailure(ctx, ipKey(ip), l.conf.Window)
+	if err != nil {
+		return err
+	}
+
+	if ipFailures >= l.conf.MaxIPFailures {
+		if err := l.repo.Lock(ctx, ipKey(ip), l.conf.LockDuration); err != nil {
+			return err
+		}
+	}
+
+	if accountFailures >= l.conf.MaxAccountFailures {
+		if err := l.repo.Lock(ctx, accountKey(email), l.conf.LockDuration); err != nil {
+			return err
+		}
+		// counter starts over once the lock expires
+		if err := l.repo.Reset(ctx, accountKey(email)); err != nil {
+			return err
+		}
+		l.notifier.AccountLocked(ctx, AccountLockedEvent{
+			Email:       email,
+			IP:          ip,
+			Failures:    accountFailures,
+			LockedUntil: time.Now().Add(l.conf.LockDuration).UTC(),
+		})
+	}
+
+	return nil
+}
+
+// Succeed clears failures of the account. IP failures are kept, a successful login on one account must not reset guessing on others.
+func (l *Lockout) Succeed(ctx context.Context, email string) error {
+	return l.repo.Reset(ctx, accountKey(email))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,188 @@
+package user_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testLockoutConfig() user.LockoutConfig {
+	conf := user.DefaultLockoutConfig()
+	conf.BaseDelay = 0
+	return conf
+}
+
+func TestUserService_Login_Lockout(t *testing.T) {
+	ctx := context.Background()
+	const (
+		email      = "john@gmail.com"
+		accountKey = "account:john@gmail.com"
+		ipKey      = "ip:10.0.0.1"
+	)
+	conf := testLockoutConfig()
+	client := token.Client{IP: "10.0.0.1", UserAgent: "test"}
+
+	setup := func() (*user.Service, *mocks.MockRepository, *mocks.MockAttemptRepository, *mocks.MockLockoutNotifier) {
+		repo := new(mocks.MockRepository)
+		attempts := new(mocks.MockAttemptRepository)
+		notifier := new(mocks.MockLockoutNotifier)
+		lockout := user.NewLockout(attempts, conf, notifier)
+		return user.NewService(repo, nil, nil, nil, nil, nil, lockout, nil, mocks.Transactor{}), repo, attempts, notifier
+	}
+
+	t.Run("unknown email is reported as invalid credentials", func(t *testing.T) {
+		svc, repo, attempts, _ := setup()
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, accountKey).Return(0, nil)
+		repo.On("GetByEmail", ctx, email).Return(nil, constants.ErrNotFound)
+		attempts.On("RecordFailure", ctx, accountKey, conf.Window).Return(1, nil)
+		attempts.On("RecordFailure", ctx, ipKey, conf.Window).Return(1, nil)
+
+		_, _, _, err := svc.Login(ctx, email, "password1", client)
+		require.ErrorIs(t, err, user.ErrInvalidCredentials)
+		attempts.AssertNotCalled(t, "Lock", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("user without password is reported as invalid credentials", func(t *testing.T) {
+		svc, repo, attempts, _ := setup()
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, accountKey).Return(0, nil)
+		repo.On("GetByEmail", ctx, email).Return(&user.User{ID: 1, Email: email}, nil)
+		attempts.On("RecordFailure", ctx, mock.Anything, conf.Window).Return(1, nil)
+
+		_, _, _, err := svc.Login(ctx, email, "password1", client)
+		require.ErrorIs(t, err, user.ErrInvalidCredentials)
+	})
+
+	t.Run("locks the account and notifies on the last allowed failure", func(t *testing.T) {
+		svc, repo, attempts, notifier := setup()
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, accountKey).Return(conf.MaxAccountFailures-1, nil)
+		repo.On("GetByEmail", ctx, email).Return(nil, constants.ErrNotFound)
+		attempts.On("RecordFailure", ctx, accountKey, conf.Window).Return(conf.MaxAccountFailures, nil)
+		attempts.On("RecordFailure", ctx, ipKey, conf.Window).Return(conf.MaxAccountFailures, nil)
+		attempts.On("Lock", ctx, accountKey, conf.LockDuration).Return(nil)
+		attempts.On("Reset", ctx, []string{accountKey}).Return(nil)
+		notifier.On("AccountLocked", ctx, mock.MatchedBy(func(e user.AccountLockedEvent) bool {
+			return e.Email == email && e.IP == "10.0.0.1" && e.Failures == conf.MaxAccountFailures
+		})).Return()
+
+		_, _, _, err := svc.Login(ctx, email, "password1", client)
+		require.ErrorIs(t, err, user.ErrInvalidCredentials)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("locked account is rejected before checking the password", func(t *testing.T) {
+		svc, repo, attempts, _ := setup()
+		attempts.On("LockedFor", ctx, accountKey).Return(10*time.Minute, nil)
+
+		_, _, _, err := svc.Login(ctx, email, "password1", client)
+		var locked *user.LockedError
+		require.ErrorAs(t, err, &locked)
+		require.ErrorIs(t, err, user.ErrAccountLocked)
+		require.Equal(t, 10*time.Minute, locked.RetryAfter)
+		repo.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("locked ip is rejected for any account", func(t *testing.T) {
+		svc, _, attempts, _ := setup()
+		attempts.On("LockedFor", ctx, accountKey).Return(time.Duration(0), nil)
+		attempts.On("LockedFor", ctx, ipKey).Return(time.Minute, nil)
+
+		_, _, _, err := svc.Login(ctx, email, "password1", client)
+		require.ErrorIs(t, err, user.ErrAccountLocked)
+	})
+}
+
+// memAttempts is an in-memory user.AttemptRepository, windows and lock durations are not enforced.
+type memAttempts struct {
+	mu       sync.Mutex
+	failures map[string]int
+	locks    map[string]time.Duration
+}
+
+func newMemAttempts() *memAttempts {
+	return &memAttempts{failures: map[string]int{}, locks: map[string]time.Duration{}}
+}
+
+func (m *memAttempts) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[key]++
+	return m.failures[key], nil
+}
+
+func (m *memAttempts) Failures(ctx context.Context, key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failures[key], nil
+}
+
+func (m *memAttempts) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.locks[key] = ttl
+	return nil
+}
+
+func (m *memAttempts) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.locks[key], nil
+}
+
+func (m *memAttempts) Reset(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.failures, k)
+	}
+	return nil
+}
+
+func TestUserService_Login_IPLockoutWithForgedForwardedFor(t *testing.T) {
+	ctx := context.Background()
+	conf := testLockoutConfig()
+	conf.MaxIPFailures = 3
+
+	proxies, err := iputil.ParseTrustedProxies("10.0.0.1")
+	require.NoError(t, err)
+	iputil.SetTrustedProxies(proxies)
+	t.Cleanup(func() { iputil.SetTrustedProxies(nil) })
+
+	for _, remote := range []string{"203.0.113.7", "10.0.0.1"} {
+		t.Run("remote "+remote, func(t *testing.T) {
+			repo := new(mocks.MockRepository)
+			repo.On("GetByEmail", ctx, mock.Anything).Return(nil, constants.ErrNotFound)
+			lockout := user.NewLockout(newMemAttempts(), conf, new(mocks.MockLockoutNotifier))
+			svc := user.NewService(repo, nil, nil, nil, nil, nil, lockout, nil, mocks.Transactor{})
+
+			login := func(i int) error {
+				// every attempt forges a new client address and targets another account, so only the ip counter can stop it
+				r := httptest.NewRequest("POST", "/user/login", nil)
+				r.RemoteAddr = remote + ":1234"
+				r.Header.Set("X-Forwarded-For", fmt.Sprintf("192.0.2.%d, 198.51.100.9", i))
+				client := token.Client{IP: iputil.ClientIP(r), UserAgent: "test"}
+
+				_, _, _, err := svc.Login(ctx, fmt.Sprintf("user%d@gmail.com", i), "password1", client)
+				return err
+			}
+
+			for i := range conf.MaxIPFailures {
+				require.ErrorIs(t, login(i), user.ErrInvalidCredentials)
+			}
+			require.ErrorIs(t, login(conf.MaxIPFailures), user.ErrAccountLocked)
+		})
+	}
+}
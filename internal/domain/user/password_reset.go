@@ -0,0 +1,209 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/pkg/mailer"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// resetSendTimeout bounds storing the token and sending the email, which run after Forgot returns.
+const resetSendTimeout = 30 * time.Second
+
+type ResetToken struct {
+	Hash      string
+	UserID    uint64
+	ExpiresAt time.Time
+}
+
+type ResetTokenRepository interface {
+	Insert(ctx context.Context, t *ResetToken) error
+	// Consume marks the token as used and returns its user. Unknown, used and expired tokens return constants.ErrNotFound.
+	Consume(ctx context.Context, tokenHash string, now time.Time) (uint64, error)
+	DeleteByUser(ctx context.Context, userID uint64) error
+}
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration
+	// ResetURL is the page that lets the user choose a new password. The token is appended as "token" query param.
+	ResetURL string
+}
+
+// PasswordResetService lets users recover their account through a single-use token sent by email.
+type PasswordResetService struct {
+	users     Repository
+	resets    ResetTokenRepository
+	tokens    *token.Service
+	mailer    mailer.Mailer
+	txManager transactor.Transactor
+	conf      PasswordResetConfig
+	log       *slog.Logger
+	// wg tracks reset emails still being sent, see Shutdown
+	wg sync.WaitGroup
+}
+
+func NewPasswordResetService(
+	users Repository,
+	resets ResetTokenRepository,
+	tokens *token.Service,
+	mailer mailer.Mailer,
+	txManager transactor.Transactor,
+	conf PasswordResetConfig,
+	log *slog.Logger,
+) *PasswordResetService {
+	if conf.TokenTTL <= 0 {
+		conf.TokenTTL = 30 * time.Minute
+	}
+	return &PasswordResetService{
+		users:     users,
+		resets:    resets,
+		tokens:    tokens,
+		mailer:    mailer,
+		txManager: txManager,
+		conf:      conf,
+		log:       log,
+	}
+}
+
+// Forgot emails a reset link if the account exists. It returns nil for unknown and disabled accounts as well, so the caller can not tell them apart.
+// The token is stored and the email sent in the background, so the response takes the same time whether the account exists or not.
+func (s *PasswordResetService) Forgot(ctx context.Context, email string) error {
+	u, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if u.DisabledAt != nil {
+		return nil
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resetSendTimeout)
+		defer cancel()
+
+		if err := s.send(ctx, u); err != nil {
+			s.log.Error("failed to send password reset email", "error", err, "user_id", u.ID)
+		}
+	}()
+
+	return nil
+}
+
+func (s *PasswordResetService) send(ctx context.Context, u *User) error {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	plain := base64.RawURLEncoding.EncodeToString(buf)
+
+	t := &ResetToken{
+		Hash:      hashResetToken(plain),
+		UserID:    u.ID,
+		ExpiresAt: time.Now().Add(s.conf.TokenTTL).UTC(),
+	}
+	if err := s.resets.Insert(ctx, t); err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your Tinylink password",
+		Text: fmt.Sprintf("Someone requested a password reset for your Tinylink account.\n\n"+
+			"Open the link below to choose a new password. It expires in %s and can be used only once.\n\n%s\n\n"+
+			"If you did not request it, you can ignore this email.\n",
+			s.conf.TokenTTL, s.resetLink(plain)),
+	}
+
+	return s.mailer.Send(ctx, msg)
+}
+
+// Shutdown waits for reset emails still being sent.
+func (s *PasswordResetService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *PasswordResetService) resetLink(plain string) string {
+	u, err := url.Parse(s.conf.ResetURL)
+	if err != nil {
+		return s.conf.ResetURL + "?token=" + url.QueryEscape(plain)
+	}
+	q := u.Query()
+	q.Set("token", plain)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Reset sets the new password and revokes all refresh tokens of the user, logging out every device. Other outstanding reset tokens are invalidated too.
+func (s *PasswordResetService) Reset(ctx context.Context, plainToken, newPassword string) error {
+	var userID uint64
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		userID, err = s.resets.Consume(ctx, hashResetToken(plainToken), time.Now().UTC())
+		if err != nil {
+			if errors.Is(err, constants.ErrNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+
+		u, err := s.users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if u.DisabledAt != nil {
+			return ErrInvalidResetToken
+		}
+
+		if err := u.Password.Set(newPassword); err != nil {
+			return err
+		}
+		if err := s.users.Update(ctx, u); err != nil {
+			return err
+		}
+
+		return s.resets.DeleteByUser(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokens.RevokeAll(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+func hashResetToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
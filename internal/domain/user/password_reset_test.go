@@ -0,0 +1,126 @@
+package user_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	mailerMocks "github.com/Kostaaa1/tinylink/internal/mocks/mailer"
+	tokenMocks "github.com/Kostaaa1/tinylink/internal/mocks/token"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/Kostaaa1/tinylink/pkg/mailer"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var resetLinkRx = regexp.MustCompile(`https://tinylink\.test/reset-password\?token=\S+`)
+
+func TestPasswordResetService(t *testing.T) {
+	ctx := context.Background()
+	const email = "john@gmail.com"
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	type deps struct {
+		users  *mocks.MockRepository
+		resets *mocks.MockResetTokenRepository
+		tokens *tokenMocks.MockRepository
+		mail   *mailerMocks.MockMailer
+	}
+
+	setup := func() (*user.PasswordResetService, deps) {
+		d := deps{
+			users:  new(mocks.MockRepository),
+			resets: new(mocks.MockResetTokenRepository),
+			tokens: new(tokenMocks.MockRepository),
+			mail:   new(mailerMocks.MockMailer),
+		}
+		svc := user.NewPasswordResetService(d.users, d.resets, token.NewService(d.tokens, nil, nil), d.mail, mocks.Transactor{},
+			user.PasswordResetConfig{TokenTTL: time.Hour, ResetURL: "https://tinylink.test/reset-password"}, log)
+		return svc, d
+	}
+
+	t.Run("unknown email does not send anything", func(t *testing.T) {
+		svc, d := setup()
+		d.users.On("GetByEmail", ctx, email).Return(nil, constants.ErrNotFound)
+
+		require.NoError(t, svc.Forgot(ctx, email))
+		require.NoError(t, svc.Shutdown(ctx))
+		d.resets.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+		d.mail.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("emails the token and stores only its hash", func(t *testing.T) {
+		svc, d := setup()
+		d.users.On("GetByEmail", ctx, email).Return(&user.User{ID: 7, Email: email}, nil)
+
+		var stored *user.ResetToken
+		d.resets.On("Insert", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*user.ResetToken)
+		}).Return(nil)
+
+		var sent mailer.Message
+		d.mail.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(mailer.Message)
+		}).Return(nil)
+
+		require.NoError(t, svc.Forgot(ctx, email))
+		require.NoError(t, svc.Shutdown(ctx))
+		require.Equal(t, email, sent.To)
+		require.Equal(t, uint64(7), stored.UserID)
+		require.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+
+		link, err := url.Parse(resetLinkRx.FindString(sent.Text))
+		require.NoError(t, err)
+		plain := link.Query().Get("token")
+		require.NotEmpty(t, plain)
+
+		sum := sha256.Sum256([]byte(plain))
+		require.Equal(t, hex.EncodeToString(sum[:]), stored.Hash)
+	})
+
+	t.Run("returns before the email is sent", func(t *testing.T) {
+		svc, d := setup()
+		d.users.On("GetByEmail", ctx, email).Return(&user.User{ID: 7, Email: email}, nil)
+		d.resets.On("Insert", mock.Anything, mock.Anything).Return(nil)
+
+		release := make(chan struct{})
+		d.mail.On("Send", mock.Anything, mock.Anything).Run(func(mock.Arguments) { <-release }).Return(nil)
+
+		require.NoError(t, svc.Forgot(ctx, email))
+		close(release)
+		require.NoError(t, svc.Shutdown(ctx))
+		d.mail.AssertExpectations(t)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		svc, d := setup()
+		d.resets.On("Consume", ctx, mock.Anything, mock.Anything).Return(nil, constants.ErrNotFound)
+
+		require.ErrorIs(t, svc.Reset(ctx, "nope", "new-password"), user.ErrInvalidResetToken)
+		d.users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("sets the password and revokes refresh tokens", func(t *testing.T) {
+		svc, d := setup()
+		u := &user.User{ID: 7, Email: email}
+		d.resets.On("Consume", ctx, mock.Anything, mock.Anything).Return(uint64(7), nil)
+		d.users.On("GetByID", ctx, uint64(7)).Return(u, nil)
+		d.users.On("Update", ctx, u).Return(nil)
+		d.resets.On("DeleteByUser", ctx, uint64(7)).Return(nil)
+		d.tokens.On("Revoke", ctx, uint64(7)).Return(nil)
+
+		require.NoError(t, svc.Reset(ctx, "token", "new-password"))
+		matches, _ := u.Password.Matches("new-password")
+		require.True(t, matches)
+		d.tokens.AssertExpectations(t)
+	})
+}
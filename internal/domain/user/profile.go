@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Kostaaa1/tinylink/pkg/validator"
+)
+
+// ProfileUpdate changes name and email of the user. Nil fields are left unchanged.
+type ProfileUpdate struct {
+	Name  *string
+	Email *string
+	// Version is optional, if set the update fails with ErrEditConflict unless it matches the version the user has
+	Version *int
+	// Reauth is required only when the email changes, see Service.Reauthenticate
+	Reauth Reauth
+}
+
+func ValidateProfileUpdate(v *validator.Validator, p ProfileUpdate) {
+	if p.Name != nil {
+		v.Check(strings.TrimSpace(*p.Name) != "", "name", "must be provided")
+		v.Check(len(*p.Name) <= 500, "name", "must not be more than 500 bytes long")
+	}
+	if p.Email != nil {
+		ValidateEmail(v, *p.Email)
+	}
+}
+
+// Me returns the user together with its roles.
+func (s *Service) Me(ctx context.Context, userID uint64) (*User, error) {
+	userData, err := s.user.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if userData.Roles, err = s.roles.Roles(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return userData, nil
+}
+
+// UpdateProfile saves the changes and reports whether the email changed. A new email must be confirmed with Reauth, since whoever
+// controls the email can reset the password, and it is unverified until the user follows the link sent to it.
+// A change of letter case only is not a new email and keeps the verification.
+func (s *Service) UpdateProfile(ctx context.Context, userID uint64, p ProfileUpdate) (*User, bool, error) {
+	userData, err := s.user.GetByID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if p.Version != nil && *p.Version != userData.Version {
+		return nil, false, ErrEditConflict
+	}
+
+	if p.Name != nil {
+		userData.Name = strings.TrimSpace(*p.Name)
+	}
+
+	emailChanged := false
+	if p.Email != nil {
+		emailChanged = !strings.EqualFold(*p.Email, userData.Email)
+		if emailChanged {
+			if err := s.Reauthenticate(ctx, userID, p.Reauth); err != nil {
+				return nil, false, err
+			}
+		}
+		userData.Email = *p.Email
+	}
+
+	if err := s.user.Update(ctx, userData); err != nil {
+		return nil, false, err
+	}
+
+	if userData.Roles, err = s.roles.Roles(ctx, userID); err != nil {
+		return nil, false, err
+	}
+
+	return userData, emailChanged, nil
+}
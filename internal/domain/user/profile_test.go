@@ -0,0 +1,99 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_UpdateProfile(t *testing.T) {
+	ctx := context.Background()
+	ptr := func(s string) *string { return &s }
+
+	setup := func(t *testing.T) (*user.Service, *mocks.MockRepository, *mocks.MockAttemptRepository) {
+		repo := new(mocks.MockRepository)
+		roles := new(mocks.MockRoleRepository)
+		attempts := new(mocks.MockAttemptRepository)
+		lockout := user.NewLockout(attempts, testLockoutConfig(), new(mocks.MockLockoutNotifier))
+
+		verifiedAt := time.Now()
+		u := &user.User{ID: 1, Name: "John", Email: "john@gmail.com", Version: 3, EmailVerifiedAt: &verifiedAt}
+		require.NoError(t, u.Password.Set("password1"))
+		repo.On("GetByID", ctx, uint64(1)).Return(u, nil)
+		roles.On("Roles", ctx, uint64(1)).Return([]string{"user"}, nil)
+
+		return user.NewService(repo, nil, nil, roles, nil, nil, lockout, nil, mocks.Transactor{}), repo, attempts
+	}
+
+	t.Run("updates name", func(t *testing.T) {
+		svc, repo, _ := setup(t)
+		repo.On("Update", ctx, mock.MatchedBy(func(u *user.User) bool { return u.Name == "Johnny" })).Return(nil)
+
+		u, emailChanged, err := svc.UpdateProfile(ctx, 1, user.ProfileUpdate{Name: ptr(" Johnny ")})
+		require.NoError(t, err)
+		require.False(t, emailChanged)
+		require.Equal(t, "Johnny", u.Name)
+		require.Equal(t, []string{"user"}, u.Roles)
+	})
+
+	t.Run("stale version is rejected", func(t *testing.T) {
+		svc, repo, _ := setup(t)
+		version := 2
+
+		_, _, err := svc.UpdateProfile(ctx, 1, user.ProfileUpdate{Name: ptr("Johnny"), Version: &version})
+		require.ErrorIs(t, err, user.ErrEditConflict)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("new email requires the password", func(t *testing.T) {
+		svc, repo, attempts := setup(t)
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+
+		_, _, err := svc.UpdateProfile(ctx, 1, user.ProfileUpdate{Email: ptr("new@gmail.com")})
+		require.ErrorIs(t, err, user.ErrReauthenticationRequired)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("new email is saved after reauthentication", func(t *testing.T) {
+		svc, repo, attempts := setup(t)
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+		repo.On("Update", ctx, mock.MatchedBy(func(u *user.User) bool { return u.Email == "new@gmail.com" })).Return(nil)
+
+		_, emailChanged, err := svc.UpdateProfile(ctx, 1, user.ProfileUpdate{
+			Email:  ptr("new@gmail.com"),
+			Reauth: user.Reauth{Password: "password1", IP: "10.0.0.1"},
+		})
+		require.NoError(t, err)
+		require.True(t, emailChanged)
+	})
+
+	t.Run("letter case change is not a new email", func(t *testing.T) {
+		svc, repo, attempts := setup(t)
+		repo.On("Update", ctx, mock.MatchedBy(func(u *user.User) bool { return u.Email == "John@gmail.com" })).Return(nil)
+
+		_, emailChanged, err := svc.UpdateProfile(ctx, 1, user.ProfileUpdate{Email: ptr("John@gmail.com")})
+		require.NoError(t, err)
+		require.False(t, emailChanged)
+		attempts.AssertNotCalled(t, "LockedFor", mock.Anything, mock.Anything)
+	})
+
+	t.Run("duplicate email is reported", func(t *testing.T) {
+		svc, repo, attempts := setup(t)
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+		repo.On("Update", ctx, mock.Anything).Return(user.ErrDuplicateEmail)
+
+		_, _, err := svc.UpdateProfile(ctx, 1, user.ProfileUpdate{
+			Email:  ptr("taken@gmail.com"),
+			Reauth: user.Reauth{Password: "password1", IP: "10.0.0.1"},
+		})
+		require.ErrorIs(t, err, user.ErrDuplicateEmail)
+	})
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+)
+
+// recentLoginWindow is how old the session of a user without password and two-factor authentication may be to confirm a sensitive action.
+const recentLoginWindow = 10 * time.Minute
+
+var ErrReauthenticationRequired = errors.New("confirm with your password and two-factor code, or log in again")
+
+// Reauth holds what the user confirms a sensitive action with.
+type Reauth struct {
+	Password string
+	// Code is a TOTP or recovery code, required if two-factor authentication is enabled
+	Code string
+	// RefreshToken identifies the session of the request. Users with neither password nor two-factor authentication must have logged in recently.
+	RefreshToken string
+	IP           string
+}
+
+// Reauthenticate checks the user still controls the account before a sensitive action. The password is required if the user has one
+// and the two-factor code if it is enabled. Wrong passwords and codes count towards the account lockout like failed logins.
+func (s *Service) Reauthenticate(ctx context.Context, userID uint64, creds Reauth) error {
+	userData, err := s.user.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	twoFactor := false
+	if s.twoFactor != nil {
+		if twoFactor, err = s.twoFactor.Enabled(ctx, userID); err != nil {
+			return fmt.Errorf("failed to check two-factor authentication: %w", err)
+		}
+	}
+
+	if !userData.HasPassword() && !twoFactor {
+		return s.requireRecentLogin(ctx, userID, creds.RefreshToken)
+	}
+
+	if err := s.lockout.Check(ctx, userData.Email, creds.IP); err != nil {
+		return err
+	}
+
+	if userData.HasPassword() {
+		if creds.Password == "" {
+			return ErrReauthenticationRequired
+		}
+		if matches, _ := userData.Password.Matches(creds.Password); !matches {
+			if err := s.lockout.Fail(ctx, userData.Email, creds.IP); err != nil {
+				return fmt.Errorf("failed to record login failure: %w", err)
+			}
+			return ErrInvalidCredentials
+		}
+	}
+
+	if twoFactor {
+		if creds.Code == "" {
+			return ErrReauthenticationRequired
+		}
+		if err := s.twoFactor.Verify(ctx, userID, creds.Code); err != nil {
+			if errors.Is(err, ErrInvalidTwoFactorCode) {
+				if err := s.lockout.Fail(ctx, userData.Email, creds.IP); err != nil {
+					return fmt.Errorf("failed to record login failure: %w", err)
+				}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) requireRecentLogin(ctx context.Context, userID uint64, refreshToken string) error {
+	if refreshToken == "" {
+		return ErrReauthenticationRequired
+	}
+
+	sess, err := s.tokens.CurrentSession(ctx, userID, refreshToken)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return ErrReauthenticationRequired
+		}
+		return err
+	}
+	if time.Since(sess.CreatedAt) > recentLoginWindow {
+		return ErrReauthenticationRequired
+	}
+	return nil
+}
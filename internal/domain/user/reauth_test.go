@@ -0,0 +1,89 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	tokenMocks "github.com/Kostaaa1/tinylink/internal/mocks/token"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_Reauthenticate(t *testing.T) {
+	ctx := context.Background()
+	const (
+		familyID = "7b0c5a0e-3f5e-4d7c-9a52-0c1c2d3e4f50"
+		ip       = "10.0.0.1"
+	)
+	conf := testLockoutConfig()
+
+	setup := func() (*user.Service, *mocks.MockRepository, *mocks.MockAttemptRepository, *tokenMocks.MockRepository) {
+		repo := new(mocks.MockRepository)
+		attempts := new(mocks.MockAttemptRepository)
+		tokens := new(tokenMocks.MockRepository)
+		lockout := user.NewLockout(attempts, conf, new(mocks.MockLockoutNotifier))
+		svc := user.NewService(repo, nil, nil, nil, token.NewService(tokens, nil, nil), nil, lockout, nil, mocks.Transactor{})
+		return svc, repo, attempts, tokens
+	}
+
+	withPassword := func(t *testing.T) *user.User {
+		u := &user.User{ID: 1, Email: "john@gmail.com"}
+		require.NoError(t, u.Password.Set("password1"))
+		return u
+	}
+
+	t.Run("accepts the current password", func(t *testing.T) {
+		svc, repo, attempts, _ := setup()
+		repo.On("GetByID", ctx, uint64(1)).Return(withPassword(t), nil)
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+
+		require.NoError(t, svc.Reauthenticate(ctx, 1, user.Reauth{Password: "password1", IP: ip}))
+	})
+
+	t.Run("wrong password counts as failed login", func(t *testing.T) {
+		svc, repo, attempts, _ := setup()
+		repo.On("GetByID", ctx, uint64(1)).Return(withPassword(t), nil)
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+		attempts.On("RecordFailure", ctx, mock.Anything, conf.Window).Return(1, nil)
+
+		err := svc.Reauthenticate(ctx, 1, user.Reauth{Password: "password2", IP: ip})
+		require.ErrorIs(t, err, user.ErrInvalidCredentials)
+		attempts.AssertNumberOfCalls(t, "RecordFailure", 2)
+	})
+
+	t.Run("missing password is required", func(t *testing.T) {
+		svc, repo, attempts, _ := setup()
+		repo.On("GetByID", ctx, uint64(1)).Return(withPassword(t), nil)
+		attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+		attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+
+		require.ErrorIs(t, svc.Reauthenticate(ctx, 1, user.Reauth{IP: ip}), user.ErrReauthenticationRequired)
+	})
+
+	t.Run("user without password needs a recent login", func(t *testing.T) {
+		svc, repo, _, tokens := setup()
+		repo.On("GetByID", ctx, uint64(1)).Return(&user.User{ID: 1, Email: "john@gmail.com"}, nil)
+		tokens.On("Get", ctx, familyID).Return(&token.Family{ID: familyID, UserID: 1, CreatedAt: time.Now().Add(-time.Minute)}, nil).Once()
+
+		require.NoError(t, svc.Reauthenticate(ctx, 1, user.Reauth{RefreshToken: familyID + ".secret"}))
+
+		tokens.On("Get", ctx, familyID).Return(&token.Family{ID: familyID, UserID: 1, CreatedAt: time.Now().Add(-time.Hour)}, nil).Once()
+		err := svc.Reauthenticate(ctx, 1, user.Reauth{RefreshToken: familyID + ".secret"})
+		require.ErrorIs(t, err, user.ErrReauthenticationRequired)
+	})
+
+	t.Run("session of another user is rejected", func(t *testing.T) {
+		svc, repo, _, tokens := setup()
+		repo.On("GetByID", ctx, uint64(1)).Return(&user.User{ID: 1, Email: "john@gmail.com"}, nil)
+		tokens.On("Get", ctx, familyID).Return(&token.Family{ID: familyID, UserID: 2, CreatedAt: time.Now()}, nil)
+
+		err := svc.Reauthenticate(ctx, 1, user.Reauth{RefreshToken: familyID + ".secret"})
+		require.ErrorIs(t, err, user.ErrReauthenticationRequired)
+	})
+}
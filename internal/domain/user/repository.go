@@ -2,13 +2,31 @@ package user
 
 import (
 	"context"
+	"time"
 )
 
 type Repository interface {
+	// Insert returns ErrDuplicateEmail if another user has the email.
 	Insert(ctx context.Context, user *User) error
-	InsertGoogleUser(ctx context.Context, googleUser *GoogleUser) error
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByID(ctx context.Context, id uint64) (*User, error)
+	// Update saves name, email and password if the version of the user is still current, otherwise returns ErrEditConflict.
+	// Changing the email clears its verification. Returns ErrDuplicateEmail if another user has the email.
 	Update(ctx context.Context, user *User) error
-	Delete(ctx context.Context, userID string) error
+	// MarkEmailVerified verifies the email of the user. Returns constants.ErrNotFound if the user no longer has that email.
+	MarkEmailVerified(ctx context.Context, userID uint64, email string, at time.Time) error
+	// Delete removes the user, rows owned by the user are removed by ON DELETE CASCADE.
+	Delete(ctx context.Context, userID uint64) error
+}
+
+// LinkClaimer reassigns links created anonymously by a guest to a user. Links whose alias the user already owns are left untouched and reported as conflicts.
+type LinkClaimer interface {
+	ClaimGuestLinks(ctx context.Context, guestUUID string, userID uint64) (claimed []string, conflicts []string, err error)
+}
+
+// RoleRepository manages the roles assigned to users. Role names match auth.Role* constants.
+type RoleRepository interface {
+	Roles(ctx context.Context, userID uint64) ([]string, error)
+	Assign(ctx context.Context, userID uint64, role string) error
+	Revoke(ctx context.Context, userID uint64, role string) error
 }
@@ -2,109 +2,307 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/core/transactor"
+	"github.com/Kostaaa1/tinylink/internal/constants"
 	"github.com/Kostaaa1/tinylink/internal/domain/auth"
 	"github.com/Kostaaa1/tinylink/internal/domain/token"
 )
 
 type Service struct {
-	user  Repository
-	token token.Repository
+	user       Repository
+	identities IdentityRepository
+	pending    PendingLinkRepository
+	roles      RoleRepository
+	tokens     *token.Service
+	links      LinkClaimer
+	lockout    *Lockout
+	twoFactor  *TwoFactorService
+	txManager  transactor.Transactor
 }
 
-func NewService(user Repository, token token.Repository) *Service {
+// twoFactor is optional, if nil logins never require a second step.
+func NewService(
+	user Repository,
+	identities IdentityRepository,
+	pendingLinks PendingLinkRepository,
+	roles RoleRepository,
+	tokens *token.Service,
+	links LinkClaimer,
+	lockout *Lockout,
+	twoFactor *TwoFactorService,
+	txManager transactor.Transactor,
+) *Service {
 	return &Service{
-		user:  user,
-		token: token,
+		user:       user,
+		identities: identities,
+		pending:    pendingLinks,
+		roles:      roles,
+		tokens:     tokens,
+		links:      links,
+		lockout:    lockout,
+		twoFactor:  twoFactor,
+		txManager:  txManager,
 	}
 }
 
-func (s *Service) HandleGoogleLogin(ctx context.Context, googleUser *GoogleUser) (*User, error) {
-	user := &User{
-		Name:   googleUser.Name,
-		Email:  googleUser.Email,
-		Google: googleUser,
-	}
-
-	// err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
-	// 	existingUser, err := s.user.GetByEmail(ctx, user.Email)
-	// 	if err != nil && errors.Is(err, constants.ErrNotFound) {
-	// 		if err := s.user.Insert(ctx, user); err != nil {
-	// 			return fmt.Errorf("failed to insert user: %w", err)
-	// 		}
-	// 	} else {
-	// 		if existingUser.Google == nil {
-	// 			user.Google.UserID = existingUser.ID
-	// 			if err := s.user.InsertGoogleUser(ctx, user.Google); err != nil {
-	// 				return fmt.Errorf("failed to insert google user: %w", err)
-	// 			}
-	// 		}
-	// 	}
-
-	// 	user, err = s.user.GetByEmail(ctx, user.Email)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-
-	// 	return nil
-	// })
-
-	// if err != nil {
-	// 	return nil, err
-	// }
-
-	return user, nil
+// ClaimGuestLinks moves links created with guestUUID to the user. Should be called right after the guest authenticates.
+func (s *Service) ClaimGuestLinks(ctx context.Context, guestUUID string, userID uint64) (*ClaimResult, error) {
+	res := &ClaimResult{Claimed: []string{}, Conflicts: []string{}}
+	if guestUUID == "" {
+		return res, nil
+	}
+
+	claimed, conflicts, err := s.links.ClaimGuestLinks(ctx, guestUUID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim guest links: %w", err)
+	}
+
+	res.Claimed = append(res.Claimed, claimed...)
+	res.Conflicts = append(res.Conflicts, conflicts...)
+	return res, nil
+}
+
+// ExternalLogin logs in the user linked to the provider identity. On the first login with the identity it creates a new user. If the
+// email already belongs to a user, the identity is not linked automatically, it returns LinkRequiredError and the owner has to log in
+// and confirm the link, so whoever controls the email at the provider can not take over the account.
+func (s *Service) ExternalLogin(ctx context.Context, identity *Identity, client token.Client) (*User, string, string, error) {
+	if identity.Email == "" {
+		return nil, "", "", ErrIdentityEmailRequired
+	}
+
+	var user *User
+	now := time.Now().UTC()
+
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		existing, err := s.identities.Get(ctx, identity.Provider, identity.Subject)
+		switch {
+		case err == nil:
+			identity.ID, identity.UserID = existing.ID, existing.UserID
+			if err := s.identities.Touch(ctx, identity, now); err != nil {
+				return fmt.Errorf("failed to update identity: %w", err)
+			}
+			user, err = s.user.GetByID(ctx, existing.UserID)
+			return err
+		case !errors.Is(err, constants.ErrNotFound):
+			return err
+		}
+
+		user, err = s.user.GetByEmail(ctx, identity.Email)
+		switch {
+		case errors.Is(err, constants.ErrNotFound):
+			user = &User{Name: identity.Name, Email: identity.Email}
+			if user.Name == "" {
+				user.Name, _, _ = strings.Cut(identity.Email, "@")
+			}
+			if identity.EmailVerified {
+				user.EmailVerifiedAt = &now
+			}
+			if err := s.user.Insert(ctx, user); err != nil {
+				return fmt.Errorf("failed to insert user: %w", err)
+			}
+			if err := s.roles.Assign(ctx, user.ID, auth.RoleUser); err != nil {
+				return fmt.Errorf("failed to assign role: %w", err)
+			}
+		case err != nil:
+			return err
+		default:
+			return s.requireLink(ctx, identity, user.ID)
+		}
+
+		identity.UserID = user.ID
+		identity.LastLoginAt = &now
+		if err := s.identities.Insert(ctx, identity); err != nil {
+			return fmt.Errorf("failed to insert identity: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if user.DisabledAt != nil {
+		return nil, "", "", ErrAccountDisabled
+	}
+
+	if err := s.requireSecondFactor(ctx, user.ID); err != nil {
+		return nil, "", "", err
+	}
+
+	return s.startSession(ctx, user, client)
 }
 
+// Register inserts the user and assigns the default role.
 func (s *Service) Register(ctx context.Context, userData *User) error {
-	return s.user.Insert(ctx, userData)
+	return s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.user.Insert(ctx, userData); err != nil {
+			return err
+		}
+		if err := s.roles.Assign(ctx, userData.ID, auth.RoleUser); err != nil {
+			return fmt.Errorf("failed to assign role: %w", err)
+		}
+		userData.Roles = []string{auth.RoleUser}
+		return nil
+	})
 }
 
-func (s *Service) Login(ctx context.Context, email, password string) (*User, string, string, error) {
-	userData, err := s.user.GetByEmail(ctx, email)
-	if err != nil {
+// Login verifies the credentials and starts a new refresh token family for the client device.
+// Unknown email, missing password and wrong password all return ErrInvalidCredentials. Repeated failures are delayed and eventually locked, see Lockout.
+// If the account has two-factor authentication enabled, it returns TwoFactorRequiredError and the login is finished by CompleteLogin.
+func (s *Service) Login(ctx context.Context, email, password string, client token.Client) (*User, string, string, error) {
+	if err := s.lockout.Check(ctx, email, client.IP); err != nil {
 		return nil, "", "", err
 	}
 
-	if len(userData.Password.Hash) > 0 {
-		matches, _ := userData.Password.Matches(password)
-		if !matches {
-			return nil, "", "", ErrInvalidCredentials
+	userData, err := s.authenticate(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			if err := s.lockout.Fail(ctx, email, client.IP); err != nil {
+				return nil, "", "", fmt.Errorf("failed to record login failure: %w", err)
+			}
 		}
-	} else {
-		return nil, "", "", ErrNoUserPasswordSet
+		return nil, "", "", err
 	}
 
-	refreshToken := auth.GenerateRefreshToken()
-	if err := s.token.Save(ctx, userData.ID, refreshToken, auth.RefreshTokenTTL); err != nil {
+	// disabled state is revealed only to someone who knows the password
+	if userData.DisabledAt != nil {
+		return nil, "", "", ErrAccountDisabled
+	}
+
+	// failures are cleared only after the second step, so guessing codes counts towards the lockout
+	if err := s.requireSecondFactor(ctx, userData.ID); err != nil {
 		return nil, "", "", err
 	}
 
-	accessToken, err := auth.GenerateAccessToken(userData.ID, nil)
+	if err := s.lockout.Succeed(ctx, email); err != nil {
+		return nil, "", "", fmt.Errorf("failed to reset login failures: %w", err)
+	}
+
+	return s.startSession(ctx, userData, client)
+}
+
+// CompleteLogin finishes a two-step login with a TOTP or recovery code. A wrong code is recorded as a failed login of the account.
+func (s *Service) CompleteLogin(ctx context.Context, challengeToken, code string, client token.Client) (*User, string, string, error) {
+	if s.twoFactor == nil {
+		return nil, "", "", ErrInvalidChallenge
+	}
+
+	userID, err := s.twoFactor.CompleteChallenge(ctx, challengeToken, code)
 	if err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.recordSecondFactorFailure(ctx, userID, client.IP); err != nil {
+				return nil, "", "", err
+			}
+		}
 		return nil, "", "", err
 	}
 
-	return userData, accessToken, refreshToken, err
+	userData, err := s.user.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if userData.DisabledAt != nil {
+		return nil, "", "", ErrAccountDisabled
+	}
+
+	if err := s.lockout.Succeed(ctx, userData.Email); err != nil {
+		return nil, "", "", fmt.Errorf("failed to reset login failures: %w", err)
+	}
+
+	return s.startSession(ctx, userData, client)
 }
 
-func (s *Service) ChangePassword(ctx context.Context, userID uint64, oldPW, newPW string) error {
-	// userData := &user.User{ID: userID}
-	// if err := userData.Password.Set(newPW); err != nil {
-	// 	return err
-	// }
+// requireSecondFactor returns TwoFactorRequiredError with a new challenge if the user has two-factor authentication enabled.
+func (s *Service) requireSecondFactor(ctx context.Context, userID uint64) error {
+	if s.twoFactor == nil {
+		return nil
+	}
+
+	enabled, err := s.twoFactor.Enabled(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+	if !enabled {
+		return nil
+	}
+
+	challenge, err := s.twoFactor.Challenge(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to create login challenge: %w", err)
+	}
+	return challenge
+}
 
+func (s *Service) recordSecondFactorFailure(ctx context.Context, userID uint64, ip string) error {
 	userData, err := s.user.GetByID(ctx, userID)
 	if err != nil {
 		return err
 	}
+	if err := s.lockout.Fail(ctx, userData.Email, ip); err != nil {
+		return fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return nil
+}
 
-	matches, err := userData.Password.Matches(oldPW)
+// startSession loads the roles and issues the access and refresh tokens of an authenticated user.
+func (s *Service) startSession(ctx context.Context, userData *User, client token.Client) (*User, string, string, error) {
+	var err error
+	userData.Roles, err = s.roles.Roles(ctx, userData.ID)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to load roles: %w", err)
+	}
+
+	refreshToken, err := s.tokens.Issue(ctx, userData.ID, client)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	accessToken, err := auth.GenerateAccessToken(userData.ID, userData.Roles)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return userData, accessToken, refreshToken, nil
+}
+
+func (s *Service) authenticate(ctx context.Context, email, password string) (*User, error) {
+	userData, err := s.user.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, constants.ErrNotFound):
+		userData = nil
+	case err != nil:
+		return nil, err
+	}
+
+	if userData == nil || !userData.HasPassword() {
+		dummy := dummyPassword()
+		dummy.Matches(password)
+		return nil, ErrInvalidCredentials
+	}
+
+	if matches, _ := userData.Password.Matches(password); !matches {
+		return nil, ErrInvalidCredentials
+	}
+
+	return userData, nil
+}
+
+func (s *Service) ChangePassword(ctx context.Context, userID uint64, oldPW, newPW string) error {
+	userData, err := s.user.GetByID(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	if !matches {
+	// users who signed up through an identity provider have no password to change, they can set one through the password reset flow
+	if !userData.HasPassword() {
+		return ErrNoUserPasswordSet
+	}
+
+	if matches, _ := userData.Password.Matches(oldPW); !matches {
 		return ErrInvalidCredentials
 	}
 
@@ -119,6 +317,11 @@ func (s *Service) ChangePassword(ctx context.Context, userID uint64, oldPW, newP
 	return nil
 }
 
-func (s *Service) Logout(ctx context.Context, userID uint64) error {
-	return s.token.Revoke(ctx, userID)
+// Logout revokes the refresh token family of the current device. Other devices stay logged in.
+func (s *Service) Logout(ctx context.Context, userID uint64, refreshToken string) error {
+	err := s.tokens.RevokeToken(ctx, userID, refreshToken)
+	if errors.Is(err, constants.ErrNotFound) || errors.Is(err, token.ErrMalformedToken) {
+		return nil
+	}
+	return err
 }
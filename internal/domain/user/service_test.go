@@ -0,0 +1,283 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	tokenMocks "github.com/Kostaaa1/tinylink/internal/mocks/token"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_ExternalLogin(t *testing.T) {
+	email := "john@gmail.com"
+	client := token.Client{IP: "10.0.0.1"}
+
+	type deps struct {
+		users      *mocks.MockRepository
+		identities *mocks.MockIdentityRepository
+		pending    *mocks.MockPendingLinkRepository
+		roles      *mocks.MockRoleRepository
+		tokens     *tokenMocks.MockRepository
+	}
+
+	setup := func() (*user.Service, deps) {
+		d := deps{
+			users:      new(mocks.MockRepository),
+			identities: new(mocks.MockIdentityRepository),
+			pending:    new(mocks.MockPendingLinkRepository),
+			roles:      new(mocks.MockRoleRepository),
+			tokens:     new(tokenMocks.MockRepository),
+		}
+		svc := user.NewService(d.users, d.identities, d.pending, d.roles, token.NewService(d.tokens, nil, nil), nil, nil, nil, mocks.Transactor{})
+		return svc, d
+	}
+
+	t.Run("creates new user", func(t *testing.T) {
+		ctx := context.Background()
+		svc, d := setup()
+
+		identity := &user.Identity{Provider: "github", Subject: "42", Email: email, EmailVerified: true}
+
+		d.identities.On("Get", ctx, "github", "42").Return(nil, constants.ErrNotFound)
+		d.users.On("GetByEmail", ctx, email).Return(nil, constants.ErrNotFound)
+		d.users.On("Insert", ctx, mock.MatchedBy(func(u *user.User) bool {
+			return u.Email == email && u.Name == "john" && u.EmailVerified()
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*user.User).ID = 7
+		}).Return(nil)
+		d.roles.On("Assign", ctx, uint64(7), auth.RoleUser).Return(nil)
+		d.identities.On("Insert", ctx, identity).Return(nil)
+		d.roles.On("Roles", ctx, uint64(7)).Return([]string{auth.RoleUser}, nil)
+		d.tokens.On("Create", ctx, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		u, access, refresh, err := svc.ExternalLogin(ctx, identity, client)
+		require.NoError(t, err)
+		require.Equal(t, uint64(7), u.ID)
+		require.Equal(t, uint64(7), identity.UserID)
+		require.Equal(t, []string{auth.RoleUser}, u.Roles)
+		require.NotEmpty(t, access)
+		require.NotEmpty(t, refresh)
+	})
+
+	t.Run("existing email requires confirming the link", func(t *testing.T) {
+		ctx := context.Background()
+		svc, d := setup()
+
+		verifiedAt := time.Now()
+		existing := &user.User{ID: 5, Email: email, EmailVerifiedAt: &verifiedAt}
+		identity := &user.Identity{Provider: "google", Subject: "g-1", Email: email, EmailVerified: true}
+
+		var savedHash string
+		d.identities.On("Get", ctx, "google", "g-1").Return(nil, constants.ErrNotFound)
+		d.users.On("GetByEmail", ctx, email).Return(existing, nil)
+		d.pending.On("Save", ctx, mock.Anything, mock.MatchedBy(func(i *user.Identity) bool {
+			return i.UserID == existing.ID && i.Subject == "g-1"
+		}), mock.Anything).Run(func(args mock.Arguments) {
+			savedHash = args.String(1)
+		}).Return(nil)
+
+		_, _, _, err := svc.ExternalLogin(ctx, identity, client)
+		var linkRequired *user.LinkRequiredError
+		require.ErrorAs(t, err, &linkRequired)
+		require.ErrorIs(t, err, user.ErrLinkRequired)
+		require.Equal(t, email, linkRequired.Email)
+		require.NotEmpty(t, linkRequired.LinkToken)
+		require.NotEqual(t, linkRequired.LinkToken, savedHash, "only the hash of the link token is stored")
+		d.identities.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+		d.tokens.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("known identity logs in its user", func(t *testing.T) {
+		ctx := context.Background()
+		svc, d := setup()
+
+		// the email changed at the provider, the identity still belongs to the linked user
+		identity := &user.Identity{Provider: "github", Subject: "42", Email: "new@example.com"}
+		linked := &user.User{ID: 3, Email: email}
+
+		d.identities.On("Get", ctx, "github", "42").Return(&user.Identity{ID: 11, UserID: 3, Provider: "github", Subject: "42"}, nil)
+		d.identities.On("Touch", ctx, identity, mock.Anything).Return(nil)
+		d.users.On("GetByID", ctx, uint64(3)).Return(linked, nil)
+		d.roles.On("Roles", ctx, uint64(3)).Return([]string{auth.RoleUser}, nil)
+		d.tokens.On("Create", ctx, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		u, _, _, err := svc.ExternalLogin(ctx, identity, client)
+		require.NoError(t, err)
+		require.Equal(t, linked.ID, u.ID)
+		require.Equal(t, uint64(11), identity.ID)
+		d.users.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("requires email", func(t *testing.T) {
+		svc, _ := setup()
+		_, _, _, err := svc.ExternalLogin(context.Background(), &user.Identity{Provider: "github", Subject: "42"}, client)
+		require.ErrorIs(t, err, user.ErrIdentityEmailRequired)
+	})
+}
+
+func TestUserService_LinkIdentity(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func() (*user.Service, *mocks.MockIdentityRepository, *mocks.MockPendingLinkRepository) {
+		identities := new(mocks.MockIdentityRepository)
+		pending := new(mocks.MockPendingLinkRepository)
+		return user.NewService(nil, identities, pending, nil, nil, nil, nil, nil, mocks.Transactor{}), identities, pending
+	}
+
+	t.Run("identity of another user conflicts", func(t *testing.T) {
+		svc, identities, _ := setup()
+		identity := &user.Identity{Provider: "github", Subject: "42"}
+		identities.On("Get", ctx, "github", "42").Return(&user.Identity{ID: 1, UserID: 2}, nil)
+
+		require.ErrorIs(t, svc.LinkIdentity(ctx, 1, identity), user.ErrIdentityConflict)
+		identities.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	})
+
+	t.Run("confirm links the pending identity", func(t *testing.T) {
+		svc, identities, pending := setup()
+		pendingIdentity := &user.Identity{UserID: 5, Provider: "google", Subject: "g-1"}
+		pending.On("Consume", ctx, mock.Anything).Return(pendingIdentity, nil)
+		identities.On("Get", ctx, "google", "g-1").Return(nil, constants.ErrNotFound)
+		identities.On("Insert", ctx, pendingIdentity).Return(nil)
+
+		identity, err := svc.ConfirmLink(ctx, 5, "token")
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), identity.UserID)
+	})
+
+	t.Run("confirm by another user fails", func(t *testing.T) {
+		svc, identities, pending := setup()
+		pending.On("Consume", ctx, mock.Anything).Return(&user.Identity{UserID: 5, Provider: "google", Subject: "g-1"}, nil)
+
+		_, err := svc.ConfirmLink(ctx, 6, "token")
+		require.ErrorIs(t, err, user.ErrInvalidLinkToken)
+		identities.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	})
+
+	t.Run("expired link token", func(t *testing.T) {
+		svc, _, pending := setup()
+		pending.On("Consume", ctx, mock.Anything).Return(nil, constants.ErrNotFound)
+
+		_, err := svc.ConfirmLink(ctx, 5, "token")
+		require.ErrorIs(t, err, user.ErrInvalidLinkToken)
+	})
+}
+
+func TestUserService_UnlinkIdentity(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func() (*user.Service, *mocks.MockRepository, *mocks.MockIdentityRepository) {
+		users := new(mocks.MockRepository)
+		identities := new(mocks.MockIdentityRepository)
+		return user.NewService(users, identities, nil, nil, nil, nil, nil, nil, mocks.Transactor{}), users, identities
+	}
+
+	t.Run("last identity without password", func(t *testing.T) {
+		svc, users, identities := setup()
+		identities.On("LockByUser", ctx, uint64(1)).Return([]*user.Identity{{ID: 3, UserID: 1}}, nil)
+		users.On("GetByID", ctx, uint64(1)).Return(&user.User{ID: 1}, nil)
+
+		require.ErrorIs(t, svc.UnlinkIdentity(ctx, 1, 3), user.ErrLastLoginMethod)
+		identities.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("last identity with password", func(t *testing.T) {
+		svc, users, identities := setup()
+		u := &user.User{ID: 1}
+		u.SetPassword([]byte("hash"))
+		identities.On("LockByUser", ctx, uint64(1)).Return([]*user.Identity{{ID: 3, UserID: 1}}, nil)
+		users.On("GetByID", ctx, uint64(1)).Return(u, nil)
+		identities.On("Delete", ctx, uint64(1), uint64(3)).Return(nil)
+
+		require.NoError(t, svc.UnlinkIdentity(ctx, 1, 3))
+	})
+
+	t.Run("another identity left", func(t *testing.T) {
+		svc, users, identities := setup()
+		identities.On("LockByUser", ctx, uint64(1)).Return([]*user.Identity{{ID: 3, UserID: 1}, {ID: 4, UserID: 1}}, nil)
+		identities.On("Delete", ctx, uint64(1), uint64(3)).Return(nil)
+
+		require.NoError(t, svc.UnlinkIdentity(ctx, 1, 3))
+		users.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("identity of another user", func(t *testing.T) {
+		svc, _, identities := setup()
+		identities.On("LockByUser", ctx, uint64(1)).Return([]*user.Identity{{ID: 3, UserID: 1}}, nil)
+
+		require.ErrorIs(t, svc.UnlinkIdentity(ctx, 1, 9), constants.ErrNotFound)
+	})
+}
+
+func TestUserService_ClaimGuestLinks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reports claimed and conflicting aliases", func(t *testing.T) {
+		links := new(mocks.MockLinkClaimer)
+		svc := user.NewService(nil, nil, nil, nil, nil, links, nil, nil, mocks.Transactor{})
+
+		links.On("ClaimGuestLinks", ctx, "guest-1", uint64(7)).Return([]string{"abc"}, []string{"taken"}, nil)
+
+		res, err := svc.ClaimGuestLinks(ctx, "guest-1", 7)
+		require.NoError(t, err)
+		require.Equal(t, []string{"abc"}, res.Claimed)
+		require.Equal(t, []string{"taken"}, res.Conflicts)
+	})
+
+	t.Run("skips claiming without guest uuid", func(t *testing.T) {
+		links := new(mocks.MockLinkClaimer)
+		svc := user.NewService(nil, nil, nil, nil, nil, links, nil, nil, mocks.Transactor{})
+
+		res, err := svc.ClaimGuestLinks(ctx, "", 7)
+		require.NoError(t, err)
+		require.Empty(t, res.Claimed)
+		links.AssertNotCalled(t, "ClaimGuestLinks", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func() (*user.Service, *mocks.MockRepository) {
+		users := new(mocks.MockRepository)
+		return user.NewService(users, nil, nil, nil, nil, nil, nil, nil, mocks.Transactor{}), users
+	}
+
+	t.Run("no password set", func(t *testing.T) {
+		svc, users := setup()
+		users.On("GetByID", ctx, uint64(1)).Return(&user.User{ID: 1}, nil)
+
+		require.ErrorIs(t, svc.ChangePassword(ctx, 1, "", "password2"), user.ErrNoUserPasswordSet)
+		users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("wrong old password", func(t *testing.T) {
+		svc, users := setup()
+		u := &user.User{ID: 1}
+		require.NoError(t, u.Password.Set("password1"))
+		users.On("GetByID", ctx, uint64(1)).Return(u, nil)
+
+		require.ErrorIs(t, svc.ChangePassword(ctx, 1, "wrong", "password2"), user.ErrInvalidCredentials)
+		users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("changes password", func(t *testing.T) {
+		svc, users := setup()
+		u := &user.User{ID: 1}
+		require.NoError(t, u.Password.Set("password1"))
+		users.On("GetByID", ctx, uint64(1)).Return(u, nil)
+		users.On("Update", ctx, u).Return(nil)
+
+		require.NoError(t, svc.ChangePassword(ctx, 1, "password1", "password2"))
+		matches, err := u.Password.Matches("password2")
+		require.NoError(t, err)
+		require.True(t, matches)
+	})
+}
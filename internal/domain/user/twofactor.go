@@ -0,0 +1,344 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/pkg/totp"
+)
+
+var (
+	ErrTwoFactorRequired    = errors.New("two-factor authentication code required")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor authentication code")
+	ErrInvalidChallenge     = errors.New("invalid or expired login challenge")
+)
+
+// TwoFactorRequiredError is returned by login when the credentials are valid but the account has two-factor authentication enabled.
+// The login is finished by Service.CompleteLogin with the challenge token. It wraps ErrTwoFactorRequired.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+	ExpiresAt      time.Time
+}
+
+func (e *TwoFactorRequiredError) Error() string { return ErrTwoFactorRequired.Error() }
+func (e *TwoFactorRequiredError) Unwrap() error { return ErrTwoFactorRequired }
+
+// TOTP is the authenticator secret of the user. It is enabled once ConfirmedAt is set.
+type TOTP struct {
+	UserID      uint64
+	Secret      string
+	ConfirmedAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, codes of the same or older steps are rejected
+	LastUsedStep int64
+}
+
+type TwoFactorRepository interface {
+	GetTOTP(ctx context.Context, userID uint64) (*TOTP, error)
+	// SaveTOTP inserts the secret or replaces the one of an unconfirmed enrollment.
+	SaveTOTP(ctx context.Context, t *TOTP) error
+	ConfirmTOTP(ctx context.Context, userID uint64, at time.Time) error
+	// UseTOTPStep records the step as used. Returns false if the same or a newer step was already used.
+	UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID uint64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint64, hashes []string) error
+	// UseRecoveryCode marks the code as used. Returns false if it does not exist or was already used.
+	UseRecoveryCode(ctx context.Context, userID uint64, hash string, at time.Time) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uint64) (int, error)
+}
+
+// ChallengeRepository keeps pending two-step logins.
+type ChallengeRepository interface {
+	Create(ctx context.Context, tokenHash string, userID uint64, ttl time.Duration) error
+	// Attempt counts an attempt to complete the challenge and returns its user and the number of attempts so far.
+	// Unknown and expired challenges return constants.ErrNotFound.
+	Attempt(ctx context.Context, tokenHash string) (uint64, int, error)
+	Delete(ctx context.Context, tokenHash string) error
+}
+
+type TwoFactorConfig struct {
+	// Issuer is shown by authenticator apps next to the account
+	Issuer       string
+	ChallengeTTL time.Duration
+	// MaxAttempts is the number of codes that can be tried per challenge
+	MaxAttempts   int
+	RecoveryCodes int
+}
+
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorService manages TOTP enrollment, recovery codes and login challenges.
+type TwoFactorService struct {
+	users      Repository
+	repo       TwoFactorRepository
+	challenges ChallengeRepository
+	txManager  transactor.Transactor
+	conf       TwoFactorConfig
+}
+
+func NewTwoFactorService(users Repository, repo TwoFactorRepository, challenges ChallengeRepository, txManager transactor.Transactor, conf TwoFactorConfig) *TwoFactorService {
+	if conf.Issuer == "" {
+		conf.Issuer = "Tinylink"
+	}
+	if conf.ChallengeTTL <= 0 {
+		conf.ChallengeTTL = 5 * time.Minute
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = 5
+	}
+	if conf.RecoveryCodes <= 0 {
+		conf.RecoveryCodes = 10
+	}
+	return &TwoFactorService{users: users, repo: repo, challenges: challenges, txManager: txManager, conf: conf}
+}
+
+// Enabled reports whether the user confirmed TOTP enrollment.
+func (s *TwoFactorService) Enabled(ctx context.Context, userID uint64) (bool, error) {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return t.ConfirmedAt != nil, nil
+}
+
+func (s *TwoFactorService) Status(ctx context.Context, userID uint64) (*TwoFactorStatus, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil || !enabled {
+		return &TwoFactorStatus{}, err
+	}
+
+	remaining, err := s.repo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorStatus{Enabled: true, RecoveryCodesRemaining: remaining}, nil
+}
+
+// Enroll generates a new secret. Two-factor authentication is not enforced until the enrollment is confirmed with a code.
+func (s *TwoFactorService) Enroll(ctx context.Context, userID uint64) (*Enrollment, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SaveTOTP(ctx, &TOTP{UserID: userID, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{Secret: secret, URI: totp.URI(s.conf.Issuer, u.Email, secret)}, nil
+}
+
+// Confirm enables two-factor authentication if the code matches the enrolled secret and returns the recovery codes. They are shown only once.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID uint64, code string) ([]string, error) {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, err
+	}
+	if t.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	now := time.Now()
+	step, ok := totp.Validate(t.Secret, normalizeCode(code), now, 1)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.ConfirmTOTP(ctx, userID, now.UTC()); err != nil {
+			return err
+		}
+		if _, err := s.repo.UseTOTPStep(ctx, userID, step); err != nil {
+			return err
+		}
+		codes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user after verifying a current code.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID uint64, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// Disable removes the secret and recovery codes after verifying a current code.
+func (s *TwoFactorService) Disable(ctx context.Context, userID uint64, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.DeleteTOTP(ctx, userID)
+}
+
+// Verify accepts either a TOTP code or an unused recovery code. Each TOTP code and each recovery code can be used only once.
+func (s *TwoFactorService) Verify(ctx context.Context, userID uint64, code string) error {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return ErrTwoFactorNotEnrolled
+		}
+		return err
+	}
+	if t.ConfirmedAt == nil {
+		return ErrTwoFactorNotEnrolled
+	}
+
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(t.Secret, code, time.Now(), 1)
+		if !ok || step <= t.LastUsedStep {
+			return ErrInvalidTwoFactorCode
+		}
+		used, err := s.repo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Challenge starts a two-step login for the user and returns the challenge token.
+func (s *TwoFactorService) Challenge(ctx context.Context, userID uint64) (*TwoFactorRequiredError, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	plain := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := s.challenges.Create(ctx, hashChallenge(plain), userID, s.conf.ChallengeTTL); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorRequiredError{ChallengeToken: plain, ExpiresAt: time.Now().Add(s.conf.ChallengeTTL).UTC()}, nil
+}
+
+// CompleteChallenge verifies the code for the challenge and returns its user. The challenge is removed on success and after too many attempts.
+// A wrong code returns ErrInvalidTwoFactorCode together with the user ID, so the failure can be counted against the account.
+func (s *TwoFactorService) CompleteChallenge(ctx context.Context, challengeToken, code string) (uint64, error) {
+	hash := hashChallenge(challengeToken)
+
+	userID, attempts, err := s.challenges.Attempt(ctx, hash)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return 0, ErrInvalidChallenge
+		}
+		return 0, err
+	}
+	if attempts > s.conf.MaxAttempts {
+		if err := s.challenges.Delete(ctx, hash); err != nil {
+			return 0, err
+		}
+		return 0, ErrInvalidChallenge
+	}
+
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return userID, err
+	}
+
+	if err := s.challenges.Delete(ctx, hash); err != nil {
+		return 0, fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *TwoFactorService) replaceRecoveryCodes(ctx context.Context, userID uint64) ([]string, error) {
+	codes := make([]string, s.conf.RecoveryCodes)
+	hashes := make([]string, s.conf.RecoveryCodes)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(normalizeCode(code))
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns 80 random bits formatted as xxxx-xxxx-xxxx-xxxx. The entropy is high enough for an unsalted hash.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// normalizeCode strips separators users tend to type and lowercases recovery codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashChallenge(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
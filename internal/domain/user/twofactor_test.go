@@ -0,0 +1,165 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/Kostaaa1/tinylink/pkg/totp"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorService(t *testing.T) {
+	ctx := context.Background()
+	const userID = uint64(7)
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+	confirmed := &user.TOTP{UserID: userID, Secret: secret, ConfirmedAt: &now}
+
+	setup := func() (*user.TwoFactorService, *mocks.MockRepository, *mocks.MockTwoFactorRepository, *mocks.MockChallengeRepository) {
+		users := new(mocks.MockRepository)
+		repo := new(mocks.MockTwoFactorRepository)
+		challenges := new(mocks.MockChallengeRepository)
+		svc := user.NewTwoFactorService(users, repo, challenges, mocks.Transactor{}, user.TwoFactorConfig{MaxAttempts: 3, RecoveryCodes: 4})
+		return svc, users, repo, challenges
+	}
+
+	currentCode := func(t *testing.T) string {
+		code, err := totp.Code(secret, totp.Step(time.Now()))
+		require.NoError(t, err)
+		return code
+	}
+
+	t.Run("enroll returns otpauth uri", func(t *testing.T) {
+		svc, users, repo, _ := setup()
+		repo.On("GetTOTP", ctx, userID).Return(nil, constants.ErrNotFound)
+		users.On("GetByID", ctx, userID).Return(&user.User{ID: userID, Email: "john@gmail.com"}, nil)
+		repo.On("SaveTOTP", ctx, mock.AnythingOfType("*user.TOTP")).Return(nil)
+
+		enrollment, err := svc.Enroll(ctx, userID)
+		require.NoError(t, err)
+		require.NotEmpty(t, enrollment.Secret)
+		require.Contains(t, enrollment.URI, "otpauth://totp/Tinylink:john@gmail.com?")
+	})
+
+	t.Run("enroll fails when already enabled", func(t *testing.T) {
+		svc, _, repo, _ := setup()
+		repo.On("GetTOTP", ctx, userID).Return(confirmed, nil)
+
+		_, err := svc.Enroll(ctx, userID)
+		require.ErrorIs(t, err, user.ErrTwoFactorEnabled)
+	})
+
+	t.Run("confirm stores only hashes of recovery codes", func(t *testing.T) {
+		svc, _, repo, _ := setup()
+		repo.On("GetTOTP", ctx, userID).Return(&user.TOTP{UserID: userID, Secret: secret}, nil)
+		repo.On("ConfirmTOTP", ctx, userID, mock.AnythingOfType("time.Time")).Return(nil)
+		repo.On("UseTOTPStep", ctx, userID, mock.AnythingOfType("int64")).Return(true, nil)
+
+		var hashes []string
+		repo.On("ReplaceRecoveryCodes", ctx, userID, mock.Anything).Run(func(args mock.Arguments) {
+			hashes = args.Get(2).([]string)
+		}).Return(nil)
+
+		codes, err := svc.Confirm(ctx, userID, currentCode(t))
+		require.NoError(t, err)
+		require.Len(t, codes, 4)
+		require.Len(t, hashes, 4)
+		for i, code := range codes {
+			require.NotContains(t, hashes, code)
+			require.Len(t, hashes[i], 64)
+		}
+	})
+
+	t.Run("confirm rejects wrong code", func(t *testing.T) {
+		svc, _, repo, _ := setup()
+		repo.On("GetTOTP", ctx, userID).Return(&user.TOTP{UserID: userID, Secret: secret}, nil)
+
+		_, err := svc.Confirm(ctx, userID, "abcdef")
+		require.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+		repo.AssertNotCalled(t, "ConfirmTOTP", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("used code can not be replayed", func(t *testing.T) {
+		svc, _, repo, _ := setup()
+		used := *confirmed
+		used.LastUsedStep = totp.Step(time.Now()) + 1
+		repo.On("GetTOTP", ctx, userID).Return(&used, nil)
+
+		require.ErrorIs(t, svc.Verify(ctx, userID, currentCode(t)), user.ErrInvalidTwoFactorCode)
+		repo.AssertNotCalled(t, "UseTOTPStep", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("recovery code is normalized before lookup", func(t *testing.T) {
+		svc, _, repo, _ := setup()
+		repo.On("GetTOTP", ctx, userID).Return(confirmed, nil)
+
+		var hash string
+		repo.On("UseRecoveryCode", ctx, userID, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			hash = args.String(2)
+		}).Return(true, nil)
+
+		require.NoError(t, svc.Verify(ctx, userID, " ABCD-efgh-ijkl-mnop "))
+		first := hash
+		require.NoError(t, svc.Verify(ctx, userID, "abcdefghijklmnop"))
+		require.Equal(t, first, hash)
+	})
+
+	t.Run("challenge is dropped after too many attempts", func(t *testing.T) {
+		svc, _, _, challenges := setup()
+		challenges.On("Attempt", ctx, mock.Anything).Return(userID, 4, nil)
+		challenges.On("Delete", ctx, mock.Anything).Return(nil)
+
+		_, err := svc.CompleteChallenge(ctx, "challenge", "123456")
+		require.ErrorIs(t, err, user.ErrInvalidChallenge)
+		challenges.AssertCalled(t, "Delete", ctx, mock.Anything)
+	})
+
+	t.Run("unknown challenge", func(t *testing.T) {
+		svc, _, _, challenges := setup()
+		challenges.On("Attempt", ctx, mock.Anything).Return(uint64(0), 0, constants.ErrNotFound)
+
+		_, err := svc.CompleteChallenge(ctx, "challenge", "123456")
+		require.ErrorIs(t, err, user.ErrInvalidChallenge)
+	})
+}
+
+func TestUserService_Login_TwoFactor(t *testing.T) {
+	ctx := context.Background()
+	const email = "john@gmail.com"
+	client := token.Client{IP: "10.0.0.1", UserAgent: "test"}
+
+	u := &user.User{ID: 7, Email: email}
+	require.NoError(t, u.Password.Set("password1"))
+	now := time.Now()
+
+	repo := new(mocks.MockRepository)
+	attempts := new(mocks.MockAttemptRepository)
+	twoFactorRepo := new(mocks.MockTwoFactorRepository)
+	challenges := new(mocks.MockChallengeRepository)
+
+	lockout := user.NewLockout(attempts, testLockoutConfig(), new(mocks.MockLockoutNotifier))
+	twoFactor := user.NewTwoFactorService(repo, twoFactorRepo, challenges, mocks.Transactor{}, user.TwoFactorConfig{})
+	svc := user.NewService(repo, nil, nil, nil, nil, nil, lockout, twoFactor, mocks.Transactor{})
+
+	attempts.On("LockedFor", ctx, mock.Anything).Return(time.Duration(0), nil)
+	attempts.On("Failures", ctx, mock.Anything).Return(0, nil)
+	repo.On("GetByEmail", ctx, email).Return(u, nil)
+	twoFactorRepo.On("GetTOTP", ctx, u.ID).Return(&user.TOTP{UserID: u.ID, Secret: "AAAA", ConfirmedAt: &now}, nil)
+	challenges.On("Create", ctx, mock.Anything, u.ID, 5*time.Minute).Return(nil)
+
+	_, _, _, err := svc.Login(ctx, email, "password1", client)
+
+	var challenge *user.TwoFactorRequiredError
+	require.ErrorAs(t, err, &challenge)
+	require.NotEmpty(t, challenge.ChallengeToken)
+	// failures are reset only once the second step succeeds
+	attempts.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,102 @@
+package user_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	mailerMocks "github.com/Kostaaa1/tinylink/internal/mocks/mailer"
+	mocks "github.com/Kostaaa1/tinylink/internal/mocks/user"
+	"github.com/Kostaaa1/tinylink/pkg/mailer"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var verifyLinkRx = regexp.MustCompile(`https://tinylink\.test/user/verify\?token=\S+`)
+
+func TestEmailVerificationService(t *testing.T) {
+	ctx := context.Background()
+	const email = "john@gmail.com"
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	setup := func(ttl time.Duration) (*user.EmailVerificationService, *mocks.MockRepository, *mailerMocks.MockMailer) {
+		users := new(mocks.MockRepository)
+		mail := new(mailerMocks.MockMailer)
+		svc := user.NewEmailVerificationService(users, mail, user.EmailVerificationConfig{
+			Secret:    []byte("secret"),
+			TokenTTL:  ttl,
+			VerifyURL: "https://tinylink.test/user/verify",
+		}, log)
+		return svc, users, mail
+	}
+
+	// sendToken returns the token from the emailed link
+	sendToken := func(t *testing.T, svc *user.EmailVerificationService, mail *mailerMocks.MockMailer) string {
+		var sent mailer.Message
+		mail.On("Send", ctx, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(mailer.Message)
+		}).Return(nil)
+
+		require.NoError(t, svc.Send(ctx, &user.User{ID: 7, Email: email}))
+		require.Equal(t, email, sent.To)
+
+		link, err := url.Parse(verifyLinkRx.FindString(sent.Text))
+		require.NoError(t, err)
+		token := link.Query().Get("token")
+		require.NotEmpty(t, token)
+		return token
+	}
+
+	t.Run("verifies the emailed token", func(t *testing.T) {
+		svc, users, mail := setup(time.Hour)
+		token := sendToken(t, svc, mail)
+
+		users.On("MarkEmailVerified", ctx, uint64(7), email, mock.AnythingOfType("time.Time")).Return(nil)
+
+		require.NoError(t, svc.Verify(ctx, token))
+		users.AssertExpectations(t)
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		svc, users, mail := setup(time.Hour)
+		token := sendToken(t, svc, mail)
+		payload, sig, _ := strings.Cut(token, ".")
+
+		require.ErrorIs(t, svc.Verify(ctx, payload+"x."+sig), user.ErrInvalidVerificationToken)
+		require.ErrorIs(t, svc.Verify(ctx, "garbage"), user.ErrInvalidVerificationToken)
+		users.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		svc, users, mail := setup(time.Nanosecond)
+		token := sendToken(t, svc, mail)
+
+		require.ErrorIs(t, svc.Verify(ctx, token), user.ErrInvalidVerificationToken)
+		users.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("token of a changed email", func(t *testing.T) {
+		svc, users, mail := setup(time.Hour)
+		token := sendToken(t, svc, mail)
+
+		users.On("MarkEmailVerified", ctx, uint64(7), email, mock.Anything).Return(constants.ErrNotFound)
+
+		require.ErrorIs(t, svc.Verify(ctx, token), user.ErrInvalidVerificationToken)
+	})
+
+	t.Run("already verified", func(t *testing.T) {
+		svc, users, mail := setup(time.Hour)
+		now := time.Now()
+		users.On("GetByID", ctx, uint64(7)).Return(&user.User{ID: 7, Email: email, EmailVerifiedAt: &now}, nil)
+
+		require.ErrorIs(t, svc.Resend(ctx, 7), user.ErrAlreadyVerified)
+		mail.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
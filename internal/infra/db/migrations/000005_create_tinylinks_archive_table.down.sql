@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tinylinks_archive;
+DROP INDEX IF EXISTS idx_tinylinks_expiration;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE INDEX IF NOT EXISTS idx_tinylinks_expiration ON tinylinks(expiration) WHERE expiration IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS tinylinks_archive (
+	id INTEGER PRIMARY KEY,
+	alias TEXT NOT NULL,
+	url TEXT NOT NULL,
+	domain TEXT,
+	private BOOLEAN NOT NULL DEFAULT FALSE,
+	user_id INTEGER DEFAULT NULL,
+	guest_id UUID DEFAULT NULL,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT NULL,
+	expiration TIMESTAMP DEFAULT NULL,
+	archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tinylinks_archive_user_id ON tinylinks_archive(user_id);
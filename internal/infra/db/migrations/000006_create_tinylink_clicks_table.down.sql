@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tinylink_clicks;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tinylink_clicks (
+	id BIGSERIAL PRIMARY KEY,
+	tinylink_id INTEGER NOT NULL,
+	alias TEXT NOT NULL,
+	clicked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	referrer TEXT,
+	user_agent TEXT,
+	ip_hash TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_tinylink_clicks_tinylink_id_clicked_at ON tinylink_clicks(tinylink_id, clicked_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS tinylink_clicks_dimensions_daily;
+DROP TABLE IF EXISTS tinylink_visitors_daily;
+DROP TABLE IF EXISTS tinylink_clicks_hourly;
+ALTER TABLE tinylink_clicks DROP COLUMN IF EXISTS device;
+ALTER TABLE tinylink_clicks DROP COLUMN IF EXISTS country;
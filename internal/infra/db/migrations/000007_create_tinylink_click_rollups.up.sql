@@ -0,0 +1,25 @@
+ALTER TABLE tinylink_clicks ADD COLUMN IF NOT EXISTS country TEXT;
+ALTER TABLE tinylink_clicks ADD COLUMN IF NOT EXISTS device TEXT;
+
+CREATE TABLE IF NOT EXISTS tinylink_clicks_hourly (
+	tinylink_id INTEGER NOT NULL,
+	bucket TIMESTAMP NOT NULL,
+	clicks BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (tinylink_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS tinylink_visitors_daily (
+	tinylink_id INTEGER NOT NULL,
+	day DATE NOT NULL,
+	ip_hash TEXT NOT NULL,
+	PRIMARY KEY (tinylink_id, day, ip_hash)
+);
+
+CREATE TABLE IF NOT EXISTS tinylink_clicks_dimensions_daily (
+	tinylink_id INTEGER NOT NULL,
+	day DATE NOT NULL,
+	dimension TEXT NOT NULL,
+	value TEXT NOT NULL,
+	clicks BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (tinylink_id, dimension, day, value)
+);
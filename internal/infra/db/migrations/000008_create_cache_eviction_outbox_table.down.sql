@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cache_eviction_outbox;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS cache_eviction_outbox (
+	id BIGSERIAL PRIMARY KEY,
+	alias TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_cache_eviction_outbox_next_attempt_at ON cache_eviction_outbox(next_attempt_at);
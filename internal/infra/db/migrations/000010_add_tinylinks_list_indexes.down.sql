@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tinylinks_guest_id_created_at;
+DROP INDEX IF EXISTS idx_tinylinks_user_id_created_at;
\ No newline at end of file
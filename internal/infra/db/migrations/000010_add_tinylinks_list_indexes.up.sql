@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_tinylinks_user_id_created_at ON tinylinks(user_id, created_at, id) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tinylinks_guest_id_created_at ON tinylinks(guest_id, created_at, id) WHERE guest_id IS NOT NULL;
@@ -0,0 +1,11 @@
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access to administrative features'),
+    ('subscriber', 'Access to paid features'),
+    ('user', 'Default role for registered users'),
+    ('guest', 'Anonymous visitor')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u, roles r
+WHERE r.name = 'user'
+ON CONFLICT DO NOTHING;
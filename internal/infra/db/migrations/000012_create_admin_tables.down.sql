@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	admin_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	action TEXT NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	details JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target ON admin_audit_log(target_type, target_id);
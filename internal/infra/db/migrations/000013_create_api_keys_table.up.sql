@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL UNIQUE,
+	key_hash TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	expires_at TIMESTAMP DEFAULT NULL,
+	last_used_at TIMESTAMP DEFAULT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP DEFAULT NULL;
+
+-- accounts created through google with a verified address are already verified
+UPDATE users u SET email_verified_at = gu.created_at
+FROM google_users_data gu
+WHERE gu.user_id = u.id AND gu.is_verified AND lower(gu.email) = lower(u.email::text) AND u.email_verified_at IS NULL;
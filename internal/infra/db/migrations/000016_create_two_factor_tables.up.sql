@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS user_totp (
+	user_id INTEGER PRIMARY KEY NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	secret TEXT NOT NULL,
+	confirmed_at TIMESTAMP DEFAULT NULL,
+	last_used_step BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP DEFAULT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, code_hash)
+);
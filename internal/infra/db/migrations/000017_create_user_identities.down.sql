@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS google_users_data (
+    user_id INTEGER PRIMARY KEY NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    google_id TEXT NOT NULL,
+    email TEXT NOT NULL,
+    name TEXT,
+    given_name TEXT,
+    family_name TEXT,
+    picture TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    is_verified BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS idx_google_users_data_google_id ON google_users_data(google_id);
+
+INSERT INTO google_users_data (user_id, google_id, email, name, picture, created_at, is_verified)
+SELECT DISTINCT ON (user_id) user_id, subject, email, name, picture, created_at, email_verified
+FROM user_identities
+WHERE provider = 'google'
+ORDER BY user_id, created_at
+ON CONFLICT (user_id) DO NOTHING;
+
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	provider TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+	name TEXT NOT NULL DEFAULT '',
+	picture TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_login_at TIMESTAMP DEFAULT NULL,
+	UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- google userinfo id is the same value as the sub claim of its id tokens
+INSERT INTO user_identities (user_id, provider, subject, email, email_verified, name, picture, created_at)
+SELECT user_id, 'google', google_id, email, is_verified, COALESCE(name, ''), COALESCE(picture, ''), created_at
+FROM google_users_data
+ON CONFLICT (provider, subject) DO NOTHING;
+
+DROP TABLE IF EXISTS google_users_data;
@@ -0,0 +1,2 @@
+ALTER TABLE tinylinks DROP CONSTRAINT IF EXISTS tinylinks_user_id_fkey;
+ALTER TABLE tinylinks ADD CONSTRAINT tinylinks_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
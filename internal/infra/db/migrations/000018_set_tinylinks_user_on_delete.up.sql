@@ -0,0 +1,3 @@
+-- links are removed together with their owner, account deletion applies its link policy before removing the user
+ALTER TABLE tinylinks DROP CONSTRAINT IF EXISTS tinylinks_user_id_fkey;
+ALTER TABLE tinylinks ADD CONSTRAINT tinylinks_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
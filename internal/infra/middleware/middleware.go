@@ -6,35 +6,46 @@ import (
 	"net/http"
 
 	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
 	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/ratelimit"
 	"github.com/Kostaaa1/tinylink/internal/domain/token"
 	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/gorilla/mux"
 )
 
 type Middleware struct {
 	errHandler errhandler.ErrorHandler
 	token      *token.Service
+	apiKeys    *apikey.Service
+	limiter    *ratelimit.Limiter
 	log        *slog.Logger
 }
 
-func New(errHandler errhandler.ErrorHandler, token *token.Service, log *slog.Logger) Middleware {
+func New(errHandler errhandler.ErrorHandler, token *token.Service, apiKeys *apikey.Service, limiter *ratelimit.Limiter, log *slog.Logger) Middleware {
 	return Middleware{
 		errHandler: errHandler,
 		token:      token,
+		apiKeys:    apiKeys,
+		limiter:    limiter,
 		log:        log,
 	}
 }
 
 func (mw Middleware) RouteProtector(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// userCtx, err := userCtxFromClaims(w, r)
-		// if err == nil {
-		// 	r = r.WithContext(auth.WithClaims(r.Context(), userCtx))
-		// 	next.ServeHTTP(w, r)
-		// 	return
-		// }
-
-		// needs to use context only, validation of JWT access token needs to happen in GlobalMiddleware.
+		// API keys are already authenticated by Global, they only need to be accepted by the route.
+		if userCtx := auth.FromContext(r.Context()); userCtx.APIKeyID != nil {
+			if !auth.APIKeyAllowed(r.Context()) {
+				mw.errHandler.ErrorResponse(w, r, http.StatusForbidden, "API keys are not allowed on this route")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Expired access token is refreshed transparently if the request carries a valid refresh token cookie.
+		// The rotated pair is returned in the Authorization header and the refresh token cookie.
 		claims, err := auth.ClaimsFromRequest(r)
 		if err == nil {
 			next.ServeHTTP(w, r)
@@ -48,30 +59,30 @@ func (mw Middleware) RouteProtector(next http.Handler) http.Handler {
 		case errors.Is(err, auth.ErrAccessTokenExpired):
 			oldToken, err := auth.RefreshTokenFromCookie(r)
 			if err != nil {
-				mw.errHandler.ErrorResponse(w, r, http.StatusUnauthorized, err)
+				mw.errHandler.UnauthorizedResponse(w, r)
 				return
 			}
 
-			refresh, access, err := mw.token.ValidateAndRotateTokens(r.Context(), claims.UserID, oldToken)
+			tokens, err := mw.token.RefreshFor(r.Context(), claims.UserID, oldToken)
 			if err != nil {
 				switch {
-				case errors.Is(err, auth.ErrTokenNotValid):
-					mw.errHandler.ForbiddenResponse(w, r)
-				case errors.Is(err, constants.ErrNotFound):
+				case errors.Is(err, auth.ErrTokenNotValid),
+					errors.Is(err, constants.ErrNotFound),
+					errors.Is(err, token.ErrTokenReused):
 					auth.ClearRefreshToken(w)
 					mw.errHandler.UnauthorizedResponse(w, r)
 				default:
-					mw.errHandler.UnauthorizedResponse(w, r)
+					mw.errHandler.ServerErrorResponse(w, r, err)
 				}
 				return
 			}
 
-			auth.SetTokens(w, refresh, access)
+			auth.SetTokens(w, tokens.RefreshToken, tokens.AccessToken)
 			r = r.WithContext(auth.WithClaims(r.Context(), auth.UserContext{
 				IsAuthenticated: true,
-				UserID:          &claims.UserID,
+				UserID:          &tokens.UserID,
 				GuestUUID:       auth.EnsureGuestUUID(w, r),
-				Roles:           claims.Roles,
+				Roles:           tokens.Roles,
 			}))
 
 			next.ServeHTTP(w, r)
@@ -82,13 +93,89 @@ func (mw Middleware) RouteProtector(next http.Handler) http.Handler {
 	})
 }
 
-func (mw Middleware) verifyCSRFToken(r *http.Request, guestUUID string) bool {
-	sess := mw.token.GetSession(r.Context(), guestUUID)
-	token := r.FormValue("csrf_token")
+// RequireRoles allows the request only if the authenticated user has at least one of the roles. Must be used after RouteProtector.
+func (mw Middleware) RequireRoles(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userCtx := auth.FromContext(r.Context())
+			if !userCtx.IsAuthenticated {
+				mw.errHandler.UnauthorizedResponse(w, r)
+				return
+			}
+			if !userCtx.HasRole(roles...) {
+				mw.errHandler.ForbiddenResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIKeyScope lets API keys use the route. Safe methods require readScope, the others writeScope. Requests authenticated otherwise pass through untouched.
+// Must be used before RouteProtector.
+func (mw Middleware) APIKeyScope(readScope, writeScope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userCtx := auth.FromContext(r.Context())
+			if userCtx.APIKeyID == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = readScope
+			}
+			if !userCtx.HasScope(scope) {
+				mw.errHandler.ErrorResponse(w, r, http.StatusForbidden, "API key is missing the "+scope+" scope")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.AllowAPIKey(r.Context())))
+		})
+	}
+}
+
+// authenticateAPIKey resolves the API key of the request. Returns false if the response was already written.
+func (mw Middleware) authenticateAPIKey(w http.ResponseWriter, r *http.Request, key, guestUUID string) (auth.UserContext, bool) {
+	k, err := mw.apiKeys.Authenticate(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidKey):
+			mw.errHandler.UnauthorizedResponse(w, r)
+		case errors.Is(err, apikey.ErrKeyExpired):
+			mw.errHandler.ErrorResponse(w, r, http.StatusUnauthorized, err.Error())
+		default:
+			mw.errHandler.ServerErrorResponse(w, r, err)
+		}
+		return auth.UserContext{}, false
+	}
+
+	return auth.UserContext{
+		IsAuthenticated: true,
+		UserID:          &k.UserID,
+		GuestUUID:       guestUUID,
+		APIKeyID:        &k.ID,
+		Scopes:          k.Scopes,
+	}, true
+}
+
+// verifyCSRFToken checks the token from the csrf_token form value or X-XSRF-Token header against the guest session. Missing session is treated as mismatch.
+func (mw Middleware) verifyCSRFToken(r *http.Request, guestUUID string) (bool, error) {
+	sess, err := mw.token.GetSession(r.Context(), guestUUID)
+	if err != nil {
+		if errors.Is(err, constants.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	token := r.Header.Get("X-XSRF-Token")
 	if token == "" {
-		token = r.Header.Get("X-XSRF-Token")
+		token = r.FormValue("csrf_token")
 	}
-	return sess.CSRF == token
+
+	return sess.ValidCSRF(token), nil
 }
 
 // What needs to happen:
@@ -107,12 +194,27 @@ func (mw Middleware) Global(next http.Handler) http.Handler {
 		// this tells caches not to store responses that include the Set-Cookie header
 		w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
 
+		// API keys are not sent automatically by browsers, so CSRF does not apply to them
+		if key := auth.APIKeyFromRequest(r); key != "" {
+			userCtx, ok := mw.authenticateAPIKey(w, r, key, guestUUID)
+			if !ok {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), userCtx)))
+			return
+		}
+
 		// Validate CSRF token
 		if r.Method == http.MethodPost ||
 			r.Method == http.MethodPut ||
 			r.Method == http.MethodPatch ||
 			r.Method == http.MethodDelete {
-			if !mw.verifyCSRFToken(r, guestUUID) {
+			valid, err := mw.verifyCSRFToken(r, guestUUID)
+			if err != nil {
+				mw.errHandler.ServerErrorResponse(w, r, err)
+				return
+			}
+			if !valid {
 				mw.errHandler.ErrorResponse(w, r, http.StatusForbidden, "CSRF token mismatch")
 				return
 			}
@@ -123,8 +225,10 @@ func (mw Middleware) Global(next http.Handler) http.Handler {
 			GuestUUID:       guestUUID,
 			IsAuthenticated: err == nil,
 			Error:           err,
-			Roles:           claims.Roles,
-			UserID:          &claims.UserID,
+		}
+		if err == nil {
+			userCtx.UserID = &claims.UserID
+			userCtx.Roles = claims.Roles
 		}
 
 		r = r.WithContext(auth.WithClaims(r.Context(), userCtx))
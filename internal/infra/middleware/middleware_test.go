@@ -0,0 +1,362 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/infra/middleware"
+	apikeyMocks "github.com/Kostaaa1/tinylink/internal/mocks/apikey"
+	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type memSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*token.Session
+}
+
+func newMemSessions() *memSessions {
+	return &memSessions{sessions: make(map[string]*token.Session)}
+}
+
+func (m *memSessions) SaveSession(ctx context.Context, sess *token.Session, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *sess
+	m.sessions[sess.GuestUUID] = &cp
+	return nil
+}
+
+func (m *memSessions) GetSession(ctx context.Context, guestUUID string) (*token.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[guestUUID]
+	if !ok {
+		return nil, constants.ErrNotFound
+	}
+	cp := *sess
+	return &cp, nil
+}
+
+// memTokens is an in-memory token.Repository with the same rotation semantics as the Redis one.
+type memTokens struct {
+	mu       sync.Mutex
+	families map[string]*token.Family
+	hashes   map[string]string
+}
+
+func newMemTokens() *memTokens {
+	return &memTokens{families: make(map[string]*token.Family), hashes: make(map[string]string)}
+}
+
+func (m *memTokens) Create(ctx context.Context, fam *token.Family, tokenHash string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.families[fam.ID] = fam
+	m.hashes[fam.ID] = tokenHash
+	return nil
+}
+
+func (m *memTokens) Rotate(ctx context.Context, familyID, oldHash, newHash string, now time.Time, ttl time.Duration) (*token.Family, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fam, ok := m.families[familyID]
+	if !ok {
+		return nil, constants.ErrNotFound
+	}
+	if m.hashes[familyID] != oldHash {
+		return fam, token.ErrTokenReused
+	}
+	m.hashes[familyID] = newHash
+	fam.LastUsedAt = now
+	return fam, nil
+}
+
+func (m *memTokens) Get(ctx context.Context, familyID string) (*token.Family, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fam, ok := m.families[familyID]
+	if !ok {
+		return nil, constants.ErrNotFound
+	}
+	return fam, nil
+}
+
+func (m *memTokens) ListByUser(ctx context.Context, userID uint64) ([]*token.Family, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var families []*token.Family
+	for _, fam := range m.families {
+		if fam.UserID == userID {
+			families = append(families, fam)
+		}
+	}
+	return families, nil
+}
+
+func (m *memTokens) RevokeFamily(ctx context.Context, userID uint64, familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.families, familyID)
+	delete(m.hashes, familyID)
+	return nil
+}
+
+func (m *memTokens) Revoke(ctx context.Context, userID uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, fam := range m.families {
+		if fam.UserID == userID {
+			delete(m.families, id)
+			delete(m.hashes, id)
+		}
+	}
+	return nil
+}
+
+type staticRoles []string
+
+func (r staticRoles) Roles(ctx context.Context, userID uint64) ([]string, error) {
+	return r, nil
+}
+
+func newMiddleware(sessions token.SessionRepository) middleware.Middleware {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return middleware.New(errhandler.New(log), token.NewService(nil, sessions, nil), nil, nil, log)
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestGlobal_CSRF(t *testing.T) {
+	const guestUUID = "5b2f7a4e-3c1d-4e8f-9a6b-0c1d2e3f4a5b"
+
+	sessions := newMemSessions()
+	mw := newMiddleware(sessions)
+	handler := mw.Global(okHandler)
+
+	tokenService := token.NewService(nil, sessions, nil)
+	sess, err := tokenService.CreateSession(context.Background(), guestUUID, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		guest  string
+		csrf   string
+		status int
+	}{
+		{name: "safe method does not require token", method: http.MethodGet, guest: guestUUID, status: http.StatusOK},
+		{name: "matching token", method: http.MethodPost, guest: guestUUID, csrf: sess.CSRF, status: http.StatusOK},
+		{name: "missing token", method: http.MethodPost, guest: guestUUID, status: http.StatusForbidden},
+		{name: "wrong token", method: http.MethodDelete, guest: guestUUID, csrf: "nope", status: http.StatusForbidden},
+		{name: "no session", method: http.MethodPatch, guest: "f0f0f0f0-0000-4000-8000-000000000000", csrf: sess.CSRF, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "guest_uuid", Value: tt.guest})
+			if tt.csrf != "" {
+				req.Header.Set("X-XSRF-Token", tt.csrf)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+
+	t.Run("rotation invalidates previous token", func(t *testing.T) {
+		rotated, err := tokenService.RotateSession(context.Background(), guestUUID, "127.0.0.1", "test")
+		require.NoError(t, err)
+		require.NotEqual(t, sess.CSRF, rotated.CSRF)
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "guest_uuid", Value: guestUUID})
+		req.Header.Set("X-XSRF-Token", sess.CSRF)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestRouteProtector_Refresh(t *testing.T) {
+	ctx := context.Background()
+	const userID = uint64(7)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tokenService := token.NewService(newMemTokens(), newMemSessions(), staticRoles{auth.RoleUser})
+	mw := middleware.New(errhandler.New(log), tokenService, nil, nil, log)
+
+	var seen auth.UserContext
+	handler := mw.Global(mw.RouteProtector(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	refreshToken, err := tokenService.Issue(ctx, userID, token.Client{IP: "127.0.0.1", UserAgent: "test"})
+	require.NoError(t, err)
+
+	validAccess, err := auth.GenerateAccessToken(userID, []string{auth.RoleUser})
+	require.NoError(t, err)
+
+	duration := auth.AccessTokenDuration
+	auth.AccessTokenDuration = -time.Minute
+	expiredAccess, err := auth.GenerateAccessToken(userID, []string{auth.RoleUser})
+	auth.AccessTokenDuration = duration
+	require.NoError(t, err)
+
+	do := func(accessToken, refreshToken string) *httptest.ResponseRecorder {
+		seen = auth.UserContext{}
+		req := httptest.NewRequest(http.MethodGet, "/user/sessions", nil)
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		if refreshToken != "" {
+			req.AddCookie(&http.Cookie{Name: "tl_refresh_token", Value: refreshToken})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	refreshCookie := func(rec *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "tl_refresh_token" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	t.Run("valid access token passes without refresh", func(t *testing.T) {
+		rec := do(validAccess, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Nil(t, refreshCookie(rec))
+		require.Equal(t, userID, *seen.UserID)
+	})
+
+	t.Run("missing access token", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, do("", refreshToken).Code)
+	})
+
+	t.Run("expired access token without refresh cookie", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, do(expiredAccess, "").Code)
+	})
+
+	var rotated string
+
+	t.Run("expired access token is refreshed and rotated cookie is stored", func(t *testing.T) {
+		rec := do(expiredAccess, refreshToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.True(t, seen.IsAuthenticated)
+		require.Equal(t, userID, *seen.UserID)
+		require.Equal(t, []string{auth.RoleUser}, seen.Roles)
+
+		newAccess, _ := strings.CutPrefix(rec.Header().Get("Authorization"), "Bearer ")
+		claims, err := auth.ParseToken(newAccess)
+		require.NoError(t, err)
+		require.Equal(t, userID, claims.UserID)
+
+		cookie := refreshCookie(rec)
+		require.NotNil(t, cookie)
+		require.NotEqual(t, refreshToken, cookie.Value)
+		require.True(t, cookie.HttpOnly)
+		rotated = cookie.Value
+	})
+
+	t.Run("rotated cookie can be used for the next refresh", func(t *testing.T) {
+		rec := do(expiredAccess, rotated)
+		require.Equal(t, http.StatusOK, rec.Code)
+		cookie := refreshCookie(rec)
+		require.NotNil(t, cookie)
+		rotated = cookie.Value
+	})
+
+	t.Run("reusing an old refresh token revokes the session", func(t *testing.T) {
+		rec := do(expiredAccess, refreshToken)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Equal(t, -1, refreshCookie(rec).MaxAge)
+
+		// the latest token belongs to the revoked family as well
+		require.Equal(t, http.StatusUnauthorized, do(expiredAccess, rotated).Code)
+	})
+}
+
+func TestGlobal_APIKey(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := new(apikeyMocks.MockRepository)
+	apiKeys := apikey.NewService(repo, log)
+
+	repo.On("CountByUser", ctx, uint64(7)).Return(0, nil)
+	repo.On("Insert", ctx, mock.Anything).Return(nil)
+	key, plain, err := apiKeys.Create(ctx, 7, apikey.CreateParams{Name: "ci", Scopes: []string{apikey.ScopeLinksRead}})
+	require.NoError(t, err)
+	key.ID = 3
+
+	repo.On("GetByPrefix", mock.Anything, key.Prefix).Return(key, nil)
+	repo.On("GetByPrefix", mock.Anything, "000000000000").Return(nil, constants.ErrNotFound)
+	repo.On("TouchLastUsed", mock.Anything, key.ID, mock.Anything).Return(nil)
+
+	mw := middleware.New(errhandler.New(log), token.NewService(nil, newMemSessions(), nil), apiKeys, nil, log)
+
+	var seen auth.UserContext
+	capture := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	linkScope := mw.APIKeyScope(apikey.ScopeLinksRead, apikey.ScopeLinksWrite)
+	linkRoute := mw.Global(linkScope(mw.RouteProtector(capture)))
+	userRoute := mw.Global(mw.RouteProtector(capture))
+
+	do := func(handler http.Handler, method string, header, value string) int {
+		seen = auth.UserContext{}
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("authorization header", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do(linkRoute, http.MethodGet, "Authorization", "ApiKey "+plain))
+		require.Equal(t, uint64(7), *seen.UserID)
+		require.Equal(t, key.ID, *seen.APIKeyID)
+		require.True(t, seen.IsAuthenticated)
+	})
+
+	t.Run("x-api-key header", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do(linkRoute, http.MethodGet, "X-API-Key", plain))
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		// POST without CSRF token reaches the scope check, so CSRF is skipped for API keys
+		require.Equal(t, http.StatusForbidden, do(linkRoute, http.MethodPost, "X-API-Key", plain))
+		require.Nil(t, seen.UserID)
+	})
+
+	t.Run("route without api key support", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, do(userRoute, http.MethodGet, "X-API-Key", plain))
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, do(linkRoute, http.MethodGet, "X-API-Key", "tl_000000000000_nope"))
+	})
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/domain/ratelimit"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/gorilla/mux"
+)
+
+// RateLimit limits requests of a single client to the route. name separates budgets of different routes. Must be used after Global.
+// The client is identified by API key, user ID, guest cookie or IP, in that order. With perIP only the IP is used, which suits endpoints
+// like login where the caller controls every other identifier.
+func (mw Middleware) RateLimit(name string, limit ratelimit.Limit, perIP bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if limit.Disabled() || mw.limiter == nil {
+			return next
+		}
+
+		policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Window.Seconds()))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := mw.limiter.Allow(r.Context(), name+":"+rateLimitSubject(r, perIP), limit)
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(res.ResetAfter))
+
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				mw.errHandler.RateLimitExceededResponse(w, r, float64(limit.Requests)/limit.Window.Seconds())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitSubject(r *http.Request, perIP bool) string {
+	if !perIP {
+		userCtx := auth.FromContext(r.Context())
+		switch {
+		case userCtx.APIKeyID != nil:
+			return "key:" + strconv.FormatUint(*userCtx.APIKeyID, 10)
+		case userCtx.UserID != nil:
+			return "user:" + strconv.FormatUint(*userCtx.UserID, 10)
+		}
+		// Guest cookie is trusted only on mutating requests, where Global already verified the CSRF token of its session.
+		// Otherwise a client could get a fresh budget by sending a random cookie with every request.
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if guestUUID := auth.GetGuestUUID(r); guestUUID != nil {
+				return "guest:" + *guestUUID
+			}
+		}
+	}
+	// forwarding headers are honoured only from trusted proxies, so a client can not get a new budget by forging them
+	return "ip:" + iputil.ClientIP(r)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,86 @@
+package middleware_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/ratelimit"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/Kostaaa1/tinylink/internal/infra/middleware"
+	"github.com/Kostaaa1/tinylink/pkg/errhandler"
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := ratelimit.NewLimiter(nil, log)
+	mw := middleware.New(errhandler.New(log), token.NewService(nil, newMemSessions(), nil), nil, limiter, log)
+
+	limit := ratelimit.Limit{Requests: 2, Window: time.Minute}
+	handler := mw.Global(mw.RateLimit("redirect", limit, false)(okHandler))
+
+	do := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = ip + ":1234"
+		// a random guest cookie must not give the client a new budget
+		req.AddCookie(&http.Cookie{Name: "guest_uuid", Value: time.Now().String()})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("10.0.0.1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+
+	require.Equal(t, http.StatusOK, do("10.0.0.1").Code)
+
+	rec = do("10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	require.Equal(t, http.StatusOK, do("10.0.0.2").Code)
+}
+
+func TestRateLimit_SpoofedForwardedFor(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := ratelimit.NewLimiter(nil, log)
+	mw := middleware.New(errhandler.New(log), token.NewService(nil, newMemSessions(), nil), nil, limiter, log)
+
+	proxies, err := iputil.ParseTrustedProxies("10.0.0.1")
+	require.NoError(t, err)
+	iputil.SetTrustedProxies(proxies)
+	t.Cleanup(func() { iputil.SetTrustedProxies(nil) })
+
+	limit := ratelimit.Limit{Requests: 2, Window: time.Minute}
+	handler := mw.Global(mw.RateLimit("login", limit, true)(okHandler))
+
+	do := func(remote, xff string) int {
+		req := httptest.NewRequest(http.MethodGet, "/auth/google/callback", nil)
+		req.RemoteAddr = remote + ":1234"
+		req.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("direct client", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do("203.0.113.7", "1.1.1.1"))
+		require.Equal(t, http.StatusOK, do("203.0.113.7", "1.1.1.2"))
+		require.Equal(t, http.StatusTooManyRequests, do("203.0.113.7", "1.1.1.3"))
+	})
+
+	t.Run("behind trusted proxy", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, do("10.0.0.1", "1.1.1.1, 198.51.100.9"))
+		require.Equal(t, http.StatusOK, do("10.0.0.1", "1.1.1.2, 198.51.100.9"))
+		require.Equal(t, http.StatusTooManyRequests, do("10.0.0.1", "1.1.1.3, 198.51.100.9"))
+	})
+}
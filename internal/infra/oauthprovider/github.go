@@ -0,0 +1,116 @@
+package oauthprovider
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+type GitHubConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// APIURL defaults to https://api.github.com, set it for GitHub Enterprise
+	APIURL string
+	// Endpoint defaults to github.com
+	Endpoint *oauth2.Endpoint
+}
+
+// GitHub is a plain OAuth 2 provider, GitHub does not issue ID tokens so the profile is read from the REST API.
+type GitHub struct {
+	conf   *oauth2.Config
+	name   string
+	apiURL string
+	client *http.Client
+}
+
+var _ oauth.Provider = (*GitHub)(nil)
+
+func NewGitHub(conf GitHubConfig, client *http.Client) *GitHub {
+	if conf.Name == "" {
+		conf.Name = "github"
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"read:user", "user:email"}
+	}
+	if conf.APIURL == "" {
+		conf.APIURL = "https://api.github.com"
+	}
+	endpoint := github.Endpoint
+	if conf.Endpoint != nil {
+		endpoint = *conf.Endpoint
+	}
+
+	return &GitHub{
+		name:   conf.Name,
+		apiURL: strings.TrimSuffix(conf.APIURL, "/"),
+		client: client,
+		conf: &oauth2.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			RedirectURL:  conf.RedirectURL,
+			Scopes:       conf.Scopes,
+			Endpoint:     endpoint,
+		},
+	}
+}
+
+func (p *GitHub) Name() string { return p.name }
+
+// AuthCodeURL ignores the nonce, there is no ID token to bind it to. State and PKCE still protect the flow.
+func (p *GitHub) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *GitHub) Exchange(ctx context.Context, code, verifier, nonce string) (*oauth.Profile, error) {
+	tok, err := p.conf.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", oauth.ErrExchangeFailed, err)
+	}
+
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL+"/user", tok.AccessToken, &user); err != nil {
+		return nil, fmt.Errorf("%s user: %w", p.name, err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("%w: missing user id", oauth.ErrExchangeFailed)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL+"/user/emails", tok.AccessToken, &emails); err != nil {
+		return nil, fmt.Errorf("%s emails: %w", p.name, err)
+	}
+
+	profile := &oauth.Profile{
+		// the numeric id is stable, logins can be renamed
+		Subject: strconv.FormatInt(user.ID, 10),
+		Name:    cmp.Or(user.Name, user.Login),
+		Picture: user.AvatarURL,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			profile.Email, profile.EmailVerified = e.Email, e.Verified
+			break
+		}
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,213 @@
+package oauthprovider
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+type OIDCConfig struct {
+	Name string
+	// Issuer is the base URL of the discovery document, e.g. https://accounts.google.com
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes default to openid, email and profile
+	Scopes []string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDC is a provider configured from the issuer's discovery document. The document is fetched on first use, so an unreachable
+// provider does not prevent the server from starting.
+type OIDC struct {
+	conf   OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	doc      *discovery
+	oauth2   *oauth2.Config
+	keys     *remoteKeySet
+	failedAt time.Time
+}
+
+var _ oauth.Provider = (*OIDC)(nil)
+
+func NewOIDC(conf OIDCConfig, client *http.Client) *OIDC {
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"openid", "email", "profile"}
+	}
+	conf.Issuer = strings.TrimSuffix(conf.Issuer, "/")
+	return &OIDC{conf: conf, client: client}
+}
+
+func (p *OIDC) Name() string { return p.conf.Name }
+
+func (p *OIDC) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	cfg, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+func (p *OIDC) discover(ctx context.Context) (*oauth2.Config, *discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.doc != nil {
+		return p.oauth2, p.doc, nil
+	}
+	if time.Since(p.failedAt) < minRefreshInterval {
+		return nil, nil, fmt.Errorf("%s discovery recently failed", p.conf.Name)
+	}
+
+	var doc discovery
+	if err := getJSON(ctx, p.client, p.conf.Issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		p.failedAt = time.Now()
+		return nil, nil, fmt.Errorf("%s discovery: %w", p.conf.Name, err)
+	}
+	if doc.Issuer != p.conf.Issuer {
+		p.failedAt = time.Now()
+		return nil, nil, fmt.Errorf("%s discovery: issuer %q does not match %q", p.conf.Name, doc.Issuer, p.conf.Issuer)
+	}
+
+	p.doc = &doc
+	p.keys = newRemoteKeySet(doc.JWKSURI, p.client)
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.conf.ClientID,
+		ClientSecret: p.conf.ClientSecret,
+		RedirectURL:  p.conf.RedirectURL,
+		Scopes:       p.conf.Scopes,
+		Endpoint:     oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint},
+	}
+	return p.oauth2, p.doc, nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*oauth.Profile, error) {
+	cfg, doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := cfg.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", oauth.ErrExchangeFailed, err)
+	}
+
+	rawIDToken, _ := tok.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%w: missing id_token", oauth.ErrInvalidIDToken)
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &oauth.Profile{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}
+
+	// some providers put only the subject in the id token and the rest in userinfo
+	if profile.Email == "" && doc.UserinfoEndpoint != "" {
+		var info idTokenClaims
+		if err := getJSON(ctx, p.client, doc.UserinfoEndpoint, tok.AccessToken, &info); err != nil {
+			return nil, fmt.Errorf("%s userinfo: %w", p.conf.Name, err)
+		}
+		// userinfo sub must match the id token, otherwise the response is for another user
+		if info.Subject != claims.Subject {
+			return nil, fmt.Errorf("%w: userinfo subject mismatch", oauth.ErrInvalidIDToken)
+		}
+		profile.Email, profile.EmailVerified = info.Email, bool(info.EmailVerified)
+		profile.Name = cmp.Or(profile.Name, info.Name)
+		profile.Picture = cmp.Or(profile.Picture, info.Picture)
+	}
+
+	return profile, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string  `json:"nonce"`
+	AuthorizedBy  string  `json:"azp"`
+	Email         string  `json:"email"`
+	EmailVerified boolish `json:"email_verified"`
+	Name          string  `json:"name"`
+	Picture       string  `json:"picture"`
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiration and nonce of the ID token, OIDC Core section 3.1.3.7.
+func (p *OIDC) verifyIDToken(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.conf.Issuer),
+		jwt.WithAudience(p.conf.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", oauth.ErrInvalidIDToken, err)
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedBy != p.conf.ClientID {
+		return nil, fmt.Errorf("%w: azp does not match client id", oauth.ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", oauth.ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", oauth.ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// boolish accepts both true and "true", some providers send email_verified as a string.
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = boolish(v)
+	case string:
+		*b = boolish(v == "true")
+	case nil:
+		*b = false
+	default:
+		return errors.New("email_verified must be a boolean")
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package oauthprovider_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/Kostaaa1/tinylink/internal/infra/oauthprovider"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIssuer is a minimal OIDC provider issuing ES256 ID tokens with the nonce of the last authorization request.
+type fakeIssuer struct {
+	*httptest.Server
+	key       *ecdsa.PrivateKey
+	nonce     string
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+			"jwks_uri":               f.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC", "kid": "k1", "use": "sig", "crv": "P-256",
+			"x": enc(key.X.FillBytes(make([]byte, 32))),
+			"y": enc(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":            f.URL,
+			"aud":            "client",
+			"sub":            "123",
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"nonce":          f.nonce,
+			"email":          "jane@example.com",
+			"email_verified": "true",
+		}
+		for k, v := range f.claims {
+			claims[k] = v
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		tok.Header["kid"] = "k1"
+		idToken, err := tok.SignedString(key)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// authorize simulates the user consenting: it records nonce and PKCE challenge from the auth URL.
+func (f *fakeIssuer) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	f.nonce = u.Query().Get("nonce")
+	f.challenge = u.Query().Get("code_challenge")
+}
+
+func TestOIDC_Exchange(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeIssuer(t)
+
+	p := oauthprovider.NewOIDC(oauthprovider.OIDCConfig{
+		Name:        "test",
+		Issuer:      f.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost/auth/test/callback",
+	}, f.Client())
+
+	t.Run("valid id token", func(t *testing.T) {
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		require.NoError(t, err)
+		f.authorize(t, authURL)
+		f.claims = nil
+
+		profile, err := p.Exchange(ctx, "code", "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+		require.NoError(t, err)
+		require.Equal(t, "123", profile.Subject)
+		require.Equal(t, "jane@example.com", profile.Email)
+		require.True(t, profile.EmailVerified)
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		require.NoError(t, err)
+		f.authorize(t, authURL)
+
+		_, err = p.Exchange(ctx, "code", "verifier-verifier-verifier-verifier-verifier", "nonce-2")
+		require.ErrorIs(t, err, oauth.ErrInvalidIDToken)
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		require.NoError(t, err)
+		f.authorize(t, authURL)
+		f.claims = jwt.MapClaims{"aud": "other-client"}
+
+		_, err = p.Exchange(ctx, "code", "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+		require.ErrorIs(t, err, oauth.ErrInvalidIDToken)
+	})
+
+	t.Run("wrong pkce verifier", func(t *testing.T) {
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		require.NoError(t, err)
+		f.authorize(t, authURL)
+		f.claims = nil
+
+		_, err = p.Exchange(ctx, "code", "another-verifier-another-verifier-another", "nonce-1")
+		require.ErrorIs(t, err, oauth.ErrExchangeFailed)
+	})
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AccountRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAccountRepository(pool *pgxpool.Pool) account.Repository {
+	return &AccountRepository{pool: pool}
+}
+
+func (r *AccountRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+// deleteLinksQuery deletes links matched by the live and archived conditions together with their click data, which is keyed by
+// tinylink_id without a foreign key. Returns aliases of deleted live links.
+func deleteLinksQuery(live, archived string) string {
+	return `WITH live AS (
+			DELETE FROM tinylinks WHERE ` + live + ` RETURNING id, alias
+		), archived AS (
+			DELETE FROM tinylinks_archive WHERE ` + archived + ` RETURNING id
+		), ids AS (
+			SELECT id FROM live UNION ALL SELECT id FROM archived
+		), raw AS (
+			DELETE FROM tinylink_clicks WHERE tinylink_id IN (SELECT id FROM ids)
+		), hourly AS (
+			DELETE FROM tinylink_clicks_hourly WHERE tinylink_id IN (SELECT id FROM ids)
+		), visitors AS (
+			DELETE FROM tinylink_visitors_daily WHERE tinylink_id IN (SELECT id FROM ids)
+		), dimensions AS (
+			DELETE FROM tinylink_clicks_dimensions_daily WHERE tinylink_id IN (SELECT id FROM ids)
+		)
+		SELECT alias FROM live`
+}
+
+// DeleteLinks removes live and archived links of the user together with their click data.
+func (r *AccountRepository) DeleteLinks(ctx context.Context, userID uint64) ([]string, error) {
+	return r.aliases(ctx, deleteLinksQuery(`user_id = $1`, `user_id = $1`), userID)
+}
+
+// AnonymizeLinks detaches public links from the user and the guest session they were created in, so they can not be claimed again.
+// Private links are deleted since nobody else could open them.
+func (r *AccountRepository) AnonymizeLinks(ctx context.Context, userID uint64) ([]string, error) {
+	deleted, err := r.aliases(ctx, deleteLinksQuery(`user_id = $1 AND private = TRUE`, `user_id = $1 AND private = TRUE`), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.db(ctx).Exec(ctx, `UPDATE tinylinks_archive SET user_id = NULL, guest_id = NULL WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	anonymized, err := r.aliases(ctx, `UPDATE tinylinks
+		SET user_id = NULL, guest_id = NULL, version = version + 1, updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING alias`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(deleted, anonymized...), nil
+}
+
+// TransferLinks moves links of the user to another account. Links with an alias the receiving account already owns are deleted.
+func (r *AccountRepository) TransferLinks(ctx context.Context, fromUserID, toUserID uint64) ([]string, error) {
+	conflict := `user_id = $1 AND alias IN (SELECT alias FROM tinylinks WHERE user_id = $2)`
+	deleted, err := r.aliases(ctx, deleteLinksQuery(conflict, `FALSE`), fromUserID, toUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.db(ctx).Exec(ctx, `UPDATE tinylinks_archive SET user_id = $2, guest_id = NULL WHERE user_id = $1`, fromUserID, toUserID); err != nil {
+		return nil, err
+	}
+
+	transferred, err := r.aliases(ctx, `UPDATE tinylinks
+		SET user_id = $2, guest_id = NULL, version = version + 1, updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING alias`, fromUserID, toUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(deleted, transferred...), nil
+}
+
+func (r *AccountRepository) aliases(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (r *AccountRepository) StreamDailyClicks(ctx context.Context, userID uint64, fn func(c *account.DailyClicks) error) error {
+	query := `WITH links AS (
+			SELECT id, alias FROM tinylinks WHERE user_id = $1
+			UNION ALL
+			SELECT id, alias FROM tinylinks_archive WHERE user_id = $1
+		), clicks AS (
+			SELECT tinylink_id, bucket::date AS day, SUM(clicks) AS clicks
+			FROM tinylink_clicks_hourly
+			WHERE tinylink_id IN (SELECT id FROM links)
+			GROUP BY tinylink_id, day
+		), visitors AS (
+			SELECT tinylink_id, day, COUNT(*) AS visitors
+			FROM tinylink_visitors_daily
+			WHERE tinylink_id IN (SELECT id FROM links)
+			GROUP BY tinylink_id, day
+		)
+		SELECT l.alias, c.day, c.clicks, COALESCE(v.visitors, 0)
+		FROM links l
+		JOIN clicks c ON c.tinylink_id = l.id
+		LEFT JOIN visitors v ON v.tinylink_id = c.tinylink_id AND v.day = c.day
+		ORDER BY l.alias, c.day, l.id`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c := &account.DailyClicks{}
+		if err := rows.Scan(&c.Alias, &c.Day, &c.Clicks, &c.UniqueVisitors); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
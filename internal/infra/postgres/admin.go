@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/admin"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AdminRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAdminRepository(pool *pgxpool.Pool) admin.Repository {
+	return &AdminRepository{pool: pool}
+}
+
+func (r *AdminRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+const userSummaryQuery = `SELECT u.id, u.name, u.email, u.created_at, u.disabled_at,
+		COALESCE(ARRAY_AGG(r.name::text ORDER BY r.name) FILTER (WHERE r.name IS NOT NULL), '{}') AS roles,
+		(SELECT COUNT(*) FROM tinylinks t WHERE t.user_id = u.id) AS link_count
+	FROM users u
+	LEFT JOIN user_roles ur ON ur.user_id = u.id
+	LEFT JOIN roles r ON r.id = ur.role_id`
+
+func scanUserSummary(row pgx.Row) (*admin.UserSummary, error) {
+	u := &admin.UserSummary{}
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.DisabledAt, &u.Roles, &u.LinkCount)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *AdminRepository) ListUsers(ctx context.Context, q admin.UserQuery) ([]*admin.UserSummary, error) {
+	query := userSummaryQuery + `
+		WHERE ($1 = '' OR u.email ILIKE $2 OR u.name ILIKE $2)
+			AND ($3::boolean IS NULL OR (u.disabled_at IS NOT NULL) = $3)
+		GROUP BY u.id
+		ORDER BY u.id
+		LIMIT $4 OFFSET $5`
+
+	pattern := "%" + likeEscaper.Replace(q.Search) + "%"
+
+	rows, err := r.db(ctx).Query(ctx, query, q.Search, pattern, q.Disabled, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*admin.UserSummary, 0)
+
+	for rows.Next() {
+		u, err := scanUserSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *AdminRepository) GetUser(ctx context.Context, userID uint64) (*admin.UserSummary, error) {
+	query := userSummaryQuery + `
+		WHERE u.id = $1
+		GROUP BY u.id`
+
+	u, err := scanUserSummary(r.db(ctx).QueryRow(ctx, query, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (r *AdminRepository) SetUserDisabled(ctx context.Context, userID uint64, disabledAt *time.Time) error {
+	res, err := r.db(ctx).Exec(ctx, `UPDATE users SET disabled_at = $1, version = version + 1 WHERE id = $2`, disabledAt, userID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return constants.ErrNotFound
+	}
+	return nil
+}
+
+func (r *AdminRepository) DeleteUser(ctx context.Context, userID uint64) ([]string, error) {
+	rows, err := r.db(ctx).Query(ctx, `DELETE FROM tinylinks WHERE user_id = $1 RETURNING alias`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.db(ctx).Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected() == 0 {
+		return nil, constants.ErrNotFound
+	}
+
+	return aliases, nil
+}
+
+const tinylinkColumns = `id, alias, url, user_id, guest_id, version, domain, private, created_at, updated_at, expiration`
+
+func scanTinylink(row pgx.Row) (*tinylink.Tinylink, error) {
+	var tl tinylink.Tinylink
+	err := row.Scan(
+		&tl.ID,
+		&tl.Alias,
+		&tl.URL,
+		&tl.UserID,
+		&tl.GuestUUID,
+		&tl.Version,
+		&tl.Domain,
+		&tl.Private,
+		&tl.CreatedAt,
+		&tl.UpdatedAt,
+		&tl.Expiration,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+	return &tl, nil
+}
+
+func (r *AdminRepository) GetLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error) {
+	query := `SELECT ` + tinylinkColumns + ` FROM tinylinks WHERE id = $1`
+	return scanTinylink(r.db(ctx).QueryRow(ctx, query, linkID))
+}
+
+func (r *AdminRepository) ExpireLink(ctx context.Context, linkID uint64, at time.Time) (*tinylink.Tinylink, error) {
+	query := `UPDATE tinylinks SET expiration = $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING ` + tinylinkColumns
+	return scanTinylink(r.db(ctx).QueryRow(ctx, query, at, linkID))
+}
+
+func (r *AdminRepository) DeleteLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error) {
+	query := `DELETE FROM tinylinks WHERE id = $1 RETURNING ` + tinylinkColumns
+	return scanTinylink(r.db(ctx).QueryRow(ctx, query, linkID))
+}
+
+func (r *AdminRepository) InsertAudit(ctx context.Context, entry *admin.AuditEntry) error {
+	query := `INSERT INTO admin_audit_log (admin_id, action, target_type, target_id, details)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	details := entry.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	return r.db(ctx).QueryRow(ctx, query, entry.AdminID, string(entry.Action), string(entry.TargetType), entry.TargetID, details).
+		Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (r *AdminRepository) ListAudit(ctx context.Context, limit, offset int) ([]*admin.AuditEntry, error) {
+	query := `SELECT id, COALESCE(admin_id, 0), action, target_type, target_id, details, created_at
+		FROM admin_audit_log
+		ORDER BY id DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db(ctx).Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*admin.AuditEntry, 0)
+
+	for rows.Next() {
+		e := &admin.AuditEntry{}
+		var action, targetType string
+		if err := rows.Scan(&e.ID, &e.AdminID, &action, &targetType, &e.TargetID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Action = admin.Action(action)
+		e.TargetType = admin.TargetType(targetType)
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
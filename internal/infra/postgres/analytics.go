@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AnalyticsRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAnalyticsRepository(pool *pgxpool.Pool) analytics.Repository {
+	return &AnalyticsRepository{pool: pool}
+}
+
+func (r *AnalyticsRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+// InsertClicks copies raw clicks and applies their rollups in the same transaction, so aggregates never drift from raw data.
+func (r *AnalyticsRepository) InsertClicks(ctx context.Context, clicks []analytics.Click) error {
+	tx, err := r.db(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	columns := []string{"tinylink_id", "alias", "clicked_at", "referrer", "user_agent", "country", "device", "ip_hash"}
+
+	rows := pgx.CopyFromSlice(len(clicks), func(i int) ([]any, error) {
+		c := clicks[i]
+		return []any{c.TinylinkID, c.Alias, c.Timestamp, c.Referrer, c.UserAgent, c.Country, c.Device, c.IPHash}, nil
+	})
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"tinylink_clicks"}, columns, rows); err != nil {
+		return err
+	}
+
+	batch := rollupBatch(analytics.BuildRollups(clicks))
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// rollupBatch queues the rollup upserts in sorted key order, so two collector workers flushing overlapping rows lock them in the same order.
+func rollupBatch(rollups analytics.Rollups) *pgx.Batch {
+	batch := &pgx.Batch{}
+
+	for _, k := range rollups.SortedHourly() {
+		batch.Queue(`INSERT INTO tinylink_clicks_hourly (tinylink_id, bucket, clicks) VALUES ($1, $2, $3)
+			ON CONFLICT (tinylink_id, bucket) DO UPDATE SET clicks = tinylink_clicks_hourly.clicks + EXCLUDED.clicks`,
+			k.TinylinkID, k.Bucket, rollups.Hourly[k])
+	}
+
+	for _, k := range rollups.SortedVisitors() {
+		batch.Queue(`INSERT INTO tinylink_visitors_daily (tinylink_id, day, ip_hash) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`,
+			k.TinylinkID, k.Day, k.IPHash)
+	}
+
+	for _, k := range rollups.SortedDimensions() {
+		batch.Queue(`INSERT INTO tinylink_clicks_dimensions_daily (tinylink_id, day, dimension, value, clicks) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (tinylink_id, dimension, day, value) DO UPDATE SET clicks = tinylink_clicks_dimensions_daily.clicks + EXCLUDED.clicks`,
+			k.TinylinkID, k.Day, string(k.Dimension), k.Value, rollups.Dimensions[k])
+	}
+
+	return batch
+}
+
+func (r *AnalyticsRepository) OwnedLinkID(ctx context.Context, userID uint64, alias string) (uint64, error) {
+	var id uint64
+	err := r.db(ctx).QueryRow(ctx, `SELECT id FROM tinylinks WHERE alias = $1 AND user_id = $2`, alias, userID).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, constants.ErrNotFound
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *AnalyticsRepository) Totals(ctx context.Context, linkID uint64, from, to time.Time) (int64, int64, error) {
+	query := `SELECT
+		(SELECT COALESCE(SUM(clicks), 0) FROM tinylink_clicks_hourly
+			WHERE tinylink_id = $1 AND bucket >= date_trunc('hour', $2::timestamp) AND bucket <= $3),
+		(SELECT COUNT(DISTINCT ip_hash) FROM tinylink_visitors_daily
+			WHERE tinylink_id = $1 AND day >= $2::date AND day <= $3::date)`
+
+	var clicks, visitors int64
+	if err := r.db(ctx).QueryRow(ctx, query, linkID, from, to).Scan(&clicks, &visitors); err != nil {
+		return 0, 0, err
+	}
+
+	return clicks, visitors, nil
+}
+
+func (r *AnalyticsRepository) Series(ctx context.Context, linkID uint64, interval analytics.Interval, from, to time.Time) ([]analytics.Point, error) {
+	switch interval {
+	case analytics.IntervalHour, analytics.IntervalDay, analytics.IntervalMonth:
+	default:
+		return nil, fmt.Errorf("%w: %s", analytics.ErrInvalidInterval, interval)
+	}
+
+	// interval is validated above, so it is safe to pass it as date_trunc field
+	query := `SELECT date_trunc($2, bucket) AS b, SUM(clicks)
+		FROM tinylink_clicks_hourly
+		WHERE tinylink_id = $1 AND bucket >= date_trunc('hour', $3::timestamp) AND bucket <= $4
+		GROUP BY b
+		ORDER BY b`
+
+	rows, err := r.db(ctx).Query(ctx, query, linkID, string(interval), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]analytics.Point, 0)
+
+	for rows.Next() {
+		var p analytics.Point
+		if err := rows.Scan(&p.Bucket, &p.Clicks); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+func (r *AnalyticsRepository) Top(ctx context.Context, linkID uint64, dim analytics.Dimension, from, to time.Time, limit int) ([]analytics.Count, error) {
+	query := `SELECT value, SUM(clicks) AS total
+		FROM tinylink_clicks_dimensions_daily
+		WHERE tinylink_id = $1 AND dimension = $2 AND day >= $3::date AND day <= $4::date
+		GROUP BY value
+		ORDER BY total DESC, value
+		LIMIT $5`
+
+	rows, err := r.db(ctx).Query(ctx, query, linkID, string(dim), from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]analytics.Count, 0)
+
+	for rows.Next() {
+		var c analytics.Count
+		if err := rows.Scan(&c.Value, &c.Clicks); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
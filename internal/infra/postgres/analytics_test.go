@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/analytics"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupBatch_StableOrder(t *testing.T) {
+	base := time.Date(2025, 3, 10, 14, 5, 0, 0, time.UTC)
+
+	clicks := make([]analytics.Click, 0, 60)
+	for i := range 60 {
+		clicks = append(clicks, analytics.Click{
+			TinylinkID: uint64(i%5 + 1),
+			Timestamp:  base.Add(time.Duration(i%7) * 5 * time.Hour),
+			IPHash:     fmt.Sprintf("ip-%d", i%11),
+			Referrer:   fmt.Sprintf("https://site%d.com/", i%3),
+			Country:    []string{"DE", "US", "RS"}[i%3],
+			Device:     []string{"mobile", "desktop"}[i%2],
+		})
+	}
+
+	queue := func() []*pgx.QueuedQuery {
+		return rollupBatch(analytics.BuildRollups(clicks)).QueuedQueries
+	}
+
+	first := queue()
+	for range 20 {
+		next := queue()
+		require.Len(t, next, len(first))
+		for i := range first {
+			require.Equal(t, first[i].SQL, next[i].SQL)
+			require.Equal(t, first[i].Arguments, next[i].Arguments)
+		}
+	}
+
+	// within each table the rows are locked in ascending (tinylink_id, bucket/day, ...) order
+	prev := map[string][]any{}
+	for _, q := range first {
+		a := q.Arguments
+		if p, ok := prev[q.SQL]; ok {
+			require.LessOrEqual(t, p[0].(uint64), a[0].(uint64))
+			if p[0] == a[0] {
+				require.False(t, a[1].(time.Time).Before(p[1].(time.Time)))
+			}
+		}
+		prev[q.SQL] = a
+	}
+}
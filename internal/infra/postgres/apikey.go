@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type APIKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(pool *pgxpool.Pool) apikey.Repository {
+	return &APIKeyRepository{pool: pool}
+}
+
+func (r *APIKeyRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+const apiKeyColumns = `k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.expires_at, k.last_used_at, k.created_at`
+
+func scanAPIKey(row pgx.Row) (*apikey.APIKey, error) {
+	var k apikey.APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Hash, &k.Scopes, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *APIKeyRepository) Insert(ctx context.Context, key *apikey.APIKey) error {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	return r.db(ctx).QueryRow(ctx, query, key.UserID, key.Name, key.Prefix, key.Hash, key.Scopes, key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+}
+
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*apikey.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.prefix = $1 AND u.disabled_at IS NULL`
+
+	key, err := scanAPIKey(r.db(ctx).QueryRow(ctx, query, prefix))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID uint64) ([]*apikey.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys k
+		WHERE k.user_id = $1
+		ORDER BY k.created_at DESC, k.id DESC`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*apikey.APIKey, 0)
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) CountByUser(ctx context.Context, userID uint64) (int, error) {
+	var n int
+	err := r.db(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM api_keys WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, userID, keyID uint64) error {
+	tag, err := r.db(ctx).Exec(ctx, `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, keyID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return constants.ErrNotFound
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) DeleteByUser(ctx context.Context, userID uint64) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM api_keys WHERE user_id = $1`, userID)
+	return err
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, keyID uint64, at time.Time) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, keyID, at)
+	return err
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+var errRollback = errors.New("rollback")
+
+// withTestTx runs fn in a transaction that is always rolled back. Requires POSTGRES_TEST_DSN pointing at a migrated database.
+func withTestTx(t *testing.T, fn func(ctx context.Context, pool *pgxpool.Pool)) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	err = pgxtx.New(pool).WithTx(context.Background(), func(ctx context.Context) error {
+		fn(ctx, pool)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+}
+
+func TestTinylinkRepository_ArchiveExpired_Conflict(t *testing.T) {
+	withTestTx(t, func(ctx context.Context, pool *pgxpool.Pool) {
+		repo := &TinylinkRepository{pool: pool}
+		db := repo.db(ctx)
+		expiredAt := time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC)
+
+		var id uint64
+		err := db.QueryRow(ctx, `INSERT INTO tinylinks (alias, url, guest_id, expiration)
+			VALUES ('archive-conflict', 'https://new.example', uuid_generate_v4(), $1) RETURNING id`, expiredAt).Scan(&id)
+		require.NoError(t, err)
+
+		_, err = db.Exec(ctx, `INSERT INTO tinylinks_archive (id, alias, url) VALUES ($1, 'stale', 'https://stale.example')`, id)
+		require.NoError(t, err)
+
+		n, err := repo.ArchiveExpired(ctx, expiredAt.Add(time.Hour), 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+
+		var live bool
+		require.NoError(t, db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM tinylinks WHERE id = $1)`, id).Scan(&live))
+		require.False(t, live)
+
+		var alias, url string
+		require.NoError(t, db.QueryRow(ctx, `SELECT alias, url FROM tinylinks_archive WHERE id = $1`, id).Scan(&alias, &url))
+		require.Equal(t, "archive-conflict", alias)
+		require.Equal(t, "https://new.example", url)
+	})
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdentityRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewIdentityRepository(pool *pgxpool.Pool) user.IdentityRepository {
+	return &IdentityRepository{pool: pool}
+}
+
+func (r *IdentityRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+const identityColumns = `id, user_id, provider, subject, email, email_verified, name, picture, created_at, last_login_at`
+
+func scanIdentity(row pgx.Row) (*user.Identity, error) {
+	var i user.Identity
+	err := row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.EmailVerified, &i.Name, &i.Picture, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *IdentityRepository) Get(ctx context.Context, provider, subject string) (*user.Identity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	i, err := scanIdentity(r.db(ctx).QueryRow(ctx, query, provider, subject))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+	return i, nil
+}
+
+func (r *IdentityRepository) ListByUser(ctx context.Context, userID uint64) ([]*user.Identity, error) {
+	return r.listByUser(ctx, `SELECT `+identityColumns+` FROM user_identities WHERE user_id = $1 ORDER BY created_at, id`, userID)
+}
+
+func (r *IdentityRepository) LockByUser(ctx context.Context, userID uint64) ([]*user.Identity, error) {
+	return r.listByUser(ctx, `SELECT `+identityColumns+` FROM user_identities WHERE user_id = $1 ORDER BY created_at, id FOR UPDATE`, userID)
+}
+
+func (r *IdentityRepository) listByUser(ctx context.Context, query string, userID uint64) ([]*user.Identity, error) {
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make([]*user.Identity, 0)
+	for rows.Next() {
+		i, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+
+	return identities, rows.Err()
+}
+
+func (r *IdentityRepository) Insert(ctx context.Context, i *user.Identity) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email, email_verified, name, picture, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	args := []any{i.UserID, i.Provider, i.Subject, i.Email, i.EmailVerified, i.Name, i.Picture, i.LastLoginAt}
+	if err := r.db(ctx).QueryRow(ctx, query, args...).Scan(&i.ID, &i.CreatedAt); err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			return user.ErrIdentityConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *IdentityRepository) Touch(ctx context.Context, i *user.Identity, at time.Time) error {
+	query := `UPDATE user_identities SET email = $2, email_verified = $3, name = $4, picture = $5, last_login_at = $6
+		WHERE id = $1
+		RETURNING created_at`
+
+	if err := r.db(ctx).QueryRow(ctx, query, i.ID, i.Email, i.EmailVerified, i.Name, i.Picture, at).Scan(&i.CreatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return constants.ErrNotFound
+		}
+		return err
+	}
+	i.LastLoginAt = &at
+	return nil
+}
+
+func (r *IdentityRepository) Delete(ctx context.Context, userID, id uint64) error {
+	tag, err := r.db(ctx).Exec(ctx, `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return constants.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ResetTokenRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewResetTokenRepository(pool *pgxpool.Pool) user.ResetTokenRepository {
+	return &ResetTokenRepository{pool: pool}
+}
+
+func (r *ResetTokenRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+func (r *ResetTokenRepository) Insert(ctx context.Context, t *user.ResetToken) error {
+	query := `INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	_, err := r.db(ctx).Exec(ctx, query, t.Hash, t.UserID, t.ExpiresAt)
+	return err
+}
+
+// Consume uses a single UPDATE, so two concurrent requests with the same token can not both succeed.
+func (r *ResetTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (uint64, error) {
+	query := `UPDATE password_reset_tokens SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id`
+
+	var userID uint64
+	if err := r.db(ctx).QueryRow(ctx, query, tokenHash, now).Scan(&userID); err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, constants.ErrNotFound
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *ResetTokenRepository) DeleteByUser(ctx context.Context, userID uint64) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1`, userID)
+	return err
+}
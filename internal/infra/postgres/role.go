@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RoleRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRoleRepository(pool *pgxpool.Pool) user.RoleRepository {
+	return &RoleRepository{pool: pool}
+}
+
+func (r *RoleRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+func (r *RoleRepository) Roles(ctx context.Context, userID uint64) ([]string, error) {
+	query := `SELECT r.name FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]string, 0)
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *RoleRepository) Assign(ctx context.Context, userID uint64, role string) error {
+	query := `INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = $2
+		ON CONFLICT DO NOTHING`
+
+	_, err := r.db(ctx).Exec(ctx, query, userID, role)
+	return err
+}
+
+func (r *RoleRepository) Revoke(ctx context.Context, userID uint64, role string) error {
+	query := `DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)`
+
+	_, err := r.db(ctx).Exec(ctx, query, userID, role)
+	return err
+}
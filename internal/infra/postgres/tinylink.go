@@ -3,10 +3,14 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
 	"github.com/Kostaaa1/tinylink/internal/constants"
 	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,6 +24,14 @@ func NewTinylinkRepository(pool *pgxpool.Pool) tinylink.DbRepository {
 	return &TinylinkRepository{pool: pool}
 }
 
+func NewLinkClaimer(pool *pgxpool.Pool) user.LinkClaimer {
+	return &TinylinkRepository{pool: pool}
+}
+
+func (r *TinylinkRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
 func (r *TinylinkRepository) Insert(ctx context.Context, tl *tinylink.Tinylink) error {
 	query := `INSERT INTO tinylinks
 			(alias, url, private, user_id, guest_id, domain, expiration)
@@ -30,7 +42,7 @@ func (r *TinylinkRepository) Insert(ctx context.Context, tl *tinylink.Tinylink)
 
 	args := []interface{}{tl.Alias, tl.URL, tl.Private, tl.UserID, tl.GuestUUID, tl.Domain, tl.Expiration}
 
-	err := r.pool.QueryRow(ctx, query, args...).Scan(
+	err := r.db(ctx).QueryRow(ctx, query, args...).Scan(
 		&tl.ID,
 		&tl.CreatedAt,
 		&tl.Version,
@@ -50,6 +62,30 @@ func (r *TinylinkRepository) Insert(ctx context.Context, tl *tinylink.Tinylink)
 	return nil
 }
 
+func (r *TinylinkRepository) InsertBatch(ctx context.Context, links []*tinylink.Tinylink) error {
+	query := `INSERT INTO tinylinks
+			(alias, url, private, user_id, guest_id, domain, expiration)
+			VALUES
+			($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT DO NOTHING
+			RETURNING id, created_at, version, updated_at`
+
+	batch := &pgx.Batch{}
+	for _, tl := range links {
+		batch.Queue(query, tl.Alias, tl.URL, tl.Private, tl.UserID, tl.GuestUUID, tl.Domain, tl.Expiration).
+			QueryRow(func(row pgx.Row) error {
+				err := row.Scan(&tl.ID, &tl.CreatedAt, &tl.Version, &tl.UpdatedAt)
+				if err == pgx.ErrNoRows {
+					// alias conflict, ID stays zero
+					return nil
+				}
+				return err
+			})
+	}
+
+	return r.db(ctx).SendBatch(ctx, batch).Close()
+}
+
 func isAliasUniqueErr(err error) bool {
 	if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
 		if strings.Contains(pgErr.ConstraintName, "uniq_public_alias") || strings.Contains(pgErr.ConstraintName, "uniq_alias_per_user") {
@@ -76,7 +112,7 @@ func (r *TinylinkRepository) Update(ctx context.Context, tl *tinylink.Tinylink)
 		tl.ID,
 	}
 
-	err := r.pool.QueryRow(ctx, query, args...).Scan(
+	err := r.db(ctx).QueryRow(ctx, query, args...).Scan(
 		&tl.Alias,
 		&tl.URL,
 		&tl.Domain,
@@ -106,7 +142,7 @@ func (r *TinylinkRepository) Update(ctx context.Context, tl *tinylink.Tinylink)
 func (r *TinylinkRepository) ListByGuestUUID(ctx context.Context, uuid string) ([]*tinylink.Tinylink, error) {
 	query := `SELECT id, alias, url, user_id, guest_id, version, domain, private, created_at, updated_at, expiration FROM tinylinks WHERE guest_id = $1`
 
-	rows, err := r.pool.Query(ctx, query, uuid)
+	rows, err := r.db(ctx).Query(ctx, query, uuid)
 	if err != nil {
 		return nil, err
 	}
@@ -140,7 +176,7 @@ func (r *TinylinkRepository) ListByGuestUUID(ctx context.Context, uuid string) (
 func (r *TinylinkRepository) ListByUserID(ctx context.Context, userID uint64) ([]*tinylink.Tinylink, error) {
 	query := `SELECT id, alias, url, user_id, guest_id, version, domain, private, created_at, updated_at, expiration FROM tinylinks WHERE user_id = $1`
 
-	rows, err := r.pool.Query(ctx, query, userID)
+	rows, err := r.db(ctx).Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -171,11 +207,142 @@ func (r *TinylinkRepository) ListByUserID(ctx context.Context, userID uint64) ([
 	return tinylinks, nil
 }
 
+func (r *TinylinkRepository) List(ctx context.Context, q tinylink.ListQuery) ([]*tinylink.Tinylink, error) {
+	var where []string
+	var args []interface{}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch {
+	case q.UserID != nil:
+		where = append(where, "user_id = "+arg(*q.UserID))
+	case q.GuestUUID != nil:
+		where = append(where, "guest_id = "+arg(*q.GuestUUID))
+	default:
+		return nil, errors.New("list query requires user id or guest uuid")
+	}
+
+	f := q.Filter
+	if f.Domain != nil {
+		where = append(where, "domain = "+arg(*f.Domain))
+	}
+	if f.Private != nil {
+		where = append(where, "private = "+arg(*f.Private))
+	}
+	if f.Expired != nil {
+		now := arg(q.Now)
+		if *f.Expired {
+			where = append(where, fmt.Sprintf("(expiration IS NOT NULL AND expiration <= %s)", now))
+		} else {
+			where = append(where, fmt.Sprintf("(expiration IS NULL OR expiration > %s)", now))
+		}
+	}
+	if f.Search != "" {
+		pattern := arg("%" + likeEscaper.Replace(f.Search) + "%")
+		where = append(where, fmt.Sprintf("(alias ILIKE %s OR url ILIKE %s)", pattern, pattern))
+	}
+
+	// sort column comes from a closed set, so it is safe to format it into the query
+	column := "created_at"
+	if q.Sort.Field == tinylink.SortAlias {
+		column = "alias"
+	}
+	dir, cmp := "ASC", ">"
+	if q.Sort.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if c := q.Cursor; c != nil {
+		var value interface{} = c.CreatedAt
+		if q.Sort.Field == tinylink.SortAlias {
+			value = c.Alias
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, cmp, arg(value), arg(c.ID)))
+	}
+
+	query := fmt.Sprintf(`SELECT id, alias, url, user_id, guest_id, version, domain, private, created_at, updated_at, expiration
+		FROM tinylinks
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT %s`, strings.Join(where, " AND "), column, dir, dir, arg(q.Limit))
+
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tinylinks := make([]*tinylink.Tinylink, 0)
+
+	for rows.Next() {
+		tl := &tinylink.Tinylink{}
+		err := rows.Scan(
+			&tl.ID,
+			&tl.Alias,
+			&tl.URL,
+			&tl.UserID,
+			&tl.GuestUUID,
+			&tl.Version,
+			&tl.Domain,
+			&tl.Private,
+			&tl.CreatedAt,
+			&tl.UpdatedAt,
+			&tl.Expiration,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tinylinks = append(tinylinks, tl)
+	}
+
+	return tinylinks, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *TinylinkRepository) StreamByUserID(ctx context.Context, userID uint64, fn func(tl *tinylink.Tinylink) error) error {
+	query := `SELECT id, alias, url, user_id, guest_id, version, domain, private, created_at, updated_at, expiration FROM tinylinks WHERE user_id = $1 ORDER BY id`
+
+	rows, err := r.db(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tl := &tinylink.Tinylink{}
+		err := rows.Scan(
+			&tl.ID,
+			&tl.Alias,
+			&tl.URL,
+			&tl.UserID,
+			&tl.GuestUUID,
+			&tl.Version,
+			&tl.Domain,
+			&tl.Private,
+			&tl.CreatedAt,
+			&tl.UpdatedAt,
+			&tl.Expiration,
+		)
+		if err != nil {
+			return err
+		}
+		if err := fn(tl); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func (r *TinylinkRepository) Redirect(ctx context.Context, userID *uint64, alias string) (*tinylink.RedirectValue, error) {
-	query := `SELECT id, url FROM tinylinks WHERE alias = $1 AND (user_id = $2 OR $2 IS NULL)`
+	query := `SELECT id, alias, url, expiration FROM tinylinks WHERE alias = $1 AND (user_id = $2 OR $2 IS NULL)`
 
 	var redirect tinylink.RedirectValue
-	err := r.pool.QueryRow(ctx, query, alias, userID).Scan(&redirect.RowID, &redirect.URL)
+	err := r.db(ctx).QueryRow(ctx, query, alias, userID).Scan(&redirect.RowID, &redirect.Alias, &redirect.URL, &redirect.Expiration)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -191,7 +358,7 @@ func (r *TinylinkRepository) Get(ctx context.Context, rowID uint64) (*tinylink.T
 	query := `SELECT id, alias, url, user_id, guest_id, version, domain, private, created_at, updated_at, expiration FROM tinylinks WHERE id = $1`
 
 	var tl tinylink.Tinylink
-	err := r.pool.QueryRow(ctx, query, rowID).
+	err := r.db(ctx).QueryRow(ctx, query, rowID).
 		Scan(
 			&tl.ID,
 			&tl.Alias,
@@ -217,7 +384,7 @@ func (r *TinylinkRepository) Get(ctx context.Context, rowID uint64) (*tinylink.T
 }
 
 func (r *TinylinkRepository) Delete(ctx context.Context, userID uint64, alias string) error {
-	res, err := r.pool.Exec(ctx, `DELETE FROM TINYLINKS WHERE alias = $1 and user_id = $2`, alias, userID)
+	res, err := r.db(ctx).Exec(ctx, `DELETE FROM TINYLINKS WHERE alias = $1 and user_id = $2`, alias, userID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return constants.ErrNotFound
@@ -231,3 +398,138 @@ func (r *TinylinkRepository) Delete(ctx context.Context, userID uint64, alias st
 
 	return errors.New("no rows affected upon delete")
 }
+
+func (r *TinylinkRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	query := `DELETE FROM tinylinks WHERE id IN (
+			SELECT id FROM tinylinks
+			WHERE expiration IS NOT NULL AND expiration <= $1
+			ORDER BY expiration
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)`
+
+	res, err := r.db(ctx).Exec(ctx, query, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// ArchiveExpired moves expired links into tinylinks_archive. A stale archive row with the same id is overwritten, since the live row is
+// already deleted and skipping the insert would lose it.
+func (r *TinylinkRepository) ArchiveExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	query := `WITH expired AS (
+			DELETE FROM tinylinks WHERE id IN (
+				SELECT id FROM tinylinks
+				WHERE expiration IS NOT NULL AND expiration <= $1
+				ORDER BY expiration
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, alias, url, domain, private, user_id, guest_id, version, created_at, updated_at, expiration
+		)
+		INSERT INTO tinylinks_archive
+			(id, alias, url, domain, private, user_id, guest_id, version, created_at, updated_at, expiration)
+		SELECT id, alias, url, domain, private, user_id, guest_id, version, created_at, updated_at, expiration FROM expired
+		ON CONFLICT (id) DO UPDATE SET
+			alias = EXCLUDED.alias, url = EXCLUDED.url, domain = EXCLUDED.domain, private = EXCLUDED.private,
+			user_id = EXCLUDED.user_id, guest_id = EXCLUDED.guest_id, version = EXCLUDED.version,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at, expiration = EXCLUDED.expiration,
+			archived_at = CURRENT_TIMESTAMP`
+
+	res, err := r.db(ctx).Exec(ctx, query, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+func (r *TinylinkRepository) EnqueueEvictions(ctx context.Context, aliases []string) error {
+	_, err := r.db(ctx).Exec(ctx, `INSERT INTO cache_eviction_outbox (alias) SELECT unnest($1::text[])`, aliases)
+	return err
+}
+
+func (r *TinylinkRepository) PendingEvictions(ctx context.Context, limit int) ([]tinylink.Eviction, error) {
+	query := `SELECT id, alias, attempts, created_at FROM cache_eviction_outbox
+		WHERE next_attempt_at <= NOW()
+		ORDER BY id
+		LIMIT $1`
+
+	rows, err := r.db(ctx).Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	evictions := make([]tinylink.Eviction, 0)
+
+	for rows.Next() {
+		var e tinylink.Eviction
+		if err := rows.Scan(&e.ID, &e.Alias, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		evictions = append(evictions, e)
+	}
+
+	return evictions, rows.Err()
+}
+
+func (r *TinylinkRepository) CompleteEvictions(ctx context.Context, ids []uint64) error {
+	_, err := r.db(ctx).Exec(ctx, `DELETE FROM cache_eviction_outbox WHERE id = ANY($1)`, ids)
+	return err
+}
+
+// FailEvictions postpones the next attempt with exponential backoff capped at 5 minutes.
+func (r *TinylinkRepository) FailEvictions(ctx context.Context, ids []uint64, reason string) error {
+	query := `UPDATE cache_eviction_outbox
+		SET attempts = attempts + 1,
+			last_error = $2,
+			next_attempt_at = NOW() + LEAST(POWER(2, attempts), 300) * INTERVAL '1 second'
+		WHERE id = ANY($1)`
+
+	_, err := r.db(ctx).Exec(ctx, query, ids, reason)
+	return err
+}
+
+// ClaimGuestLinks assigns guest links to the user in a single statement. Links with an alias the user already owns are reported as conflicts and stay with the guest.
+func (r *TinylinkRepository) ClaimGuestLinks(ctx context.Context, guestUUID string, userID uint64) ([]string, []string, error) {
+	query := `WITH conflicts AS (
+			SELECT g.alias FROM tinylinks g
+			JOIN tinylinks u ON u.alias = g.alias AND u.user_id = $2
+			WHERE g.guest_id = $1 AND g.user_id IS NULL
+		), claimed AS (
+			UPDATE tinylinks
+			SET user_id = $2, version = version + 1, updated_at = NOW()
+			WHERE guest_id = $1 AND user_id IS NULL AND alias NOT IN (SELECT alias FROM conflicts)
+			RETURNING alias
+		)
+		SELECT alias, TRUE FROM claimed
+		UNION ALL
+		SELECT alias, FALSE FROM conflicts`
+
+	rows, err := r.db(ctx).Query(ctx, query, guestUUID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	claimed := make([]string, 0)
+	conflicts := make([]string, 0)
+
+	for rows.Next() {
+		var alias string
+		var ok bool
+		if err := rows.Scan(&alias, &ok); err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			claimed = append(claimed, alias)
+		} else {
+			conflicts = append(conflicts, alias)
+		}
+	}
+
+	return claimed, conflicts, rows.Err()
+}
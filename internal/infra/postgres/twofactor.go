@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TwoFactorRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTwoFactorRepository(pool *pgxpool.Pool) user.TwoFactorRepository {
+	return &TwoFactorRepository{pool: pool}
+}
+
+func (r *TwoFactorRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
+
+func (r *TwoFactorRepository) GetTOTP(ctx context.Context, userID uint64) (*user.TOTP, error) {
+	query := `SELECT user_id, secret, confirmed_at, last_used_step FROM user_totp WHERE user_id = $1`
+
+	var t user.TOTP
+	if err := r.db(ctx).QueryRow(ctx, query, userID).Scan(&t.UserID, &t.Secret, &t.ConfirmedAt, &t.LastUsedStep); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveTOTP never overwrites a confirmed secret, re-enrolling requires disabling first.
+func (r *TwoFactorRepository) SaveTOTP(ctx context.Context, t *user.TOTP) error {
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.confirmed_at IS NULL`
+
+	tag, err := r.db(ctx).Exec(ctx, query, t.UserID, t.Secret)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return user.ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+func (r *TwoFactorRepository) ConfirmTOTP(ctx context.Context, userID uint64, at time.Time) error {
+	tag, err := r.db(ctx).Exec(ctx, `UPDATE user_totp SET confirmed_at = $2 WHERE user_id = $1 AND confirmed_at IS NULL`, userID, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return constants.ErrNotFound
+	}
+	return nil
+}
+
+// UseTOTPStep uses a single conditional UPDATE, so the same code can not be accepted by two concurrent requests.
+func (r *TwoFactorRepository) UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error) {
+	tag, err := r.db(ctx).Exec(ctx, `UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *TwoFactorRepository) DeleteTOTP(ctx context.Context, userID uint64) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID)
+	batch.Queue(`DELETE FROM user_totp WHERE user_id = $1`, userID)
+	return r.db(ctx).SendBatch(ctx, batch).Close()
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint64, hashes []string) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID)
+	batch.Queue(`INSERT INTO user_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`, userID, hashes)
+	return r.db(ctx).SendBatch(ctx, batch).Close()
+}
+
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uint64, hash string, at time.Time) (bool, error) {
+	query := `UPDATE user_recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	tag, err := r.db(ctx).Exec(ctx, query, userID, hash, at)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *TwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uint64) (int, error) {
+	var n int
+	err := r.db(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
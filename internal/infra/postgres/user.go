@@ -5,9 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Kostaaa1/tinylink/core/transactor/pgxtx"
 	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
 	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -19,34 +24,25 @@ func NewUserRepository(pool *pgxpool.Pool) user.Repository {
 	return &UserRepository{pool: pool}
 }
 
-func (r *UserRepository) GetByID(ctx context.Context, id uint64) (*user.User, error) {
-	query := `SELECT u.id, u.name, u.email, u.password_hash, u.version, u.created_at,
-		gu.google_id, gu.name, gu.given_name, gu.family_name, gu.picture, gu.is_verified, gu.created_at
-		FROM users u
-		LEFT JOIN google_users_data gu ON gu.user_id = u.id
-		WHERE u.id = $1`
+func (r *UserRepository) db(ctx context.Context) pgxtx.Querier {
+	return pgxtx.Conn(ctx, r.pool)
+}
 
+const userColumns = `id, name, email, password_hash, version, created_at, disabled_at, email_verified_at`
+
+func scanUser(row pgx.Row) (*user.User, error) {
 	userData := &user.User{}
 	var pwHash []byte
 
-	var gID, gName, gGivenName, gFamilyName, gPicture sql.NullString
-	var gVerified sql.NullBool
-	var googlCreatedAt sql.NullTime
-
-	err := r.pool.QueryRow(ctx, query, id).Scan(
+	err := row.Scan(
 		&userData.ID,
 		&userData.Name,
 		&userData.Email,
 		&pwHash,
 		&userData.Version,
 		&userData.CreatedAt,
-		&gID,
-		&gName,
-		&gGivenName,
-		&gFamilyName,
-		&gPicture,
-		&gVerified,
-		&googlCreatedAt,
+		&userData.DisabledAt,
+		&userData.EmailVerifiedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -56,195 +52,112 @@ func (r *UserRepository) GetByID(ctx context.Context, id uint64) (*user.User, er
 	}
 
 	userData.SetPassword(pwHash)
-
-	if gID.Valid {
-		userData.Google = &user.GoogleUser{
-			UserID:        userData.ID,
-			ID:            gID.String,
-			Email:         userData.Email,
-			VerifiedEmail: gVerified.Bool,
-			FamilyName:    gFamilyName.String,
-			Name:          gName.String,
-			GivenName:     gGivenName.String,
-			Picture:       gPicture.String,
-			CreatedAt:     googlCreatedAt.Time,
-		}
-	}
-
 	return userData, nil
 }
 
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
-	query := `SELECT u.id, u.name, u.email, u.password_hash, u.version, u.created_at,
-		gu.google_id, gu.name, gu.given_name, gu.family_name, gu.picture, gu.is_verified, gu.created_at
-		FROM users u
-		LEFT JOIN google_users_data gu ON gu.user_id = u.id
-		WHERE u.email = $1`
-
-	userData := &user.User{}
-	var pwHash []byte
-
-	var gID, gName, gGivenName, gFamilyName, gPicture sql.NullString
-	var gVerified sql.NullBool
-	var googlCreatedAt sql.NullTime
+func (r *UserRepository) GetByID(ctx context.Context, id uint64) (*user.User, error) {
+	return scanUser(r.db(ctx).QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
 
-	err := r.pool.QueryRow(ctx, query, email).Scan(
-		&userData.ID,
-		&userData.Name,
-		&userData.Email,
-		// &userData.Password.Hash,
-		&pwHash,
-		&userData.Version,
-		&userData.CreatedAt,
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	return scanUser(r.db(ctx).QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
 
-		&gID,
-		&gName,
-		&gGivenName,
-		&gFamilyName,
-		&gPicture,
-		&gVerified,
-		&googlCreatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, constants.ErrNotFound
-		}
-		return nil, err
-	}
+// isDuplicateEmailErr reports a violation of the unique constraint on users.email. The column is CITEXT, so emails differing only in case conflict too.
+func isDuplicateEmailErr(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_email_key"
+}
 
-	userData.SetPassword(pwHash)
+func (r *UserRepository) Insert(ctx context.Context, u *user.User) error {
+	query := `INSERT INTO users (name, email, password_hash, email_verified_at)
+            VALUES ($1, $2, $3, $4)
+            RETURNING id, created_at, version`
 
-	if gID.Valid {
-		userData.Google = &user.GoogleUser{
-			UserID:        userData.ID,
-			ID:            gID.String,
-			Email:         userData.Email,
-			VerifiedEmail: gVerified.Bool,
-			FamilyName:    gFamilyName.String,
-			Name:          gName.String,
-			GivenName:     gGivenName.String,
-			Picture:       gPicture.String,
-			CreatedAt:     googlCreatedAt.Time,
+	args := []interface{}{u.Name, u.Email, u.Password.Hash, u.EmailVerifiedAt}
+	if err := r.db(ctx).QueryRow(ctx, query, args...).Scan(&u.ID, &u.CreatedAt, &u.Version); err != nil {
+		if isDuplicateEmailErr(err) {
+			return user.ErrDuplicateEmail
 		}
+		return err
 	}
 
-	return userData, nil
+	return nil
 }
 
-// func isUniqueConstraintErr(err error) bool {
-// 	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
-// 	// if sqliteError, ok := err.(sqlite3.Error); ok {
-// 	// 	if sqliteError.Code == sqlite3.ErrConstraint && sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique {
-// 	// 		return true
-// 	// 	}
-// 	// }
-// 	// return false
-// }
+// MarkEmailVerified sets email_verified_at only if the user still has the email, so a token issued for a previous address can not verify a new one.
+// Already verified users keep the original timestamp.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID uint64, email string, at time.Time) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, $3)
+		WHERE id = $1 AND email = $2`
 
-func (r *UserRepository) Insert(ctx context.Context, user *user.User) error {
-
-	query := `INSERT INTO users (name, email, password_hash)
-            VALUES ($1, $2, $3)
-            RETURNING id, created_at, version`
-
-	args := []interface{}{user.Name, user.Email, user.Password.Hash}
-	if err := r.pool.QueryRow(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version); err != nil {
-		// if isUniqueConstraintErr(err) {
-		// 	return data.ErrRecordExists
-		// }
+	tag, err := r.db(ctx).Exec(ctx, query, userID, email, at)
+	if err != nil {
 		return err
 	}
-
-	if user.Google != nil {
-		query := `INSERT INTO google_users_data
-			(user_id, google_id, email, name, given_name, family_name, picture, is_verified)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			RETURNING google_users_data.created_at`
-
-		args := []interface{}{
-			user.ID,
-			user.Google.ID,
-			user.Google.Email,
-			user.Google.Name,
-			user.Google.GivenName,
-			user.Google.FamilyName,
-			user.Google.Picture,
-			user.Google.VerifiedEmail,
-		}
-
-		if err := r.pool.QueryRow(ctx, query, args...).Scan(&user.Google.CreatedAt); err != nil {
-			// if isUniqueConstraintErr(err) {
-			// 	return data.ErrRecordExists
-			// }
-			return err
-		}
+	if tag.RowsAffected() == 0 {
+		return constants.ErrNotFound
 	}
-
 	return nil
 }
 
-func (r *UserRepository) InsertGoogleUser(ctx context.Context, googleUser *user.GoogleUser) error {
-
-	query := `INSERT INTO google_users_data
-			(user_id, google_id, email, name, given_name, family_name, picture, is_verified)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			RETURNING google_users_data.created_at`
+type VerificationChecker struct {
+	pool *pgxpool.Pool
+}
 
-	args := []interface{}{
-		googleUser.UserID,
-		googleUser.ID,
-		googleUser.Email,
-		googleUser.Name,
-		googleUser.GivenName,
-		googleUser.FamilyName,
-		googleUser.Picture,
-		googleUser.VerifiedEmail,
-	}
+func NewVerificationChecker(pool *pgxpool.Pool) tinylink.VerificationChecker {
+	return &VerificationChecker{pool: pool}
+}
 
-	if err := r.pool.QueryRow(ctx, query, args...).Scan(&googleUser.CreatedAt); err != nil {
-		// if isUniqueConstraintErr(err) {
-		// 	return data.ErrRecordExists
-		// }
-		return err
+func (c *VerificationChecker) EmailVerified(ctx context.Context, userID uint64) (bool, error) {
+	var verified bool
+	err := pgxtx.Conn(ctx, c.pool).QueryRow(ctx, `SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&verified)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, constants.ErrNotFound
+		}
+		return false, err
 	}
-
-	return nil
+	return verified, nil
 }
 
-func (r *UserRepository) Delete(ctx context.Context, userID string) error {
-
-	query := "DELETE FROM users WHERE id = ?"
-	res, err := r.pool.Exec(ctx, query, userID)
+func (r *UserRepository) Delete(ctx context.Context, userID uint64) error {
+	res, err := r.db(ctx).Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
 	if err != nil {
 		return fmt.Errorf("failed to execute delete query: %w", err)
 	}
 	if res.RowsAffected() == 0 {
-		return fmt.Errorf("no user found with id %s", userID)
+		return constants.ErrNotFound
 	}
 	return nil
 }
 
-func (r *UserRepository) Update(ctx context.Context, user *user.User) error {
-
+// Update compares the version so concurrent edits do not overwrite each other. email_verified_at is kept only if the email did not change,
+// comparing as CITEXT so a change of letter case keeps the verification.
+func (r *UserRepository) Update(ctx context.Context, u *user.User) error {
 	query := `
         UPDATE users
-        SET name = $1, email = $2, password_hash = $3, version = version + 1
-        WHERE id = $4
-        RETURNING version
+        SET name = $1, email = $2, password_hash = $3, version = version + 1,
+            email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END
+        WHERE id = $4 AND version = $5
+        RETURNING version, email_verified_at
     `
 
 	args := []interface{}{
-		user.Name,
-		user.Email,
-		user.Password.Hash,
-		user.ID,
+		u.Name,
+		u.Email,
+		u.Password.Hash,
+		u.ID,
+		u.Version,
 	}
 
-	err := r.pool.QueryRow(ctx, query, args...).Scan(&user.Version)
+	err := r.db(ctx).QueryRow(ctx, query, args...).Scan(&u.Version, &u.EmailVerifiedAt)
 	if err != nil {
 		switch {
-		case err == sql.ErrNoRows:
-			return constants.ErrNotFound
+		case errors.Is(err, pgx.ErrNoRows):
+			return user.ErrEditConflict
+		case isDuplicateEmailErr(err):
+			return user.ErrDuplicateEmail
 		default:
 			return err
 		}
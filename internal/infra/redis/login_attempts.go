@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/redis/go-redis/v9"
+)
+
+type LoginAttemptRepository struct {
+	client *redis.Client
+}
+
+func NewLoginAttemptRepository(client *redis.Client) *LoginAttemptRepository {
+	return &LoginAttemptRepository{client: client}
+}
+
+var _ user.AttemptRepository = (*LoginAttemptRepository)(nil)
+
+func loginFailuresKey(key string) string {
+	return "login_failures:" + key
+}
+
+func loginLockKey(key string) string {
+	return "login_lock:" + key
+}
+
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	k := loginFailuresKey(key)
+
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, k)
+		// the window starts at the first failure and is not extended by the following ones
+		pipe.ExpireNX(ctx, k, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(incr.Val()), nil
+}
+
+func (r *LoginAttemptRepository) Failures(ctx context.Context, key string) (int, error) {
+	n, err := r.client.Get(ctx, loginFailuresKey(key)).Int()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Set(ctx, loginLockKey(key), 1, ttl).Err()
+}
+
+func (r *LoginAttemptRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, loginLockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// negative values mean the key does not exist or has no expiration
+	return max(ttl, 0), nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = loginFailuresKey(key)
+	}
+	return r.client.Del(ctx, redisKeys...).Err()
+}
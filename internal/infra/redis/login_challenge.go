@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/redis/go-redis/v9"
+)
+
+type LoginChallengeRepository struct {
+	client *redis.Client
+}
+
+func NewLoginChallengeRepository(client *redis.Client) *LoginChallengeRepository {
+	return &LoginChallengeRepository{client: client}
+}
+
+var _ user.ChallengeRepository = (*LoginChallengeRepository)(nil)
+
+func loginChallengeKey(tokenHash string) string {
+	return "login_challenge:" + tokenHash
+}
+
+func (r *LoginChallengeRepository) Create(ctx context.Context, tokenHash string, userID uint64, ttl time.Duration) error {
+	k := loginChallengeKey(tokenHash)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, k, "user_id", userID, "attempts", 0)
+		pipe.Expire(ctx, k, ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *LoginChallengeRepository) Attempt(ctx context.Context, tokenHash string) (uint64, int, error) {
+	k := loginChallengeKey(tokenHash)
+
+	userID, err := r.client.HGet(ctx, k, "user_id").Uint64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, 0, constants.ErrNotFound
+		}
+		return 0, 0, err
+	}
+
+	// HINCRBY would recreate the hash without expiration if it expired in between, so the field is incremented only if it exists
+	attempts, err := incrExisting.Run(ctx, r.client, []string{k}, "attempts").Int()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, 0, constants.ErrNotFound
+		}
+		return 0, 0, err
+	}
+
+	return userID, attempts, nil
+}
+
+var incrExisting = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+return redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+`)
+
+func (r *LoginChallengeRepository) Delete(ctx context.Context, tokenHash string) error {
+	return r.client.Del(ctx, loginChallengeKey(tokenHash)).Err()
+}
@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/oauth"
+	"github.com/redis/go-redis/v9"
+)
+
+type OAuthStateRepository struct {
+	client *redis.Client
+}
+
+func NewOAuthStateRepository(client *redis.Client) *OAuthStateRepository {
+	return &OAuthStateRepository{client: client}
+}
+
+var _ oauth.StateRepository = (*OAuthStateRepository)(nil)
+
+func oauthStateKey(key string) string {
+	return "oauth_state:" + key
+}
+
+func (r *OAuthStateRepository) Save(ctx context.Context, key string, state oauth.State, ttl time.Duration) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, oauthStateKey(key), b, ttl).Err()
+}
+
+// Consume reads and deletes the state atomically, so a callback can not be replayed.
+func (r *OAuthStateRepository) Consume(ctx context.Context, key string) (*oauth.State, error) {
+	b, err := r.client.GetDel(ctx, oauthStateKey(key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+
+	var state oauth.State
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/redis/go-redis/v9"
+)
+
+type PendingLinkRepository struct {
+	client *redis.Client
+}
+
+func NewPendingLinkRepository(client *redis.Client) *PendingLinkRepository {
+	return &PendingLinkRepository{client: client}
+}
+
+var _ user.PendingLinkRepository = (*PendingLinkRepository)(nil)
+
+// pendingLink is stored instead of user.Identity, whose JSON omits the user and subject.
+type pendingLink struct {
+	UserID        uint64 `json:"user_id"`
+	Provider      string `json:"provider"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+func pendingLinkKey(tokenHash string) string {
+	return "pending_link:" + tokenHash
+}
+
+func (r *PendingLinkRepository) Save(ctx context.Context, tokenHash string, i *user.Identity, ttl time.Duration) error {
+	b, err := json.Marshal(pendingLink{
+		UserID:        i.UserID,
+		Provider:      i.Provider,
+		Subject:       i.Subject,
+		Email:         i.Email,
+		EmailVerified: i.EmailVerified,
+		Name:          i.Name,
+		Picture:       i.Picture,
+	})
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, pendingLinkKey(tokenHash), b, ttl).Err()
+}
+
+func (r *PendingLinkRepository) Consume(ctx context.Context, tokenHash string) (*user.Identity, error) {
+	b, err := r.client.GetDel(ctx, pendingLinkKey(tokenHash)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, constants.ErrNotFound
+		}
+		return nil, err
+	}
+
+	var p pendingLink
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+
+	return &user.Identity{
+		UserID:        p.UserID,
+		Provider:      p.Provider,
+		Subject:       p.Subject,
+		Email:         p.Email,
+		EmailVerified: p.EmailVerified,
+		Name:          p.Name,
+		Picture:       p.Picture,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+type RateLimitStore struct {
+	client *redis.Client
+}
+
+func NewRateLimitStore(client *redis.Client) *RateLimitStore {
+	return &RateLimitStore{client: client}
+}
+
+func rateLimitKey(key string) string {
+	return "ratelimit:" + key
+}
+
+// gcraScript stores the theoretical arrival time (in ms) of the next request. The key expires once the bucket is full again.
+// Returns {allowed, tat offset in ms}.
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+if new_tat - now > window then
+	return {0, tat - now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+return {1, new_tat - now}
+`)
+
+func (s *RateLimitStore) Allow(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	res, err := gcraScript.Run(ctx, s.client, []string{rateLimitKey(key)},
+		now.UnixMilli(), limit.Interval().Milliseconds(), limit.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	return ratelimit.NewResult(limit, res[0] == 1, time.Duration(res[1])*time.Millisecond), nil
+}
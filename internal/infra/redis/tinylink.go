@@ -41,6 +41,25 @@ func (r *TinylinkRepository) GenerateAlias(ctx context.Context) (string, error)
 	return alias, nil
 }
 
+// GenerateAliases reserves n consecutive counter values with a single INCRBY.
+func (r *TinylinkRepository) GenerateAliases(ctx context.Context, n int) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	last, err := r.client.IncrBy(ctx, "tinylink_count", int64(n)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to increment alias counter: %w", err)
+	}
+
+	aliases := make([]string, n)
+	for i := range n {
+		aliases[i] = base62Encode(last - int64(n) + 1 + int64(i))
+	}
+
+	return aliases, nil
+}
+
 func (r *TinylinkRepository) Redirect(ctx context.Context, alias string) (*tinylink.RedirectValue, error) {
 	key := fmt.Sprintf("cached_alias:%s", alias)
 
@@ -53,8 +72,9 @@ func (r *TinylinkRepository) Redirect(ctx context.Context, alias string) (*tinyl
 		return nil, err
 	}
 
+	// HGETALL returns an empty map (not redis.Nil) for missing keys
 	if len(value) == 0 {
-		return nil, fmt.Errorf("no cache key found for alias: %s", alias)
+		return nil, constants.ErrNotFound
 	}
 
 	rowIDStr, ok := value["row_id"]
@@ -67,11 +87,21 @@ func (r *TinylinkRepository) Redirect(ctx context.Context, alias string) (*tinyl
 		return nil, fmt.Errorf("parseUint failed for row_id: %s", rowIDStr)
 	}
 
-	return &tinylink.RedirectValue{
+	redirect := &tinylink.RedirectValue{
 		RowID: rowID,
-		Alias: value["alias"],
+		Alias: alias,
 		URL:   value["url"],
-	}, nil
+	}
+
+	if exp, ok := value["expiration"]; ok && exp != "" {
+		t, err := time.Parse(time.RFC3339, exp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiration for alias: %s", alias)
+		}
+		redirect.Expiration = &t
+	}
+
+	return redirect, nil
 }
 
 func (r *TinylinkRepository) Cache(ctx context.Context, val tinylink.RedirectValue, ttl time.Duration) error {
@@ -81,6 +111,9 @@ func (r *TinylinkRepository) Cache(ctx context.Context, val tinylink.RedirectVal
 		"row_id": strconv.Itoa(int(val.RowID)),
 		"url":    val.URL,
 	}
+	if val.Expiration != nil {
+		cacheVal["expiration"] = val.Expiration.Format(time.RFC3339)
+	}
 
 	pipe := r.client.Pipeline()
 	pipe.HSet(ctx, key, cacheVal)
@@ -91,6 +124,19 @@ func (r *TinylinkRepository) Cache(ctx context.Context, val tinylink.RedirectVal
 	return err
 }
 
+func (r *TinylinkRepository) Evict(ctx context.Context, aliases ...string) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(aliases))
+	for i, alias := range aliases {
+		keys[i] = fmt.Sprintf("cached_alias:%s", alias)
+	}
+
+	return r.client.Del(ctx, keys...).Err()
+}
+
 func (r *TinylinkRepository) Save(
 	ctx context.Context,
 	uuid string,
@@ -3,9 +3,12 @@ package redis
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
-	"github.com/Kostaaa1/tinylink/internal/domain/auth"
+	"github.com/Kostaaa1/tinylink/internal/constants"
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -18,51 +21,235 @@ func NewTokenRepository(c *redis.Client) *Tokenository {
 }
 
 var (
-	refreshTokensKey = "refresh_tokens"
+	refreshFamilyKey   = "refresh_family"
+	refreshFamiliesKey = "refresh_families"
 )
 
-func (r *Tokenository) Save(ctx context.Context, userID uint64, refreshToken string, ttl time.Duration) error {
-	key := fmt.Sprintf("%s:%d", refreshTokensKey, userID)
-	return r.client.Set(ctx, key, refreshToken, ttl).Err()
+func familyKey(familyID string) string {
+	return fmt.Sprintf("%s:%s", refreshFamilyKey, familyID)
 }
 
-func (r *Tokenository) Revoke(ctx context.Context, userID uint64) error {
-	return r.client.Del(ctx, fmt.Sprintf("%s:%d", refreshTokensKey, userID)).Err()
+func userFamiliesKey(userID uint64) string {
+	return fmt.Sprintf("%s:%d", refreshFamiliesKey, userID)
+}
+
+func (r *Tokenository) Create(ctx context.Context, fam *token.Family, tokenHash string, ttl time.Duration) error {
+	key := familyKey(fam.ID)
+	setKey := userFamiliesKey(fam.UserID)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, map[string]string{
+			"user_id":      strconv.FormatUint(fam.UserID, 10),
+			"token_hash":   tokenHash,
+			"ip":           fam.IP,
+			"user_agent":   fam.UserAgent,
+			"created_at":   fam.CreatedAt.Format(time.RFC3339),
+			"last_used_at": fam.LastUsedAt.Format(time.RFC3339),
+		})
+		pipe.Expire(ctx, key, ttl)
+		pipe.SAdd(ctx, setKey, fam.ID)
+		pipe.Expire(ctx, setKey, ttl)
+		return nil
+	})
+
+	return err
 }
 
-func (r *Tokenository) Valid(ctx context.Context, userID uint64, token string) error {
-	refreshToken, err := r.client.Get(ctx, fmt.Sprintf("%s:%d", refreshTokensKey, userID)).Result()
+// rotateScript swaps the current token hash only if the presented one matches, so two concurrent refreshes with the same token can not both succeed.
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'token_hash')
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'token_hash', ARGV[2], 'last_used_at', ARGV[3])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+func (r *Tokenository) Rotate(ctx context.Context, familyID, oldHash, newHash string, now time.Time, ttl time.Duration) (*token.Family, error) {
+	res, err := rotateScript.Run(ctx, r.client, []string{familyKey(familyID)},
+		oldHash, newHash, now.Format(time.RFC3339), int64(ttl.Seconds())).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	if res == -1 {
+		return nil, constants.ErrNotFound
+	}
+
+	fam, err := r.Get(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == 0 {
+		return fam, token.ErrTokenReused
+	}
+
+	if err := r.client.Expire(ctx, userFamiliesKey(fam.UserID), ttl).Err(); err != nil {
+		return nil, err
+	}
+
+	return fam, nil
+}
+
+func (r *Tokenository) Get(ctx context.Context, familyID string) (*token.Family, error) {
+	m, err := r.client.HGetAll(ctx, familyKey(familyID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, constants.ErrNotFound
+	}
+	return familyFromMap(familyID, m)
+}
+
+func familyFromMap(familyID string, m map[string]string) (*token.Family, error) {
+	fam := &token.Family{
+		ID:        familyID,
+		IP:        m["ip"],
+		UserAgent: m["user_agent"],
+	}
+
+	var err error
+	if fam.UserID, err = strconv.ParseUint(m["user_id"], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid token family user_id: %w", err)
+	}
+	if fam.CreatedAt, err = time.Parse(time.RFC3339, m["created_at"]); err != nil {
+		return nil, fmt.Errorf("invalid token family created_at: %w", err)
+	}
+	if fam.LastUsedAt, err = time.Parse(time.RFC3339, m["last_used_at"]); err != nil {
+		return nil, fmt.Errorf("invalid token family last_used_at: %w", err)
+	}
+
+	return fam, nil
+}
+
+// ListByUser returns families that are still alive. Expired ones are removed from the user's set.
+func (r *Tokenository) ListByUser(ctx context.Context, userID uint64) ([]*token.Family, error) {
+	setKey := userFamiliesKey(userID)
+
+	ids, err := r.client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, familyKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	families := make([]*token.Family, 0, len(ids))
+	var expired []interface{}
+
+	for i, cmd := range cmds {
+		m, err := cmd.Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(m) == 0 {
+			expired = append(expired, ids[i])
+			continue
+		}
+		fam, err := familyFromMap(ids[i], m)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, fam)
+	}
+
+	if len(expired) > 0 {
+		if err := r.client.SRem(ctx, setKey, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(families, func(a, b *token.Family) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+
+	return families, nil
+}
+
+func (r *Tokenository) RevokeFamily(ctx context.Context, userID uint64, familyID string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, familyKey(familyID))
+		pipe.SRem(ctx, userFamiliesKey(userID), familyID)
+		return nil
+	})
+	return err
+}
+
+func (r *Tokenository) Revoke(ctx context.Context, userID uint64) error {
+	setKey := userFamiliesKey(userID)
+
+	ids, err := r.client.SMembers(ctx, setKey).Result()
 	if err != nil {
 		return err
 	}
-	if refreshToken != token {
-		return auth.ErrTokenNotValid
+
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, familyKey(id))
 	}
-	return nil
+	keys = append(keys, setKey)
+
+	return r.client.Del(ctx, keys...).Err()
 }
 
-type Session struct {
-	GuestUUID string
-	CSRF      string
-	IssuedAt  string
-	ExpiresAt string
-	IP        string
-	UserAgent string
+var (
+	sessionKey = "session"
+)
+
+func (r *Tokenository) SaveSession(ctx context.Context, sess *token.Session, ttl time.Duration) error {
+	key := fmt.Sprintf("%s:%s", sessionKey, sess.GuestUUID)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, map[string]string{
+			"uuid":       sess.GuestUUID,
+			"csrf":       sess.CSRF,
+			"ip":         sess.IP,
+			"user_agent": sess.UserAgent,
+			"issued_at":  sess.IssuedAt.Format(time.RFC3339),
+			"expires_at": sess.ExpiresAt.Format(time.RFC3339),
+		})
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+
+	return err
 }
 
-// func (r *Tokenository) CreateSession(ctx context.Context, sess Session) error {
-// 	key := fmt.Sprintf("session:%s", sess.GuestUUID)
-// 	pipe := r.client.Pipeline()
-// 	pipe.HSet(ctx, key, map[string]string{
-// 		"uuid":       sess.GuestUUID,
-// 		"csrf":       sess.CSRF,
-// 		"issued_at":  sess.IssuedAt,
-// 		"expires_at": sess.ExpiresAt,
-// 		"ip":         sess.IP,
-// 		"user_agent": sess.UserAgent,
-// 	})
-// 	// set expires at
-// }
-
-// func (r *Tokenository) GetSession(ctx context.Context, guestUUID string) error {
-// }
+func (r *Tokenository) GetSession(ctx context.Context, guestUUID string) (*token.Session, error) {
+	m, err := r.client.HGetAll(ctx, fmt.Sprintf("%s:%s", sessionKey, guestUUID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, constants.ErrNotFound
+	}
+
+	sess := &token.Session{
+		GuestUUID: m["uuid"],
+		CSRF:      m["csrf"],
+		IP:        m["ip"],
+		UserAgent: m["user_agent"],
+	}
+
+	if sess.IssuedAt, err = time.Parse(time.RFC3339, m["issued_at"]); err != nil {
+		return nil, fmt.Errorf("invalid session issued_at: %w", err)
+	}
+	if sess.ExpiresAt, err = time.Parse(time.RFC3339, m["expires_at"]); err != nil {
+		return nil, fmt.Errorf("invalid session expires_at: %w", err)
+	}
+
+	return sess, nil
+}
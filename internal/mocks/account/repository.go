@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/account"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+var _ account.Repository = (*MockRepository)(nil)
+
+func (m *MockRepository) DeleteLinks(ctx context.Context, userID uint64) ([]string, error) {
+	args := m.Called(ctx, userID)
+	aliases, _ := args.Get(0).([]string)
+	return aliases, args.Error(1)
+}
+
+func (m *MockRepository) AnonymizeLinks(ctx context.Context, userID uint64) ([]string, error) {
+	args := m.Called(ctx, userID)
+	aliases, _ := args.Get(0).([]string)
+	return aliases, args.Error(1)
+}
+
+func (m *MockRepository) TransferLinks(ctx context.Context, fromUserID, toUserID uint64) ([]string, error) {
+	args := m.Called(ctx, fromUserID, toUserID)
+	aliases, _ := args.Get(0).([]string)
+	return aliases, args.Error(1)
+}
+
+func (m *MockRepository) StreamDailyClicks(ctx context.Context, userID uint64, fn func(c *account.DailyClicks) error) error {
+	args := m.Called(ctx, userID, fn)
+	return args.Error(0)
+}
+
+type MockReauthenticator struct {
+	mock.Mock
+}
+
+var _ account.Reauthenticator = (*MockReauthenticator)(nil)
+
+func (m *MockReauthenticator) Reauthenticate(ctx context.Context, userID uint64, creds user.Reauth) error {
+	args := m.Called(ctx, userID, creds)
+	return args.Error(0)
+}
+
+type MockLinkExporter struct {
+	mock.Mock
+}
+
+var _ account.LinkExporter = (*MockLinkExporter)(nil)
+
+func (m *MockLinkExporter) Export(ctx context.Context, userID uint64, fn func(tl *tinylink.Tinylink) error) error {
+	args := m.Called(ctx, userID, fn)
+	return args.Error(0)
+}
+
+type MockCacheEvicter struct {
+	mock.Mock
+}
+
+func (m *MockCacheEvicter) Evict(ctx context.Context, aliases ...string) error {
+	args := m.Called(ctx, aliases)
+	return args.Error(0)
+}
+
+// Transactor runs the function without a real transaction, passing ctx through.
+type Transactor struct{}
+
+func (Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
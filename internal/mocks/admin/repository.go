@@ -0,0 +1,84 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/admin"
+	"github.com/Kostaaa1/tinylink/internal/domain/tinylink"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+var _ admin.Repository = (*MockRepository)(nil)
+
+func (m *MockRepository) ListUsers(ctx context.Context, q admin.UserQuery) ([]*admin.UserSummary, error) {
+	args := m.Called(ctx, q)
+	users, _ := args.Get(0).([]*admin.UserSummary)
+	return users, args.Error(1)
+}
+
+func (m *MockRepository) GetUser(ctx context.Context, userID uint64) (*admin.UserSummary, error) {
+	args := m.Called(ctx, userID)
+	u, _ := args.Get(0).(*admin.UserSummary)
+	return u, args.Error(1)
+}
+
+func (m *MockRepository) SetUserDisabled(ctx context.Context, userID uint64, disabledAt *time.Time) error {
+	args := m.Called(ctx, userID, disabledAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteUser(ctx context.Context, userID uint64) ([]string, error) {
+	args := m.Called(ctx, userID)
+	aliases, _ := args.Get(0).([]string)
+	return aliases, args.Error(1)
+}
+
+func (m *MockRepository) GetLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error) {
+	args := m.Called(ctx, linkID)
+	tl, _ := args.Get(0).(*tinylink.Tinylink)
+	return tl, args.Error(1)
+}
+
+func (m *MockRepository) ExpireLink(ctx context.Context, linkID uint64, at time.Time) (*tinylink.Tinylink, error) {
+	args := m.Called(ctx, linkID, at)
+	tl, _ := args.Get(0).(*tinylink.Tinylink)
+	return tl, args.Error(1)
+}
+
+func (m *MockRepository) DeleteLink(ctx context.Context, linkID uint64) (*tinylink.Tinylink, error) {
+	args := m.Called(ctx, linkID)
+	tl, _ := args.Get(0).(*tinylink.Tinylink)
+	return tl, args.Error(1)
+}
+
+func (m *MockRepository) InsertAudit(ctx context.Context, entry *admin.AuditEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListAudit(ctx context.Context, limit, offset int) ([]*admin.AuditEntry, error) {
+	args := m.Called(ctx, limit, offset)
+	entries, _ := args.Get(0).([]*admin.AuditEntry)
+	return entries, args.Error(1)
+}
+
+type MockCacheEvicter struct {
+	mock.Mock
+}
+
+func (m *MockCacheEvicter) Evict(ctx context.Context, aliases ...string) error {
+	args := m.Called(ctx, aliases)
+	return args.Error(0)
+}
+
+// Transactor runs the function without a real transaction, passing ctx through.
+type Transactor struct{}
+
+func (Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
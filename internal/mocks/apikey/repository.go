@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/apikey"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+var _ apikey.Repository = (*MockRepository)(nil)
+
+func (m *MockRepository) Insert(ctx context.Context, key *apikey.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetByPrefix(ctx context.Context, prefix string) (*apikey.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	key, _ := args.Get(0).(*apikey.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockRepository) ListByUser(ctx context.Context, userID uint64) ([]*apikey.APIKey, error) {
+	args := m.Called(ctx, userID)
+	keys, _ := args.Get(0).([]*apikey.APIKey)
+	return keys, args.Error(1)
+}
+
+func (m *MockRepository) CountByUser(ctx context.Context, userID uint64) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, userID, keyID uint64) error {
+	args := m.Called(ctx, userID, keyID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteByUser(ctx context.Context, userID uint64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) TouchLastUsed(ctx context.Context, keyID uint64, at time.Time) error {
+	args := m.Called(ctx, keyID, at)
+	return args.Error(0)
+}
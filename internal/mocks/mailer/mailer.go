@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/Kostaaa1/tinylink/pkg/mailer"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMailer struct {
+	mock.Mock
+}
+
+var _ mailer.Mailer = (*MockMailer)(nil)
+
+func (m *MockMailer) Send(ctx context.Context, msg mailer.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
@@ -23,6 +23,11 @@ func (m *MockDbRepository) Insert(ctx context.Context, tl *tinylink.Tinylink) er
 	return args.Error(0)
 }
 
+func (m *MockDbRepository) InsertBatch(ctx context.Context, links []*tinylink.Tinylink) error {
+	args := m.Called(ctx, links)
+	return args.Error(0)
+}
+
 func (m *MockDbRepository) Update(ctx context.Context, tl *tinylink.Tinylink) error {
 	args := m.Called(ctx, tl)
 	return args.Error(0)
@@ -69,6 +74,52 @@ func (m *MockDbRepository) ListByGuestUUID(ctx context.Context, guestUUID string
 	return nil, args.Error(1)
 }
 
+func (m *MockDbRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDbRepository) ArchiveExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDbRepository) EnqueueEvictions(ctx context.Context, aliases []string) error {
+	args := m.Called(ctx, aliases)
+	return args.Error(0)
+}
+
+func (m *MockDbRepository) PendingEvictions(ctx context.Context, limit int) ([]tinylink.Eviction, error) {
+	args := m.Called(ctx, limit)
+	if rv := args.Get(0); rv != nil {
+		return rv.([]tinylink.Eviction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDbRepository) CompleteEvictions(ctx context.Context, ids []uint64) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
+
+func (m *MockDbRepository) FailEvictions(ctx context.Context, ids []uint64, reason string) error {
+	args := m.Called(ctx, ids, reason)
+	return args.Error(0)
+}
+
+func (m *MockDbRepository) StreamByUserID(ctx context.Context, userID uint64, fn func(tl *tinylink.Tinylink) error) error {
+	args := m.Called(ctx, userID, fn)
+	return args.Error(0)
+}
+
+func (m *MockDbRepository) List(ctx context.Context, q tinylink.ListQuery) ([]*tinylink.Tinylink, error) {
+	args := m.Called(ctx, q)
+	if rv := args.Get(0); rv != nil {
+		return rv.([]*tinylink.Tinylink), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 var _ tinylink.CacheRepository = (*MockCacheRepository)(nil)
 
 func (m *MockCacheRepository) Redirect(ctx context.Context, alias string) (*tinylink.RedirectValue, error) {
@@ -93,3 +144,34 @@ func (m *MockCacheRepository) GenerateAlias(ctx context.Context) (string, error)
 	}
 	return "", args.Error(1)
 }
+
+func (m *MockCacheRepository) Evict(ctx context.Context, aliases ...string) error {
+	args := m.Called(ctx, aliases)
+	return args.Error(0)
+}
+
+func (m *MockCacheRepository) GenerateAliases(ctx context.Context, n int) ([]string, error) {
+	args := m.Called(ctx, n)
+	if rv := args.Get(0); rv != nil {
+		return rv.([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockVerificationChecker struct {
+	mock.Mock
+}
+
+var _ tinylink.VerificationChecker = (*MockVerificationChecker)(nil)
+
+func (m *MockVerificationChecker) EmailVerified(ctx context.Context, userID uint64) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+// Transactor runs the function without a real transaction, passing ctx through.
+type Transactor struct{}
+
+func (Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/token"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+var _ token.Repository = (*MockRepository)(nil)
+
+func (m *MockRepository) Create(ctx context.Context, fam *token.Family, tokenHash string, ttl time.Duration) error {
+	args := m.Called(ctx, fam, tokenHash, ttl)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Rotate(ctx context.Context, familyID, oldHash, newHash string, now time.Time, ttl time.Duration) (*token.Family, error) {
+	args := m.Called(ctx, familyID, oldHash, newHash, now, ttl)
+	fam, _ := args.Get(0).(*token.Family)
+	return fam, args.Error(1)
+}
+
+func (m *MockRepository) Get(ctx context.Context, familyID string) (*token.Family, error) {
+	args := m.Called(ctx, familyID)
+	fam, _ := args.Get(0).(*token.Family)
+	return fam, args.Error(1)
+}
+
+func (m *MockRepository) ListByUser(ctx context.Context, userID uint64) ([]*token.Family, error) {
+	args := m.Called(ctx, userID)
+	families, _ := args.Get(0).([]*token.Family)
+	return families, args.Error(1)
+}
+
+func (m *MockRepository) RevokeFamily(ctx context.Context, userID uint64, familyID string) error {
+	args := m.Called(ctx, userID, familyID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Revoke(ctx context.Context, userID uint64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+type MockRoleReader struct {
+	mock.Mock
+}
+
+func (m *MockRoleReader) Roles(ctx context.Context, userID uint64) ([]string, error) {
+	args := m.Called(ctx, userID)
+	roles, _ := args.Get(0).([]string)
+	return roles, args.Error(1)
+}
@@ -22,10 +22,9 @@ func User() *user.User {
 	val := rand.IntN(10000)
 
 	userData := &user.User{
-		ID:     uint64(val),
-		Name:   fmt.Sprintf("testname_%d", val),
-		Email:  fmt.Sprintf("testname_%d@gmail.com", val),
-		Google: nil,
+		ID:    uint64(val),
+		Name:  fmt.Sprintf("testname_%d", val),
+		Email: fmt.Sprintf("testname_%d@gmail.com", val),
 	}
 
 	randPw := strconv.Itoa(rand.IntN(100000000))
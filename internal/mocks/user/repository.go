@@ -1 +1,287 @@
 package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/internal/domain/user"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+var _ user.Repository = (*MockRepository)(nil)
+
+func (m *MockRepository) Insert(ctx context.Context, u *user.User) error {
+	args := m.Called(ctx, u)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	args := m.Called(ctx, email)
+	if rv := args.Get(0); rv != nil {
+		return rv.(*user.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetByID(ctx context.Context, id uint64) (*user.User, error) {
+	args := m.Called(ctx, id)
+	if rv := args.Get(0); rv != nil {
+		return rv.(*user.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) Update(ctx context.Context, u *user.User) error {
+	args := m.Called(ctx, u)
+	return args.Error(0)
+}
+
+func (m *MockRepository) MarkEmailVerified(ctx context.Context, userID uint64, email string, at time.Time) error {
+	args := m.Called(ctx, userID, email, at)
+	return args.Error(0)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, userID uint64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// Transactor runs the function without a real transaction, passing ctx through.
+type Transactor struct{}
+
+func (Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type MockLinkClaimer struct {
+	mock.Mock
+}
+
+func (m *MockLinkClaimer) ClaimGuestLinks(ctx context.Context, guestUUID string, userID uint64) ([]string, []string, error) {
+	args := m.Called(ctx, guestUUID, userID)
+	claimed, _ := args.Get(0).([]string)
+	conflicts, _ := args.Get(1).([]string)
+	return claimed, conflicts, args.Error(2)
+}
+
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+var _ user.RoleRepository = (*MockRoleRepository)(nil)
+
+func (m *MockRoleRepository) Roles(ctx context.Context, userID uint64) ([]string, error) {
+	args := m.Called(ctx, userID)
+	roles, _ := args.Get(0).([]string)
+	return roles, args.Error(1)
+}
+
+func (m *MockRoleRepository) Assign(ctx context.Context, userID uint64, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) Revoke(ctx context.Context, userID uint64, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+type MockAttemptRepository struct {
+	mock.Mock
+}
+
+var _ user.AttemptRepository = (*MockAttemptRepository)(nil)
+
+func (m *MockAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	args := m.Called(ctx, key, window)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAttemptRepository) Failures(ctx context.Context, key string) (int, error) {
+	args := m.Called(ctx, key)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAttemptRepository) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	args := m.Called(ctx, key, ttl)
+	return args.Error(0)
+}
+
+func (m *MockAttemptRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	args := m.Called(ctx, key)
+	d, _ := args.Get(0).(time.Duration)
+	return d, args.Error(1)
+}
+
+func (m *MockAttemptRepository) Reset(ctx context.Context, keys ...string) error {
+	args := m.Called(ctx, keys)
+	return args.Error(0)
+}
+
+type MockLockoutNotifier struct {
+	mock.Mock
+}
+
+func (m *MockLockoutNotifier) AccountLocked(ctx context.Context, event user.AccountLockedEvent) {
+	m.Called(ctx, event)
+}
+
+type MockResetTokenRepository struct {
+	mock.Mock
+}
+
+var _ user.ResetTokenRepository = (*MockResetTokenRepository)(nil)
+
+func (m *MockResetTokenRepository) Insert(ctx context.Context, t *user.ResetToken) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockResetTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (uint64, error) {
+	args := m.Called(ctx, tokenHash, now)
+	id, _ := args.Get(0).(uint64)
+	return id, args.Error(1)
+}
+
+func (m *MockResetTokenRepository) DeleteByUser(ctx context.Context, userID uint64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+type MockTwoFactorRepository struct {
+	mock.Mock
+}
+
+var _ user.TwoFactorRepository = (*MockTwoFactorRepository)(nil)
+
+func (m *MockTwoFactorRepository) GetTOTP(ctx context.Context, userID uint64) (*user.TOTP, error) {
+	args := m.Called(ctx, userID)
+	if rv := args.Get(0); rv != nil {
+		return rv.(*user.TOTP), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTwoFactorRepository) SaveTOTP(ctx context.Context, t *user.TOTP) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepository) ConfirmTOTP(ctx context.Context, userID uint64, at time.Time) error {
+	args := m.Called(ctx, userID, at)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepository) UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTwoFactorRepository) DeleteTOTP(ctx context.Context, userID uint64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint64, hashes []string) error {
+	args := m.Called(ctx, userID, hashes)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uint64, hash string, at time.Time) (bool, error) {
+	args := m.Called(ctx, userID, hash, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uint64) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+type MockChallengeRepository struct {
+	mock.Mock
+}
+
+var _ user.ChallengeRepository = (*MockChallengeRepository)(nil)
+
+func (m *MockChallengeRepository) Create(ctx context.Context, tokenHash string, userID uint64, ttl time.Duration) error {
+	args := m.Called(ctx, tokenHash, userID, ttl)
+	return args.Error(0)
+}
+
+func (m *MockChallengeRepository) Attempt(ctx context.Context, tokenHash string) (uint64, int, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(uint64), args.Int(1), args.Error(2)
+}
+
+func (m *MockChallengeRepository) Delete(ctx context.Context, tokenHash string) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+type MockIdentityRepository struct {
+	mock.Mock
+}
+
+var _ user.IdentityRepository = (*MockIdentityRepository)(nil)
+
+func (m *MockIdentityRepository) Get(ctx context.Context, provider, subject string) (*user.Identity, error) {
+	args := m.Called(ctx, provider, subject)
+	if rv := args.Get(0); rv != nil {
+		return rv.(*user.Identity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockIdentityRepository) ListByUser(ctx context.Context, userID uint64) ([]*user.Identity, error) {
+	args := m.Called(ctx, userID)
+	if rv := args.Get(0); rv != nil {
+		return rv.([]*user.Identity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockIdentityRepository) Insert(ctx context.Context, identity *user.Identity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) Touch(ctx context.Context, identity *user.Identity, at time.Time) error {
+	args := m.Called(ctx, identity, at)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) LockByUser(ctx context.Context, userID uint64) ([]*user.Identity, error) {
+	args := m.Called(ctx, userID)
+	if rv := args.Get(0); rv != nil {
+		return rv.([]*user.Identity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockIdentityRepository) Delete(ctx context.Context, userID, id uint64) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+type MockPendingLinkRepository struct {
+	mock.Mock
+}
+
+var _ user.PendingLinkRepository = (*MockPendingLinkRepository)(nil)
+
+func (m *MockPendingLinkRepository) Save(ctx context.Context, tokenHash string, identity *user.Identity, ttl time.Duration) error {
+	args := m.Called(ctx, tokenHash, identity, ttl)
+	return args.Error(0)
+}
+
+func (m *MockPendingLinkRepository) Consume(ctx context.Context, tokenHash string) (*user.Identity, error) {
+	args := m.Called(ctx, tokenHash)
+	if rv := args.Get(0); rv != nil {
+		return rv.(*user.Identity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -14,6 +14,7 @@ import (
 // 403 - Forbidden
 // 404 - Not found
 // 405 - Method not allowed
+// 410 - Gone
 // 500 - Internal server error
 
 type ErrorHandler struct {
@@ -82,6 +83,10 @@ func (h ErrorHandler) NotFoundResponse(w http.ResponseWriter, r *http.Request) {
 	h.ErrorResponse(w, r, http.StatusNotFound, "the requested resource could not be found")
 }
 
+func (h ErrorHandler) GoneResponse(w http.ResponseWriter, r *http.Request) {
+	h.ErrorResponse(w, r, http.StatusGone, "the requested resource is no longer available")
+}
+
 func (h ErrorHandler) MethodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	h.ErrorResponse(w, r, http.StatusMethodNotAllowed, "method not allowed for this resource")
 }
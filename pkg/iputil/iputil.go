@@ -0,0 +1,115 @@
+package iputil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedMu      sync.RWMutex
+	trustedProxies []netip.Prefix
+)
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and CIDR ranges.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.Contains(part, "/") {
+			p, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// SetTrustedProxies replaces the proxies whose forwarding headers ClientIP honours. Called once on startup, without it headers are ignored.
+func SetTrustedProxies(prefixes []netip.Prefix) {
+	trustedMu.Lock()
+	defer trustedMu.Unlock()
+	trustedProxies = prefixes
+}
+
+func isTrusted(addr netip.Addr) bool {
+	trustedMu.RLock()
+	defer trustedMu.RUnlock()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAddr(s string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// ClientIP returns the address of the client that made the request. X-Forwarded-For and X-Real-IP are honoured only when RemoteAddr is a
+// trusted proxy. X-Forwarded-For is walked from the right and the first hop that is not a trusted proxy is the client, since hops left
+// of it were written by the client and can be forged.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, ok := parseAddr(host)
+	if !ok || !isTrusted(remote) {
+		return host
+	}
+
+	if hops := r.Header.Values("X-Forwarded-For"); len(hops) > 0 {
+		hops = strings.Split(strings.Join(hops, ","), ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, ok := parseAddr(hops[i])
+			if !ok {
+				// a malformed hop can not be attributed to anyone, the last valid hop is the best known client
+				break
+			}
+			client = addr
+			if !isTrusted(addr) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if addr, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+		return addr.String()
+	}
+
+	return remote.String()
+}
+
+// Country returns the ISO country code of the client as reported by the edge proxy (e.g. Cloudflare), or empty string if unknown.
+func Country(r *http.Request) string {
+	for _, h := range []string{"CF-IPCountry", "X-Country-Code"} {
+		if c := strings.TrimSpace(r.Header.Get(h)); c != "" && c != "XX" {
+			return strings.ToUpper(c)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package iputil_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kostaaa1/tinylink/pkg/iputil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := iputil.ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+	iputil.SetTrustedProxies(proxies)
+	t.Cleanup(func() { iputil.SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		realIP string
+		want   string
+	}{
+		{name: "direct client", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "headers of untrusted client are ignored", remote: "203.0.113.7:5000", xff: "1.2.3.4", realIP: "5.6.7.8", want: "203.0.113.7"},
+		{name: "trusted proxy", remote: "10.0.0.2:5000", xff: "203.0.113.7", want: "203.0.113.7"},
+		{name: "forged hops left of the client are ignored", remote: "10.0.0.2:5000", xff: "1.2.3.4, 203.0.113.7", want: "203.0.113.7"},
+		{name: "chain of trusted proxies", remote: "10.0.0.2:5000", xff: "203.0.113.7, 192.168.1.1, 10.0.0.3", want: "203.0.113.7"},
+		{name: "x-real-ip from trusted proxy", remote: "192.168.1.1:5000", realIP: "203.0.113.7", want: "203.0.113.7"},
+		{name: "trusted proxy without headers", remote: "10.0.0.2:5000", want: "10.0.0.2"},
+		{name: "malformed hop", remote: "10.0.0.2:5000", xff: "garbage, 203.0.113.7", want: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			require.Equal(t, tt.want, iputil.ClientIP(r))
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	_, err := iputil.ParseTrustedProxies("10.0.0.0/8,not-an-ip")
+	require.Error(t, err)
+
+	proxies, err := iputil.ParseTrustedProxies("")
+	require.NoError(t, err)
+	require.Empty(t, proxies)
+}
@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// build renders the message in RFC 5322 format.
+func build(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// validRecipient rejects addresses that could inject headers.
+func validRecipient(to string) error {
+	if to == "" || strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", to)
+	}
+	return nil
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type SMTPMailer struct {
+	conf SMTPConfig
+}
+
+func NewSMTPMailer(conf SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{conf: conf}
+}
+
+// Send delivers the message through the SMTP server. STARTTLS is used when the server supports it, which net/smtp requires for PLAIN auth on non-local hosts.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := validRecipient(msg.To); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.conf.Username != "" {
+		auth = smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)
+	}
+
+	addr := net.JoinHostPort(m.conf.Host, strconv.Itoa(m.conf.Port))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- smtp.SendMail(addr, auth, m.conf.From, []string{msg.To}, build(m.conf.From, msg, time.Now()))
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errc:
+		return err
+	}
+}
+
+// FileMailer writes every message as .eml file into the directory. Meant for local development and tests.
+type FileMailer struct {
+	dir  string
+	from string
+	seq  atomic.Int64
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := validRecipient(msg.To); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405"), m.seq.Add(1))
+	return os.WriteFile(filepath.Join(m.dir, name), build(m.from, msg, now), 0o644)
+}
+
+// LogMailer logs messages instead of sending them. Message body is logged too, so it must not be used in production.
+type LogMailer struct {
+	log *slog.Logger
+}
+
+func NewLogMailer(log *slog.Logger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := validRecipient(msg.To); err != nil {
+		return err
+	}
+	m.log.Info("mail", "to", msg.To, "subject", msg.Subject, "text", msg.Text)
+	return nil
+}
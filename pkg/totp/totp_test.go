@@ -0,0 +1,61 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Kostaaa1/tinylink/pkg/totp"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed from RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// appendix B lists 8 digit codes, these are their last 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, want, got, "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	prev, err := totp.Code(secret, totp.Step(now)-1)
+	require.NoError(t, err)
+
+	step, ok := totp.Validate(secret, prev, now, 1)
+	require.True(t, ok)
+	require.Equal(t, totp.Step(now)-1, step)
+
+	_, ok = totp.Validate(secret, prev, now, 0)
+	require.False(t, ok)
+
+	_, ok = totp.Validate(secret, "12345", now, 1)
+	require.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(totp.URI("Tinylink", "john@gmail.com", "ABC"))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/Tinylink:john@gmail.com", u.Path)
+	require.Equal(t, "ABC", u.Query().Get("secret"))
+	require.Equal(t, "Tinylink", u.Query().Get("issuer"))
+}